@@ -61,6 +61,28 @@ function formatAlertType(type: AlertType): string {
       return "CPU Threshold";
     case "memory_threshold":
       return "Memory Threshold";
+    case "container_unhealthy":
+      return "Container Unhealthy";
+    case "container_recovered":
+      return "Container Recovered";
+    case "crash_loop":
+      return "Crash Loop";
+    case "crash_loop_resolved":
+      return "Crash Loop Resolved";
+    case "container_oom_killed":
+      return "Out of Memory";
+    case "host_disk":
+      return "Host Disk";
+    case "host_memory":
+      return "Host Memory";
+    case "host_load":
+      return "Host Load";
+    case "host_swap":
+      return "Host Swap";
+    case "host_inodes":
+      return "Host Inodes";
+    case "host_resolved":
+      return "Host Resolved";
     default:
       return type;
   }
@@ -1,7 +1,18 @@
 export type AlertType =
   | "container_stopped"
   | "cpu_threshold"
-  | "memory_threshold";
+  | "memory_threshold"
+  | "container_unhealthy"
+  | "container_recovered"
+  | "crash_loop"
+  | "crash_loop_resolved"
+  | "container_oom_killed"
+  | "host_disk"
+  | "host_memory"
+  | "host_load"
+  | "host_swap"
+  | "host_inodes"
+  | "host_resolved";
 
 export interface Alert {
   id: string;
@@ -14,6 +25,24 @@ export interface Alert {
   threshold?: number;
   timestamp: number;
   acknowledged: boolean;
+  restart_count?: number;
+  restart_history?: number[];
+  exit?: ExitDiagnostics;
+  mount?: string;
+}
+
+export interface ExitDiagnostics {
+  exit_code: number;
+  oom_killed: boolean;
+  finished_at?: number;
+  error?: string;
+  logs?: {
+    timestamp: string;
+    level: string;
+    message: string;
+    stream: string;
+    raw: string;
+  }[];
 }
 
 export interface AlertConfig {
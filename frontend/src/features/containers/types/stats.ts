@@ -2,6 +2,7 @@ export interface ContainerStats {
   container_id: string;
   host: string;
   cpu_percent: number;
+  cpu_usage: number;
   memory_usage: number;
   memory_limit: number;
   memory_percent: number;
@@ -7,6 +7,9 @@ export interface ImageInfo {
   created: number;
   labels: Record<string, string> | null;
   host: string;
+  containers: number;
+  unused: boolean;
+  dangling: boolean;
 }
 
 export interface ImagePullProgress {
@@ -4,19 +4,33 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/hhftechnology/vps-monitor/internal/agent"
 	"github.com/hhftechnology/vps-monitor/internal/alerts"
 	"github.com/hhftechnology/vps-monitor/internal/api"
 	"github.com/hhftechnology/vps-monitor/internal/auth"
 	"github.com/hhftechnology/vps-monitor/internal/config"
 	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/sinks"
+	"github.com/hhftechnology/vps-monitor/internal/stats"
 	"github.com/hhftechnology/vps-monitor/internal/system"
+	"github.com/hhftechnology/vps-monitor/internal/tsdb"
 )
 
 func main() {
 	system.Init()
 
 	cfg := config.NewConfig()
+
+	// Agent mode serves the local Docker API and system stats to a central server
+	if (len(os.Args) > 1 && os.Args[1] == "agent") || os.Getenv("RUN_MODE") == "agent" {
+		runAgent(cfg)
+		return
+	}
+
 	fmt.Println("Config", cfg)
 
 	multiHostClient, err := docker.NewMultiHostClient(cfg.DockerHosts)
@@ -34,6 +48,9 @@ func main() {
 		log.Println("   To enable authentication, set: JWT_SECRET, ADMIN_USERNAME, ADMIN_PASSWORD")
 	} else {
 		log.Println("Authentication is ENABLED")
+		if cfg.Metrics.Token == "" {
+			log.Println("   /metrics requires an API token, set METRICS_TOKEN for Prometheus scrapes")
+		}
 	}
 
 	if cfg.ReadOnly {
@@ -43,10 +60,30 @@ func main() {
 		log.Println("Read-only mode is DISABLED - all operations are allowed")
 	}
 
+	// Container stats are recorded whether or not alerts are enabled, the
+	// alert monitor checks thresholds against the latest samples
+	statsStore := openStatsStore(cfg)
+	defer statsStore.Close()
+
+	containerSampler := stats.NewContainerSampler(multiHostClient, stats.NewHistoryManager(statsStore), cfg.StatsInterval)
+	containerSampler.Start()
+	defer containerSampler.Stop()
+	log.Printf("Container stats are recorded every %s", cfg.StatsInterval)
+
+	hostSampler := stats.NewHostSampler(cfg.HostMetricsInterval, cfg.HostMetricsRetention)
+	hostSampler.Start()
+	defer hostSampler.Stop()
+	log.Printf("Host metrics are sampled every %s, keeping %s of history",
+		cfg.HostMetricsInterval, cfg.HostMetricsRetention)
+
+	if metricSinks := startMetricSinks(cfg, multiHostClient, containerSampler, hostSampler); metricSinks != nil {
+		defer metricSinks.Stop()
+	}
+
 	// Initialize alert monitor if enabled
 	var alertMonitor *alerts.Monitor
 	if cfg.Alerts.Enabled {
-		alertMonitor = alerts.NewMonitor(multiHostClient, &cfg.Alerts)
+		alertMonitor = alerts.NewMonitor(multiHostClient, &cfg.Alerts, containerSampler)
 		alertMonitor.Start()
 		defer alertMonitor.Stop()
 		log.Println("Alert monitoring is ENABLED")
@@ -61,7 +98,9 @@ func main() {
 	}
 
 	routerOpts := &api.RouterOptions{
-		AlertMonitor: alertMonitor,
+		AlertMonitor:     alertMonitor,
+		HostSampler:      hostSampler,
+		ContainerSampler: containerSampler,
 	}
 	apiRouter := api.NewRouter(multiHostClient, authService, cfg, routerOpts)
 
@@ -70,3 +109,65 @@ func main() {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// openStatsStore opens the container stats history in the data directory,
+// falling back to memory so a read-only filesystem does not prevent startup
+func openStatsStore(cfg *config.Config) *tsdb.DB {
+	storeOpts := tsdb.DBOptions{Retention: cfg.StatsRetention}
+	for _, rollup := range cfg.StatsRollups {
+		storeOpts.Rollups = append(storeOpts.Rollups, tsdb.Rollup{Step: rollup.Step, Retention: rollup.Retention})
+	}
+
+	store, err := tsdb.OpenDB(filepath.Join(cfg.DataDir, "stats"), storeOpts)
+	if err == nil {
+		log.Printf("Stats history is stored in %s (raw retention: %s, rollups: %s)", cfg.DataDir, cfg.StatsRetention, formatRollups(store.Rollups()))
+		return store
+	}
+
+	log.Printf("Failed to open stats history in %s, keeping it in memory only: %v", cfg.DataDir, err)
+	store, _ = tsdb.OpenDB("", storeOpts)
+	return store
+}
+
+// startMetricSinks starts pushing the samples of the samplers to the
+// configured sinks, or returns nil when there are none
+func startMetricSinks(cfg *config.Config, dockerClient *docker.MultiHostClient, containerSampler *stats.ContainerSampler, hostSampler *stats.HostSampler) *sinks.Manager {
+	if len(cfg.Metrics.Sinks) == 0 {
+		return nil
+	}
+
+	metricSinks, err := sinks.NewManager(cfg.Metrics.Sinks)
+	if err != nil {
+		log.Fatalf("Failed to set up metric sinks: %v", err)
+	}
+	metricSinks.Start()
+	metricSinks.Forward(dockerClient, containerSampler, hostSampler)
+
+	for _, sink := range cfg.Metrics.Sinks {
+		target := sink.URL
+		if target == "" {
+			target = sink.Address
+		}
+		log.Printf("Metrics are pushed to %s sink %s at %s every %s", sink.Type, sink.Name, target, sink.FlushInterval)
+	}
+	return metricSinks
+}
+
+func formatRollups(rollups []tsdb.Rollup) string {
+	parts := make([]string, len(rollups))
+	for i, rollup := range rollups {
+		parts[i] = fmt.Sprintf("%s for %s", tsdb.FormatStep(rollup.Step), rollup.Retention)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runAgent(cfg *config.Config) {
+	agentServer, err := agent.NewServer(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start agent: %v", err)
+	}
+
+	if err := agentServer.ListenAndServe(); err != nil {
+		log.Fatalf("Agent failed: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/config"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// Server exposes the local Docker API and system stats of a host to a
+// central vps-monitor. Every request must carry the agent token, a client
+// certificate signed by the configured CA, or both.
+type Server struct {
+	config   config.AgentConfig
+	readOnly bool
+	hostname string
+	proxy    *httputil.ReverseProxy
+}
+
+// NewServer creates an agent server. It refuses to run without a token or
+// client certificate authentication.
+func NewServer(cfg *config.Config) (*Server, error) {
+	agentConfig := *cfg.Agent
+
+	if agentConfig.Token == "" && agentConfig.TLSClientCA == "" {
+		return nil, fmt.Errorf("agent mode requires AGENT_TOKEN or AGENT_TLS_CLIENT_CA")
+	}
+	if agentConfig.TLSClientCA != "" && (agentConfig.TLSCert == "" || agentConfig.TLSKey == "") {
+		return nil, fmt.Errorf("AGENT_TLS_CLIENT_CA requires AGENT_TLS_CERT and AGENT_TLS_KEY")
+	}
+	if agentConfig.Token != "" && agentConfig.TLSCert == "" && !agentConfig.Insecure {
+		return nil, fmt.Errorf("AGENT_TOKEN would be sent in clear text; set AGENT_TLS_CERT and AGENT_TLS_KEY, or AGENT_INSECURE=true to allow plain HTTP")
+	}
+
+	socket := agentConfig.DockerSocket
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = "http"
+			r.Out.URL.Host = "docker"
+			r.Out.URL.Path = strings.TrimPrefix(r.In.URL.Path, docker.AgentDockerPath)
+			r.Out.URL.RawPath = ""
+			r.Out.Header.Del("Authorization")
+		},
+		Transport: transport,
+		// Logs, stats and events are streamed
+		FlushInterval: -1,
+	}
+
+	return &Server{
+		config:   agentConfig,
+		readOnly: cfg.ReadOnly,
+		hostname: cfg.Hostname,
+		proxy:    proxy,
+	}, nil
+}
+
+// ListenAndServe serves the agent API, over TLS when a certificate is
+// configured
+func (s *Server) ListenAndServe() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle("GET /system/stats", s.authenticate(http.HandlerFunc(s.getSystemStats)))
+	mux.Handle("GET /system/metrics", s.authenticate(http.HandlerFunc(s.getSystemMetrics)))
+	mux.Handle("GET /system/processes", s.authenticate(http.HandlerFunc(s.getSystemProcesses)))
+	mux.Handle(docker.AgentDockerPath+"/", s.authenticate(s.readOnlyGuard(s.proxy)))
+
+	server := &http.Server{
+		Addr:              s.config.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if s.config.TLSCert == "" {
+		log.Printf("Warning: AGENT_INSECURE is set, the agent token is accepted over plain HTTP and can be read by anyone on the network path")
+		log.Printf("Agent listening on %s (plain HTTP, token authentication)", s.config.Listen)
+		return server.ListenAndServe()
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.config.TLSClientCA != "" {
+		caPEM, err := os.ReadFile(s.config.TLSClientCA)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("client CA %s contains no certificate", s.config.TLSClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	server.TLSConfig = tlsConfig
+
+	log.Printf("Agent listening on %s (TLS, client certificates: %t, token: %t)",
+		s.config.Listen, s.config.TLSClientCA != "", s.config.Token != "")
+	return server.ListenAndServeTLS(s.config.TLSCert, s.config.TLSKey)
+}
+
+// authenticate checks the bearer token when one is configured. Client
+// certificates are verified during the TLS handshake.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.Token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readOnlyGuard only lets read requests through to Docker in read-only mode
+func (s *Server) readOnlyGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "agent is in read-only mode", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getSystemStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := system.GetStats(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if s.hostname != "" {
+		stats.HostInfo.Hostname = s.hostname
+	}
+
+	writeJSON(w, stats)
+}
+
+func (s *Server) getSystemMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := system.GetMetrics(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, metrics)
+}
+
+func (s *Server) getSystemProcesses(w http.ResponseWriter, r *http.Request) {
+	sortBy := system.ProcessSort(r.URL.Query().Get("sort"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	processes, err := system.GetTopProcesses(r.Context(), sortBy, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, processes)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
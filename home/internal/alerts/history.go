@@ -11,6 +11,9 @@ type AlertHistory struct {
 	alerts  []models.Alert
 	mu      sync.RWMutex
 	maxSize int
+
+	// totals counts every alert added by type, including trimmed and cleared ones
+	totals map[models.AlertType]uint64
 }
 
 // NewAlertHistory creates a new alert history with the specified max size
@@ -18,6 +21,7 @@ func NewAlertHistory(maxSize int) *AlertHistory {
 	return &AlertHistory{
 		alerts:  make([]models.Alert, 0, maxSize),
 		maxSize: maxSize,
+		totals:  make(map[models.AlertType]uint64),
 	}
 }
 
@@ -28,6 +32,7 @@ func (h *AlertHistory) Add(alert models.Alert) {
 
 	// Add to the beginning for newest-first ordering
 	h.alerts = append([]models.Alert{alert}, h.alerts...)
+	h.totals[alert.Type]++
 
 	// Trim if exceeds max size
 	if len(h.alerts) > h.maxSize {
@@ -88,6 +93,18 @@ func (h *AlertHistory) GetUnacknowledgedCount() int {
 	return count
 }
 
+// GetTotals returns the number of alerts added since startup by type
+func (h *AlertHistory) GetTotals() map[models.AlertType]uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make(map[models.AlertType]uint64, len(h.totals))
+	for alertType, count := range h.totals {
+		result[alertType] = count
+	}
+	return result
+}
+
 // Clear removes all alerts from history
 func (h *AlertHistory) Clear() {
 	h.mu.Lock()
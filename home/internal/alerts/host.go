@@ -0,0 +1,278 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// hostCheck is a single host threshold evaluated against a metrics snapshot
+type hostCheck struct {
+	alertType models.AlertType
+	mount     string
+	value     float64
+	threshold float64
+	message   string // Describes the exceeded threshold
+	resolved  string // Describes the recovery
+}
+
+// activeHostAlert is a raised host alert that has not been resolved yet
+type activeHostAlert struct {
+	id        string
+	host      string
+	alertType models.AlertType
+	mount     string
+}
+
+// helperCheckTimeout bounds a helper run, including the first image pull
+const helperCheckTimeout = 2 * time.Minute
+
+// checkHostThresholds checks disk, inode, memory, swap and load thresholds of
+// the machine running vps-monitor and of agent hosts. Other hosts are only
+// checked when HostHelperInterval is set, by the stats helper container,
+// which covers the root filesystem, memory and load only.
+func (m *Monitor) checkHostThresholds(ctx context.Context) {
+	localChecked := false
+	known := make(map[string]struct{})
+
+	for _, host := range m.docker.GetHosts() {
+		known[host.Name] = struct{}{}
+
+		var (
+			metrics *system.HostMetrics
+			err     error
+		)
+
+		switch {
+		case host.Agent != nil:
+			metrics, err = m.docker.GetHostMetrics(ctx, host.Name)
+		case strings.HasPrefix(host.Host, "unix://"):
+			// Every socket host is the local machine, only alert once
+			if localChecked {
+				continue
+			}
+			localChecked = true
+			metrics, err = m.hostCollector.Collect(ctx)
+		default:
+			if m.config.HostHelperImage == "" || m.config.HostHelperInterval <= 0 {
+				m.logUncheckedOnce(host.Name, "host thresholds are not checked, as the stats helper is disabled")
+				continue
+			}
+			m.logUncheckedOnce(host.Name, "swap and inode thresholds are not checked, as the stats helper does not report them")
+			if time.Since(m.helperChecked[host.Name]) < m.config.HostHelperInterval {
+				continue
+			}
+			m.helperChecked[host.Name] = time.Now()
+			metrics, err = m.helperHostMetrics(host.Name)
+		}
+
+		if errors.Is(err, docker.ErrNotAgentHost) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Alert monitor: failed to get host metrics for %s: %v", host.Name, err)
+			continue
+		}
+
+		m.evaluateHostChecks(host.Name, m.hostChecks(metrics))
+	}
+
+	// Resolve alerts of hosts that are no longer configured
+	m.statesMu.Lock()
+	for key, active := range m.hostAlerts {
+		if _, ok := known[active.host]; !ok {
+			m.resolveVanishedHostAlert(key, active)
+		}
+	}
+	m.statesMu.Unlock()
+}
+
+// logUncheckedOnce logs the host checks that are unavailable for a host the
+// first time it is checked
+func (m *Monitor) logUncheckedOnce(hostName, message string) {
+	if _, logged := m.uncheckedLogged[hostName]; logged {
+		return
+	}
+	m.uncheckedLogged[hostName] = struct{}{}
+	log.Printf("Alert monitor: %s: %s", hostName, message)
+}
+
+// helperHostMetrics reads the stats of a host without an agent through the
+// stats helper container and the CPU count of the Docker daemon
+func (m *Monitor) helperHostMetrics(hostName string) (*system.HostMetrics, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), helperCheckTimeout)
+	defer cancel()
+
+	stats, err := m.docker.GetHostSystemStats(ctx, hostName, m.config.HostHelperImage)
+	if err != nil {
+		return nil, err
+	}
+	info, err := m.docker.GetHostInfo(ctx, hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := stats.Usage
+	metrics := &system.HostMetrics{
+		Timestamp: time.Now().Unix(),
+		CPU:       system.CPUMetrics{Percent: usage.CPUPercent},
+		Load:      system.LoadMetrics{Load1: usage.Load1, Load5: usage.Load5, Load15: usage.Load15},
+		Memory: system.MemoryMetrics{
+			Total:     usage.MemoryTotal,
+			Used:      usage.MemoryUsed,
+			Available: usage.MemoryTotal - usage.MemoryUsed,
+			Percent:   usage.MemoryPercent,
+		},
+		Filesystems: []system.FilesystemMetrics{{
+			Mountpoint: "/",
+			Total:      usage.DiskTotal,
+			Used:       usage.DiskUsed,
+			Percent:    usage.DiskPercent,
+		}},
+	}
+	if info.CPUs > 0 {
+		metrics.Load.Load1PerCore = usage.Load1 / float64(info.CPUs)
+	}
+	return metrics, nil
+}
+
+// hostChecks lists the enabled threshold checks for a metrics snapshot
+func (m *Monitor) hostChecks(metrics *system.HostMetrics) []hostCheck {
+	checks := []hostCheck{}
+
+	for _, fs := range metrics.Filesystems {
+		if m.config.HostDiskThreshold > 0 {
+			checks = append(checks, hostCheck{
+				alertType: models.AlertHostDisk,
+				mount:     fs.Mountpoint,
+				value:     fs.Percent,
+				threshold: m.config.HostDiskThreshold,
+				message:   fmt.Sprintf("disk usage of %s (%.1f%%) exceeds threshold (%.1f%%)", fs.Mountpoint, fs.Percent, m.config.HostDiskThreshold),
+				resolved:  fmt.Sprintf("disk usage of %s is back to %.1f%%", fs.Mountpoint, fs.Percent),
+			})
+		}
+		// Some filesystems (btrfs, overlay) report no inodes
+		if m.config.HostInodeThreshold > 0 && fs.InodesTotal > 0 {
+			checks = append(checks, hostCheck{
+				alertType: models.AlertHostInodes,
+				mount:     fs.Mountpoint,
+				value:     fs.InodesPercent,
+				threshold: m.config.HostInodeThreshold,
+				message:   fmt.Sprintf("inode usage of %s (%.1f%%) exceeds threshold (%.1f%%)", fs.Mountpoint, fs.InodesPercent, m.config.HostInodeThreshold),
+				resolved:  fmt.Sprintf("inode usage of %s is back to %.1f%%", fs.Mountpoint, fs.InodesPercent),
+			})
+		}
+	}
+
+	if m.config.HostMemoryThreshold > 0 {
+		checks = append(checks, hostCheck{
+			alertType: models.AlertHostMemory,
+			value:     metrics.Memory.Percent,
+			threshold: m.config.HostMemoryThreshold,
+			message:   fmt.Sprintf("memory usage (%.1f%%) exceeds threshold (%.1f%%)", metrics.Memory.Percent, m.config.HostMemoryThreshold),
+			resolved:  fmt.Sprintf("memory usage is back to %.1f%%", metrics.Memory.Percent),
+		})
+	}
+
+	if m.config.HostSwapThreshold > 0 && metrics.Swap.Total > 0 {
+		checks = append(checks, hostCheck{
+			alertType: models.AlertHostSwap,
+			value:     metrics.Swap.Percent,
+			threshold: m.config.HostSwapThreshold,
+			message:   fmt.Sprintf("swap usage (%.1f%%) exceeds threshold (%.1f%%)", metrics.Swap.Percent, m.config.HostSwapThreshold),
+			resolved:  fmt.Sprintf("swap usage is back to %.1f%%", metrics.Swap.Percent),
+		})
+	}
+
+	if m.config.HostLoadThreshold > 0 {
+		checks = append(checks, hostCheck{
+			alertType: models.AlertHostLoad,
+			value:     metrics.Load.Load1PerCore,
+			threshold: m.config.HostLoadThreshold,
+			message:   fmt.Sprintf("load average per core (%.2f) exceeds threshold (%.2f)", metrics.Load.Load1PerCore, m.config.HostLoadThreshold),
+			resolved:  fmt.Sprintf("load average per core is back to %.2f", metrics.Load.Load1PerCore),
+		})
+	}
+
+	return checks
+}
+
+// evaluateHostChecks raises an alert when a check first exceeds its threshold
+// and resolves it once the value drops back below. Unlike container
+// thresholds, a full disk is reported once rather than on every check.
+func (m *Monitor) evaluateHostChecks(hostName string, checks []hostCheck) {
+	m.statesMu.Lock()
+	defer m.statesMu.Unlock()
+
+	now := time.Now().Unix()
+	checked := make(map[string]struct{}, len(checks))
+	for _, check := range checks {
+		key := fmt.Sprintf("%s:%s:%s", hostName, check.alertType, check.mount)
+		checked[key] = struct{}{}
+		existing, active := m.hostAlerts[key]
+
+		switch {
+		case !active && check.value > check.threshold:
+			alert := models.Alert{
+				ID:        uuid.New().String(),
+				Type:      check.alertType,
+				Host:      hostName,
+				Mount:     check.mount,
+				Message:   fmt.Sprintf("Host %s %s", hostName, check.message),
+				Value:     check.value,
+				Threshold: check.threshold,
+				Timestamp: now,
+			}
+			m.hostAlerts[key] = activeHostAlert{id: alert.ID, host: hostName, alertType: check.alertType, mount: check.mount}
+			m.triggerAlert(alert)
+		case active && check.value <= check.threshold:
+			m.history.Acknowledge(existing.id)
+			delete(m.hostAlerts, key)
+			m.triggerAlert(models.Alert{
+				ID:        uuid.New().String(),
+				Type:      models.AlertHostResolved,
+				Host:      hostName,
+				Mount:     check.mount,
+				Message:   fmt.Sprintf("Host %s %s", hostName, check.resolved),
+				Value:     check.value,
+				Threshold: check.threshold,
+				Timestamp: now,
+			})
+		}
+	}
+
+	// Resolve alerts of mounts that disappeared or checks that were disabled
+	for key, active := range m.hostAlerts {
+		if _, ok := checked[key]; !ok && active.host == hostName {
+			m.resolveVanishedHostAlert(key, active)
+		}
+	}
+}
+
+// resolveVanishedHostAlert resolves an active host alert whose check is no
+// longer evaluated. Callers must hold statesMu.
+func (m *Monitor) resolveVanishedHostAlert(key string, active activeHostAlert) {
+	m.history.Acknowledge(active.id)
+	delete(m.hostAlerts, key)
+
+	subject := string(active.alertType)
+	if active.mount != "" {
+		subject += " of " + active.mount
+	}
+	m.triggerAlert(models.Alert{
+		ID:        uuid.New().String(),
+		Type:      models.AlertHostResolved,
+		Host:      active.host,
+		Mount:     active.mount,
+		Message:   fmt.Sprintf("Host %s no longer reports %s", active.host, subject),
+		Timestamp: time.Now().Unix(),
+	})
+}
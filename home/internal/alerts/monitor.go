@@ -13,30 +13,46 @@ import (
 	"github.com/hhftechnology/vps-monitor/internal/docker"
 	"github.com/hhftechnology/vps-monitor/internal/models"
 	"github.com/hhftechnology/vps-monitor/internal/stats"
+	"github.com/hhftechnology/vps-monitor/internal/system"
 )
 
 // Monitor handles background monitoring and alerting
 type Monitor struct {
-	docker       *docker.MultiHostClient
-	config       *config.AlertConfig
-	history      *AlertHistory
-	statsHistory *stats.HistoryManager
-	stopCh       chan struct{}
-	wg           sync.WaitGroup
+	docker  *docker.MultiHostClient
+	config  *config.AlertConfig
+	history *AlertHistory
+	sampler *stats.ContainerSampler // Source of the container stats checked against thresholds
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
 
 	containerStates map[string]string
+	containerHealth map[string]string
+	unhealthy       map[string]models.Alert // Active unhealthy alert by container
+	restarts        map[string]*restartTracker
+	hostAlerts      map[string]activeHostAlert // Active host alerts by host, type and mount
+	helperChecked   map[string]time.Time       // Last helper check by host
+	uncheckedLogged map[string]struct{}        // Hosts whose unavailable checks were logged
 	statesMu        sync.RWMutex
+
+	hostCollector *system.MetricsCollector
 }
 
 // NewMonitor creates a new alert monitor
-func NewMonitor(dockerClient *docker.MultiHostClient, alertConfig *config.AlertConfig) *Monitor {
+func NewMonitor(dockerClient *docker.MultiHostClient, alertConfig *config.AlertConfig, sampler *stats.ContainerSampler) *Monitor {
 	return &Monitor{
 		docker:          dockerClient,
 		config:          alertConfig,
 		history:         NewAlertHistory(100),
-		statsHistory:    stats.NewHistoryManager(),
+		sampler:         sampler,
 		stopCh:          make(chan struct{}),
 		containerStates: make(map[string]string),
+		containerHealth: make(map[string]string),
+		unhealthy:       make(map[string]models.Alert),
+		restarts:        make(map[string]*restartTracker),
+		hostAlerts:      make(map[string]activeHostAlert),
+		helperChecked:   make(map[string]time.Time),
+		uncheckedLogged: make(map[string]struct{}),
+		hostCollector:   system.NewMetricsCollector(),
 	}
 }
 
@@ -47,8 +63,10 @@ func (m *Monitor) Start() {
 		return
 	}
 
-	log.Printf("Starting alert monitor (interval: %s, CPU threshold: %.1f%%, Memory threshold: %.1f%%)",
-		m.config.CheckInterval, m.config.CPUThreshold, m.config.MemoryThreshold)
+	log.Printf("Starting alert monitor (interval: %s, CPU threshold: %.1f%%, Memory threshold: %.1f%%, crash loop: >%d restarts in %s)",
+		m.config.CheckInterval, m.config.CPUThreshold, m.config.MemoryThreshold, m.config.CrashLoopRestarts, m.config.CrashLoopWindow)
+	log.Printf("Host thresholds: disk %.1f%%, inodes %.1f%%, memory %.1f%%, swap %.1f%%, load per core %.2f",
+		m.config.HostDiskThreshold, m.config.HostInodeThreshold, m.config.HostMemoryThreshold, m.config.HostSwapThreshold, m.config.HostLoadThreshold)
 
 	m.wg.Add(1)
 	go m.monitorLoop()
@@ -66,10 +84,6 @@ func (m *Monitor) GetHistory() *AlertHistory {
 	return m.history
 }
 
-func (m *Monitor) GetStatsHistory() *stats.HistoryManager {
-	return m.statsHistory
-}
-
 // monitorLoop is the main monitoring loop
 func (m *Monitor) monitorLoop() {
 	defer m.wg.Done()
@@ -96,17 +110,24 @@ func (m *Monitor) checkAll() {
 	defer cancel()
 
 	m.checkContainerStates(ctx)
-	m.checkResourceThresholds(ctx)
+	m.checkResourceThresholds()
+	m.checkHostThresholds(ctx)
 }
 
 // checkContainerStates checks for container state changes
 func (m *Monitor) checkContainerStates(ctx context.Context) {
-	containersMap, _, err := m.docker.ListContainersAllHosts(ctx)
+	containersMap, hostErrors, err := m.docker.ListContainersAllHosts(ctx)
 	if err != nil {
 		log.Printf("Alert monitor: failed to list containers: %v", err)
 		return
 	}
 
+	// Containers of hosts that could not be listed are kept as they were
+	unreachable := make(map[string]struct{}, len(hostErrors))
+	for _, hostErr := range hostErrors {
+		unreachable[hostErr.HostName] = struct{}{}
+	}
+
 	m.statesMu.Lock()
 	defer m.statesMu.Unlock()
 
@@ -128,15 +149,7 @@ func (m *Monitor) checkContainerStates(ctx context.Context) {
 				if prevState != ctr.State {
 					// State changed
 					if ctr.State == "exited" || ctr.State == "dead" {
-						m.triggerAlert(models.Alert{
-							ID:            uuid.New().String(),
-							Type:          models.AlertContainerStopped,
-							ContainerID:   ctr.ID,
-							ContainerName: containerName,
-							Host:          hostName,
-							Message:       fmt.Sprintf("Container %s stopped (was: %s, now: %s)", containerName, prevState, ctr.State),
-							Timestamp:     time.Now().Unix(),
-						})
+						m.triggerStoppedAlert(ctx, hostName, ctr, containerName, prevState)
 					} else if ctr.State == "running" && (prevState == "exited" || prevState == "dead" || prevState == "created") {
 						m.triggerAlert(models.Alert{
 							ID:            uuid.New().String(),
@@ -152,71 +165,197 @@ func (m *Monitor) checkContainerStates(ctx context.Context) {
 			}
 
 			m.containerStates[key] = ctr.State
+
+			// Check if health changed. A container first seen unhealthy counts
+			// as a change, so one that was already failing still alerts.
+			if prevHealth := m.containerHealth[key]; prevHealth != ctr.Health {
+				m.checkHealthTransition(ctx, hostName, ctr, containerName, key)
+			}
+
+			m.containerHealth[key] = ctr.Health
+
+			m.checkRestarts(hostName, ctr, containerName, key)
 		}
 	}
 
 	// Clean up containers that no longer exist
 	for key := range m.containerStates {
+		hostName, _, _ := strings.Cut(key, ":")
+		if _, down := unreachable[hostName]; down {
+			continue
+		}
 		if _, exists := currentContainers[key]; !exists {
 			delete(m.containerStates, key)
+			delete(m.containerHealth, key)
+			if alert, ok := m.unhealthy[key]; ok {
+				m.history.Acknowledge(alert.ID)
+				delete(m.unhealthy, key)
+				m.triggerAlert(models.Alert{
+					ID:            uuid.New().String(),
+					Type:          models.AlertContainerRecovered,
+					ContainerID:   alert.ContainerID,
+					ContainerName: alert.ContainerName,
+					Host:          alert.Host,
+					Message:       fmt.Sprintf("Container %s was removed while unhealthy", alert.ContainerName),
+					Timestamp:     time.Now().Unix(),
+				})
+			}
+			if tracker, ok := m.restarts[key]; ok && tracker.alert != nil {
+				m.history.Acknowledge(tracker.alert.ID)
+				m.triggerAlert(models.Alert{
+					ID:            uuid.New().String(),
+					Type:          models.AlertCrashLoopResolved,
+					ContainerID:   tracker.alert.ContainerID,
+					ContainerName: tracker.alert.ContainerName,
+					Host:          tracker.alert.Host,
+					Message:       fmt.Sprintf("Container %s was removed", tracker.alert.ContainerName),
+					Timestamp:     time.Now().Unix(),
+				})
+			}
+			delete(m.restarts, key)
 		}
 	}
 }
 
-// checkResourceThresholds checks CPU and memory thresholds
-func (m *Monitor) checkResourceThresholds(ctx context.Context) {
-	containersMap, _, err := m.docker.ListContainersAllHosts(ctx)
+// triggerStoppedAlert raises an alert for a stopped container including the
+// exit code, OOM flag and last log lines, so the cause is visible from the
+// notification
+func (m *Monitor) triggerStoppedAlert(ctx context.Context, hostName string, ctr models.ContainerInfo, containerName, prevState string) {
+	alert := models.Alert{
+		ID:            uuid.New().String(),
+		Type:          models.AlertContainerStopped,
+		ContainerID:   ctr.ID,
+		ContainerName: containerName,
+		Host:          hostName,
+		Message:       fmt.Sprintf("Container %s stopped (was: %s, now: %s)", containerName, prevState, ctr.State),
+		Timestamp:     time.Now().Unix(),
+	}
+
+	diagnostics, err := m.docker.GetExitDiagnostics(ctx, hostName, ctr.ID, m.config.ExitLogLines)
 	if err != nil {
-		return
+		log.Printf("Alert monitor: failed to get exit diagnostics for %s: %v", containerName, err)
+	}
+	if diagnostics != nil {
+		alert.Exit = diagnostics
+		alert.Value = float64(diagnostics.ExitCode)
+		alert.Message = fmt.Sprintf("Container %s stopped with exit code %d (was: %s, now: %s)", containerName, diagnostics.ExitCode, prevState, ctr.State)
+		if diagnostics.OOMKilled {
+			alert.Type = models.AlertContainerOOMKilled
+			alert.Message = fmt.Sprintf("Container %s was killed by the OOM killer (exit code %d)", containerName, diagnostics.ExitCode)
+		}
+		if diagnostics.Error != "" {
+			alert.Message += ": " + diagnostics.Error
+		}
 	}
 
-	for hostName, containers := range containersMap {
-		for _, ctr := range containers {
-			if ctr.State != "running" {
-				continue
-			}
+	m.triggerAlert(alert)
+}
 
-			stats, err := m.docker.GetContainerStatsOnce(ctx, hostName, ctr.ID)
-			if err != nil {
-				continue
+// checkHealthTransition raises alerts when a container becomes unhealthy or
+// recovers from being unhealthy. Callers must hold statesMu.
+func (m *Monitor) checkHealthTransition(ctx context.Context, hostName string, ctr models.ContainerInfo, containerName, key string) {
+	active, isActive := m.unhealthy[key]
+
+	switch {
+	case ctr.Health == "unhealthy" && !isActive:
+		// The container list only carries the status, so the failing streak
+		// and probe output are inspected once per transition
+		message := fmt.Sprintf("Container %s is unhealthy", containerName)
+		var failingStreak int
+		health, err := m.docker.GetContainerHealth(ctx, hostName, ctr.ID)
+		if err != nil {
+			log.Printf("Alert monitor: failed to get health of %s: %v", containerName, err)
+		}
+		if health != nil {
+			failingStreak = health.FailingStreak
+			message = fmt.Sprintf("Container %s is unhealthy (failing streak: %d)", containerName, failingStreak)
+			if output := lastProbeOutput(health); output != "" {
+				message += ": " + output
 			}
+		}
 
-			m.statsHistory.RecordStats(ctr.ID, *stats)
+		alert := models.Alert{
+			ID:            uuid.New().String(),
+			Type:          models.AlertContainerUnhealthy,
+			ContainerID:   ctr.ID,
+			ContainerName: containerName,
+			Host:          hostName,
+			Message:       message,
+			Value:         float64(failingStreak),
+			Timestamp:     time.Now().Unix(),
+		}
+		m.unhealthy[key] = alert
+		m.triggerAlert(alert)
+	case ctr.Health == "healthy" && isActive:
+		// A restart in between passes through "starting", so recovery is
+		// judged by the active alert rather than the previous status
+		m.history.Acknowledge(active.ID)
+		delete(m.unhealthy, key)
+		m.triggerAlert(models.Alert{
+			ID:            uuid.New().String(),
+			Type:          models.AlertContainerRecovered,
+			ContainerID:   ctr.ID,
+			ContainerName: containerName,
+			Host:          hostName,
+			Message:       fmt.Sprintf("Container %s is healthy again", containerName),
+			Timestamp:     time.Now().Unix(),
+		})
+	}
+}
 
-			containerName := ctr.ID[:12]
-			if len(ctr.Names) > 0 {
-				containerName = strings.TrimPrefix(ctr.Names[0], "/")
-			}
+// lastProbeOutput returns the output of the most recent healthcheck probe
+func lastProbeOutput(health *models.ContainerHealth) string {
+	if len(health.Probes) == 0 {
+		return ""
+	}
 
-			// Check CPU threshold
-			if stats.CPUPercent > m.config.CPUThreshold {
-				m.triggerAlert(models.Alert{
-					ID:            uuid.New().String(),
-					Type:          models.AlertCPUThreshold,
-					ContainerID:   ctr.ID,
-					ContainerName: containerName,
-					Host:          hostName,
-					Message:       fmt.Sprintf("Container %s CPU usage (%.1f%%) exceeds threshold (%.1f%%)", containerName, stats.CPUPercent, m.config.CPUThreshold),
-					Value:         stats.CPUPercent,
-					Threshold:     m.config.CPUThreshold,
-					Timestamp:     time.Now().Unix(),
-				})
-			}
+	output := health.Probes[len(health.Probes)-1].Output
+	const maxOutput = 200
+	if len(output) > maxOutput {
+		output = output[:maxOutput] + "..."
+	}
+	return output
+}
 
-			// Check memory threshold
-			if stats.MemoryPercent > m.config.MemoryThreshold {
-				m.triggerAlert(models.Alert{
-					ID:            uuid.New().String(),
-					Type:          models.AlertMemoryThreshold,
-					ContainerID:   ctr.ID,
-					ContainerName: containerName,
-					Host:          hostName,
-					Message:       fmt.Sprintf("Container %s memory usage (%.1f%%) exceeds threshold (%.1f%%)", containerName, stats.MemoryPercent, m.config.MemoryThreshold),
-					Value:         stats.MemoryPercent,
-					Threshold:     m.config.MemoryThreshold,
-					Timestamp:     time.Now().Unix(),
-				})
-			}
+// checkResourceThresholds checks the latest container stats against the CPU
+// and memory thresholds
+func (m *Monitor) checkResourceThresholds() {
+	for _, sample := range m.sampler.Latest() {
+		hostName, ctr, stats := sample.Host, sample.Container, sample.Stats
+
+		containerName := ctr.ID[:12]
+		if len(ctr.Names) > 0 {
+			containerName = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+
+		// Check CPU threshold
+		if stats.CPUPercent > m.config.CPUThreshold {
+			m.triggerAlert(models.Alert{
+				ID:            uuid.New().String(),
+				Type:          models.AlertCPUThreshold,
+				ContainerID:   ctr.ID,
+				ContainerName: containerName,
+				Host:          hostName,
+				Message:       fmt.Sprintf("Container %s CPU usage (%.1f%%) exceeds threshold (%.1f%%)", containerName, stats.CPUPercent, m.config.CPUThreshold),
+				Value:         stats.CPUPercent,
+				Threshold:     m.config.CPUThreshold,
+				Timestamp:     time.Now().Unix(),
+			})
+		}
+
+		// Check memory threshold
+		if stats.MemoryPercent > m.config.MemoryThreshold {
+			m.triggerAlert(models.Alert{
+				ID:            uuid.New().String(),
+				Type:          models.AlertMemoryThreshold,
+				ContainerID:   ctr.ID,
+				ContainerName: containerName,
+				Host:          hostName,
+				Message:       fmt.Sprintf("Container %s memory usage (%.1f%%) exceeds threshold (%.1f%%)", containerName, stats.MemoryPercent, m.config.MemoryThreshold),
+				Value:         stats.MemoryPercent,
+				Threshold:     m.config.MemoryThreshold,
+				Timestamp:     time.Now().Unix(),
+			})
 		}
 	}
 }
@@ -247,5 +386,7 @@ func (m *Monitor) triggerAlert(alert models.Alert) {
 }
 
 func isCriticalAlert(alert models.Alert) bool {
-	return alert.Type == models.AlertContainerStopped
+	return alert.Type == models.AlertContainerStopped || alert.Type == models.AlertContainerUnhealthy ||
+		alert.Type == models.AlertCrashLoop || alert.Type == models.AlertContainerOOMKilled ||
+		alert.Type == models.AlertHostDisk || alert.Type == models.AlertHostInodes
 }
@@ -0,0 +1,103 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// restartTracker records the recent restarts of a single container
+type restartTracker struct {
+	lastStart time.Time
+	restarts  []time.Time
+	alert     *models.Alert // Set while a crash loop alert is active
+}
+
+// prune drops restarts that fell out of the window
+func (t *restartTracker) prune(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	kept := t.restarts[:0]
+	for _, ts := range t.restarts {
+		if ts.After(cutoff) {
+			kept = append(kept, ts)
+		}
+	}
+	t.restarts = kept
+}
+
+func (t *restartTracker) history() []int64 {
+	history := make([]int64, len(t.restarts))
+	for i, ts := range t.restarts {
+		history[i] = ts.Unix()
+	}
+	return history
+}
+
+// checkRestarts tracks container restarts and raises an alert when a
+// container restarts more often than allowed within the crash loop window.
+// Each start of a known container counts as a restart, timestamped with the
+// time it started, as read from the event log by the container sampler. The
+// alert is resolved once the container has not restarted for a full window.
+// Callers must hold statesMu.
+func (m *Monitor) checkRestarts(hostName string, ctr models.ContainerInfo, containerName, key string) {
+	if ctr.State == "created" {
+		return
+	}
+
+	now := time.Now()
+	starts := m.sampler.Starts(hostName, ctr.ID)
+	tracker, exists := m.restarts[key]
+	if !exists {
+		// First sighting, only record the baseline
+		tracker = &restartTracker{}
+		m.restarts[key] = tracker
+		if len(starts) > 0 {
+			tracker.lastStart = starts[len(starts)-1]
+		}
+		return
+	}
+
+	for _, startedAt := range starts {
+		if startedAt.After(tracker.lastStart) {
+			tracker.restarts = append(tracker.restarts, startedAt)
+			tracker.lastStart = startedAt
+		}
+	}
+	tracker.prune(now, m.config.CrashLoopWindow)
+
+	switch {
+	case tracker.alert == nil && len(tracker.restarts) > m.config.CrashLoopRestarts:
+		// The restart policy's count, as of the sampler's latest round
+		restartCount, _ := m.sampler.RestartCount(hostName, ctr.ID)
+
+		alert := models.Alert{
+			ID:             uuid.New().String(),
+			Type:           models.AlertCrashLoop,
+			ContainerID:    ctr.ID,
+			ContainerName:  containerName,
+			Host:           hostName,
+			Message:        fmt.Sprintf("Container %s restarted %d times in the last %s", containerName, len(tracker.restarts), m.config.CrashLoopWindow),
+			Value:          float64(len(tracker.restarts)),
+			Threshold:      float64(m.config.CrashLoopRestarts),
+			Timestamp:      now.Unix(),
+			RestartCount:   restartCount,
+			RestartHistory: tracker.history(),
+		}
+		tracker.alert = &alert
+		m.triggerAlert(alert)
+	case tracker.alert != nil && len(tracker.restarts) == 0:
+		m.history.Acknowledge(tracker.alert.ID)
+		tracker.alert = nil
+		m.triggerAlert(models.Alert{
+			ID:            uuid.New().String(),
+			Type:          models.AlertCrashLoopResolved,
+			ContainerID:   ctr.ID,
+			ContainerName: containerName,
+			Host:          hostName,
+			Message:       fmt.Sprintf("Container %s has not restarted in the last %s", containerName, m.config.CrashLoopWindow),
+			Timestamp:     now.Unix(),
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
+)
+
+// ListContainerFiles lists a directory inside a container
+func (ar *APIRouter) ListContainerFiles(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+	dirPath := r.URL.Query().Get("path")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	// Listing reads the archive of the whole subtree, so the root is never
+	// listed implicitly
+	if dirPath == "" {
+		http.Error(w, "path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	listing, err := ar.docker.ListContainerDirectory(r.Context(), host, id, dirPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"listing": listing,
+	})
+}
+
+// StatContainerFile returns information about a single path inside a container
+func (ar *APIRouter) StatContainerFile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+	filePath := r.URL.Query().Get("path")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if filePath == "" {
+		http.Error(w, "path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := ar.docker.StatContainerPath(r.Context(), host, id, filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"file": entry,
+	})
+}
+
+// DownloadContainerFile downloads a file or directory from a container.
+// Supported formats are "raw" (regular files only), "tar" and "zip"; files
+// default to raw and directories to tar.
+func (ar *APIRouter) DownloadContainerFile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+	filePath := r.URL.Query().Get("path")
+	format := r.URL.Query().Get("format")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if filePath == "" {
+		http.Error(w, "path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	switch format {
+	case "", "raw", "tar", "zip":
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	reader, entry, err := ar.docker.CopyFromContainer(r.Context(), host, id, filePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	if format == "" {
+		format = "raw"
+		if entry.Type == "dir" {
+			format = "tar"
+		}
+	}
+
+	if format == "raw" && entry.Type != "file" {
+		http.Error(w, "raw download is only supported for regular files", http.StatusBadRequest)
+		return
+	}
+
+	name := path.Base(entry.Path)
+	if name == "/" {
+		name = "root"
+	}
+
+	switch format {
+	case "raw":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		err = docker.ExtractFirstFile(w, reader)
+	case "zip":
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".zip"))
+		err = docker.TarToZip(w, reader)
+	default:
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar"))
+		_, err = io.Copy(w, reader)
+	}
+
+	if err != nil {
+		log.Printf("Failed to download %s from container %s on host %s: %v", filePath, id, host, err)
+	}
+}
+
+// UploadContainerFile writes the request body to a file inside a container.
+// The body must declare its length and stay within the configured upload limit.
+func (ar *APIRouter) UploadContainerFile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+	filePath := r.URL.Query().Get("path")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if filePath == "" {
+		http.Error(w, "path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.ContentLength < 0 {
+		http.Error(w, "Content-Length header is required", http.StatusLengthRequired)
+		return
+	}
+
+	if r.ContentLength > ar.config.UploadMaxSize {
+		http.Error(w, fmt.Sprintf("file exceeds upload limit of %d bytes", ar.config.UploadMaxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, ar.config.UploadMaxSize)
+
+	if err := ar.docker.CopyFileToContainer(r.Context(), host, id, filePath, body, r.ContentLength); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to upload file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"message": "File uploaded",
+		"path":    path.Clean("/" + filePath),
+		"size":    r.ContentLength,
+	})
+}
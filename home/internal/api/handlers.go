@@ -3,14 +3,19 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hhftechnology/vps-monitor/internal/auth"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
 	"github.com/hhftechnology/vps-monitor/internal/models"
 	"github.com/hhftechnology/vps-monitor/internal/system"
 )
@@ -18,7 +23,67 @@ import (
 // Pre-compiled regex for validating environment variable keys (performance optimization)
 var envKeyRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
+// psArgsRegex restricts ps arguments for container top to plain options
+var psArgsRegex = regexp.MustCompile(`^[a-zA-Z0-9 ,=_-]*$`)
+
+// resolveHosts expands a comma-separated host list, or "all", into configured
+// host names
+func (ar *APIRouter) resolveHosts(raw string) ([]string, error) {
+	configured := ar.docker.GetHosts()
+
+	if strings.TrimSpace(raw) == "all" {
+		hosts := make([]string, 0, len(configured))
+		for _, host := range configured {
+			hosts = append(hosts, host.Name)
+		}
+		return hosts, nil
+	}
+
+	known := make(map[string]struct{}, len(configured))
+	for _, host := range configured {
+		known[host.Name] = struct{}{}
+	}
+
+	var hosts []string
+	seen := make(map[string]struct{})
+	for name := range strings.SplitSeq(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("host %s not found", name)
+		}
+		if _, dup := seen[name]; dup {
+			continue
+		}
+		seen[name] = struct{}{}
+		hosts = append(hosts, name)
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts specified")
+	}
+
+	return hosts, nil
+}
+
+// GetSystemStats returns host-level stats of the machine running vps-monitor,
+// or of a configured Docker host when the host parameter is set
 func (ar *APIRouter) GetSystemStats(w http.ResponseWriter, r *http.Request) {
+	// This route is public, but stats of a Docker host may start a helper
+	// container, so they require authentication like the hosts route
+	if host := r.URL.Query().Get("host"); host != "" {
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ar.serveHostSystemStats(w, r, host)
+		})
+		if ar.authService != nil {
+			handler = auth.Middleware(ar.authService)(handler)
+		}
+		handler.ServeHTTP(w, r)
+		return
+	}
+
 	ctx := r.Context()
 	stats, err := system.GetStats(ctx)
 	if err != nil {
@@ -26,6 +91,11 @@ func (ar *APIRouter) GetSystemStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Prefer the sampler's CPU usage, which covers a full sampling interval
+	if latest := ar.latestHostMetrics(); latest != nil {
+		stats.Usage.CPUPercent = latest.CPU.Percent
+	}
+
 	// Override hostname if configured
 	if ar.config.Hostname != "" {
 		stats.HostInfo.Hostname = ar.config.Hostname
@@ -34,10 +104,172 @@ func (ar *APIRouter) GetSystemStats(w http.ResponseWriter, r *http.Request) {
 	WriteJsonResponse(w, http.StatusOK, stats)
 }
 
+// latestHostMetrics returns the sampler's latest metrics, or nil when there is
+// no sampler or the sample is stale
+func (ar *APIRouter) latestHostMetrics() *system.HostMetrics {
+	if ar.hostSampler == nil {
+		return nil
+	}
+	latest := ar.hostSampler.Latest()
+	if latest == nil || time.Since(time.Unix(latest.Timestamp, 0)) > 2*ar.hostSampler.Interval() {
+		return nil
+	}
+	return latest
+}
+
+// GetHostSystemStats returns stats of a Docker host collected by its agent or
+// a helper container. The helper is not started in read-only mode.
+func (ar *APIRouter) GetHostSystemStats(w http.ResponseWriter, r *http.Request) {
+	ar.serveHostSystemStats(w, r, chi.URLParam(r, "name"))
+}
+
+func (ar *APIRouter) serveHostSystemStats(w http.ResponseWriter, r *http.Request, host string) {
+	if _, err := ar.docker.GetClient(host); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Allow time for pulling the helper image on first use
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	helperImage := ar.config.HostStatsImage
+	if ar.config.ReadOnly {
+		helperImage = ""
+	}
+
+	stats, err := ar.docker.GetHostSystemStats(ctx, host, helperImage)
+	if errors.Is(err, docker.ErrStatsHelperDisabled) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, stats)
+}
+
+// GetSystemMetrics returns detailed metrics of the machine running
+// vps-monitor, or of an agent host when the host parameter is set
+func (ar *APIRouter) GetSystemMetrics(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	if host := r.URL.Query().Get("host"); host != "" {
+		if _, err := ar.docker.GetClient(host); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		metrics, err := ar.docker.GetHostMetrics(ctx, host)
+		if errors.Is(err, docker.ErrNotAgentHost) {
+			http.Error(w, "detailed metrics are only available for the local machine and agent hosts", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		WriteJsonResponse(w, http.StatusOK, metrics)
+		return
+	}
+
+	if latest := ar.latestHostMetrics(); latest != nil {
+		WriteJsonResponse(w, http.StatusOK, latest)
+		return
+	}
+
+	metrics, err := system.GetMetrics(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, metrics)
+}
+
+// GetSystemProcesses returns the top processes of the machine running
+// vps-monitor, or of an agent host when the host parameter is set
+func (ar *APIRouter) GetSystemProcesses(w http.ResponseWriter, r *http.Request) {
+	sortBy, limit, err := parseProcessQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	if host := r.URL.Query().Get("host"); host != "" {
+		if _, err := ar.docker.GetClient(host); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		query := url.Values{"sort": {string(sortBy)}, "limit": {strconv.Itoa(limit)}}
+		processes, err := ar.docker.GetHostProcesses(ctx, host, query)
+		if errors.Is(err, docker.ErrNotAgentHost) {
+			http.Error(w, "processes are only available for the local machine and agent hosts", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		WriteJsonResponse(w, http.StatusOK, map[string]any{"processes": processes})
+		return
+	}
+
+	processes, err := system.GetTopProcesses(ctx, sortBy, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{"processes": processes})
+}
+
+// parseProcessQuery reads the sort order and number of processes to return
+func parseProcessQuery(query url.Values) (system.ProcessSort, int, error) {
+	sortBy := system.ProcessSortCPU
+	if value := query.Get("sort"); value != "" {
+		sortBy = system.ProcessSort(value)
+		if sortBy != system.ProcessSortCPU && sortBy != system.ProcessSortMemory {
+			return "", 0, fmt.Errorf("invalid sort: %s (expected cpu or memory)", value)
+		}
+	}
+
+	limit := 20
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > 500 {
+			return "", 0, fmt.Errorf("invalid limit: %s (expected 1-500)", value)
+		}
+		limit = n
+	}
+
+	return sortBy, limit, nil
+}
+
 func (ar *APIRouter) GetContainers(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	query, err := parseListQuery(r.URL.Query(), containerSortFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Computing sizes walks every container's filesystem, so allow more time
+	withSize, _ := strconv.ParseBool(r.URL.Query().Get("size"))
+	timeout := 10 * time.Second
+	if withSize {
+		timeout = 60 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	containersMap, hostErrors, err := ar.docker.ListContainersAllHosts(ctx)
+	containersMap, hostErrors, err := ar.docker.ListContainersAllHostsWithSize(ctx, withSize)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -55,9 +287,11 @@ func (ar *APIRouter) GetContainers(w http.ResponseWriter, r *http.Request) {
 		allContainers = append(allContainers, containers...)
 	}
 
-	// Add historical stats if alert monitor is available
-	if ar.alertMonitor != nil {
-		statsHistory := ar.alertMonitor.GetStatsHistory()
+	allContainers, total, nextCursor := applyListQuery(allContainers, query, containerListFields)
+
+	// Add historical stats if container stats are recorded
+	if ar.containers != nil {
+		statsHistory := ar.containers.History()
 		for i := range allContainers {
 			cpu1h, mem1h, has1h := statsHistory.Get1hAverages(allContainers[i].ID)
 			cpu12h, mem12h, has12h := statsHistory.Get12hAverages(allContainers[i].ID)
@@ -74,9 +308,11 @@ func (ar *APIRouter) GetContainers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	WriteJsonResponse(w, http.StatusOK, map[string]any{
-		"containers": allContainers,
-		"hosts":      ar.docker.GetHosts(),
-		"readOnly":   ar.config.ReadOnly,
+		"containers":  allContainers,
+		"total":       total,
+		"next_cursor": nextCursor,
+		"hosts":       ar.docker.GetHosts(),
+		"readOnly":    ar.config.ReadOnly,
 	})
 }
 
@@ -96,6 +332,7 @@ func (ar *APIRouter) GetContainer(w http.ResponseWriter, r *http.Request) {
 	}
 	WriteJsonResponse(w, http.StatusOK, map[string]any{
 		"container": container,
+		"health":    docker.ContainerHealthFromState(container.State),
 	})
 }
 
@@ -349,12 +586,12 @@ func (ar *APIRouter) UpdateEnvVariables(w http.ResponseWriter, r *http.Request)
 func (ar *APIRouter) GetContainerHistoricalStats(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	if ar.alertMonitor == nil {
+	if ar.containers == nil {
 		http.Error(w, "Stats history not available", http.StatusServiceUnavailable)
 		return
 	}
 
-	statsHistory := ar.alertMonitor.GetStatsHistory()
+	statsHistory := ar.containers.History()
 	
 	cpu1h, mem1h, has1h := statsHistory.Get1hAverages(id)
 	cpu12h, mem12h, has12h := statsHistory.Get12hAverages(id)
@@ -371,3 +608,49 @@ func (ar *APIRouter) GetContainerHistoricalStats(w http.ResponseWriter, r *http.
 
 	WriteJsonResponse(w, http.StatusOK, response)
 }
+
+func (ar *APIRouter) GetContainerProcesses(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+	psArgs := r.URL.Query().Get("ps_args")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !psArgsRegex.MatchString(psArgs) {
+		http.Error(w, fmt.Sprintf("invalid ps_args: %s", psArgs), http.StatusBadRequest)
+		return
+	}
+
+	processes, err := ar.docker.GetContainerProcesses(r.Context(), host, id, psArgs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"processes": processes,
+	})
+}
+
+func (ar *APIRouter) GetContainerDiff(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := ar.docker.GetContainerDiff(r.Context(), host, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"diff": diff,
+	})
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hhftechnology/vps-monitor/internal/auth"
+	"github.com/hhftechnology/vps-monitor/internal/config"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+func TestParseProcessQuery(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantSort  system.ProcessSort
+		wantLimit int
+		wantErr   bool
+	}{
+		{"", system.ProcessSortCPU, 20, false},
+		{"sort=memory", system.ProcessSortMemory, 20, false},
+		{"sort=cpu&limit=5", system.ProcessSortCPU, 5, false},
+		{"limit=500", system.ProcessSortCPU, 500, false},
+		{"limit=1", system.ProcessSortCPU, 1, false},
+		{"sort=pid", "", 0, true},
+		{"sort=CPU", "", 0, true},
+		{"limit=0", "", 0, true},
+		{"limit=-1", "", 0, true},
+		{"limit=501", "", 0, true},
+		{"limit=ten", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sortBy, limit, err := parseProcessQuery(values)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseProcessQuery(%q) = %s, %d, want error", tt.query, sortBy, limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProcessQuery(%q) error = %v", tt.query, err)
+			}
+			if sortBy != tt.wantSort || limit != tt.wantLimit {
+				t.Errorf("parseProcessQuery(%q) = %s, %d, want %s, %d", tt.query, sortBy, limit, tt.wantSort, tt.wantLimit)
+			}
+		})
+	}
+}
+
+// TestSystemStatsOfHostRequiresAuth checks that the public stats route only
+// serves a Docker host to authenticated requests
+func TestSystemStatsOfHostRequiresAuth(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "hash")
+	authService, err := auth.NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiToken, err := authService.GenerateToken("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authService   *auth.Service
+		authorization string
+		want          int
+	}{
+		{"auth enabled without a token", authService, "", http.StatusUnauthorized},
+		{"auth enabled with a token", authService, "Bearer " + apiToken, http.StatusNotFound},
+		{"auth disabled", nil, "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &APIRouter{
+				authService: tt.authService,
+				config:      &config.Config{},
+				docker:      &docker.MultiHostClient{},
+			}
+			r := httptest.NewRequest("GET", "/api/v1/system/stats?host=missing", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			ar.GetSystemStats(w, r)
+			if w.Code != tt.want {
+				t.Errorf("GetSystemStats() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// GetHostInfo returns the Docker engine information of a host
+func (ar *APIRouter) GetHostInfo(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	if _, err := ar.docker.GetClient(name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	info, err := ar.docker.GetHostInfo(ctx, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"host": info,
+	})
+}
+
+// GetHostsInfo returns one summary row per host so engines can be compared
+// across the fleet. Unreachable hosts are listed with their error.
+func (ar *APIRouter) GetHostsInfo(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	infos, hostErrors := ar.docker.GetHostInfoAllHosts(ctx)
+
+	newest := ""
+	for _, info := range infos {
+		if compareVersions(info.EngineVersion, newest) > 0 {
+			newest = info.EngineVersion
+		}
+	}
+
+	rows := make([]models.HostSummary, 0, len(infos)+len(hostErrors))
+	for _, info := range infos {
+		rows = append(rows, models.HostSummary{
+			Name:              info.Name,
+			EngineVersion:     info.EngineVersion,
+			APIVersion:        info.APIVersion,
+			OperatingSystem:   info.OperatingSystem,
+			KernelVersion:     info.KernelVersion,
+			StorageDriver:     info.StorageDriver,
+			CgroupVersion:     info.CgroupVersion,
+			CPUs:              info.CPUs,
+			MemoryTotal:       info.MemoryTotal,
+			Containers:        info.Containers,
+			ContainersRunning: info.ContainersRunning,
+			Images:            info.Images,
+			Warnings:          len(info.Warnings),
+			Outdated:          compareVersions(info.EngineVersion, newest) < 0,
+		})
+	}
+	for _, hostErr := range hostErrors {
+		rows = append(rows, models.HostSummary{
+			Name:  hostErr.HostName,
+			Error: hostErr.Err.Error(),
+		})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Name < rows[j].Name
+	})
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"hosts":          rows,
+		"newest_version": newest,
+	})
+}
+
+// compareVersions compares dotted engine versions such as "28.5.2" or
+// "24.0.7-ce". Missing or non-numeric parts count as zero.
+func compareVersions(a, b string) int {
+	partsA := versionParts(a)
+	partsB := versionParts(b)
+
+	for i := range max(len(partsA), len(partsB)) {
+		var x, y int
+		if i < len(partsA) {
+			x = partsA[i]
+		}
+		if i < len(partsB) {
+			y = partsB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []int {
+	version, _, _ = strings.Cut(version, "-")
+	var parts []int
+	for part := range strings.SplitSeq(version, ".") {
+		n, _ := strconv.Atoi(part)
+		parts = append(parts, n)
+	}
+	return parts
+}
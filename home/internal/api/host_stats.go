@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxHistoryPoints caps the points of a history response when no step is given
+const maxHistoryPoints = 300
+
+// HandleHostStats streams host metrics samples over a WebSocket, starting with
+// the latest one
+func (ar *APIRouter) HandleHostStats(w http.ResponseWriter, r *http.Request) {
+	if ar.hostSampler == nil {
+		http.Error(w, "host metrics sampling is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("websocket upgrade failed for host stats: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	ctx := r.Context()
+
+	samples, unsubscribe := ar.hostSampler.Subscribe()
+	defer unsubscribe()
+
+	// Handle WebSocket close from client
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					log.Printf("host stats websocket closed unexpectedly: %v", err)
+				}
+				return
+			}
+		}
+	}()
+
+	writeSample := func(sample any) bool {
+		data, err := json.Marshal(sample)
+		if err != nil {
+			log.Printf("failed to marshal host stats: %v", err)
+			return true
+		}
+		if err := ws.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Printf("failed to write host stats to websocket: %v", err)
+			return false
+		}
+		return true
+	}
+
+	if latest := ar.hostSampler.Latest(); latest != nil {
+		if !writeSample(latest) {
+			return
+		}
+	}
+
+	for {
+		select {
+		case sample := <-samples:
+			if !writeSample(sample) {
+				return
+			}
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// GetHostStatsHistory returns sampled host metrics over a time range, for
+// charts. Ranges are given either as range=1h or as from/to, with an optional
+// step averaging samples into buckets.
+func (ar *APIRouter) GetHostStatsHistory(w http.ResponseWriter, r *http.Request) {
+	if ar.hostSampler == nil {
+		http.Error(w, "host metrics sampling is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	from, to, err := parseTimeRange(r.URL.Query(), time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	step, err := parseStep(r.URL.Query().Get("step"), to.Sub(from), ar.hostSampler.Interval())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"from":   from.Unix(),
+		"to":     to.Unix(),
+		"step":   int64(step.Seconds()),
+		"points": ar.hostSampler.Range(from, to, step),
+	})
+}
+
+// parseTimeRange reads range, or from and to, from the query. Times are Unix
+// seconds or RFC 3339; to defaults to now and the range to def.
+func parseTimeRange(query url.Values, def time.Duration) (time.Time, time.Time, error) {
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+		to = t
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, err := parseTime(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+		}
+		if !from.Before(to) {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+		}
+		return from, to, nil
+	}
+
+	span := def
+	if value := query.Get("range"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid range: %s", value)
+		}
+		span = d
+	}
+
+	return to.Add(-span), to, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseStep reads the bucket size of a range query. Without one, the step is
+// chosen so the response has at most maxHistoryPoints points.
+func parseStep(value string, span, minStep time.Duration) (time.Duration, error) {
+	if value != "" {
+		step, err := time.ParseDuration(value)
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step: %s", value)
+		}
+		return max(step, minStep), nil
+	}
+
+	step := (span / maxHistoryPoints).Round(time.Second)
+	return max(step, minStep), nil
+}
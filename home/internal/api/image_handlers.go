@@ -5,17 +5,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
 	"github.com/hhftechnology/vps-monitor/internal/models"
 )
 
 // GetImages lists all images across all Docker hosts
 func (ar *APIRouter) GetImages(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r.URL.Query(), imageSortFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
@@ -36,10 +45,14 @@ func (ar *APIRouter) GetImages(w http.ResponseWriter, r *http.Request) {
 		allImages = append(allImages, images...)
 	}
 
+	allImages, total, nextCursor := applyListQuery(allImages, query, imageListFields)
+
 	WriteJsonResponse(w, http.StatusOK, map[string]any{
-		"images":   allImages,
-		"hosts":    ar.docker.GetHosts(),
-		"readOnly": ar.config.ReadOnly,
+		"images":      allImages,
+		"total":       total,
+		"next_cursor": nextCursor,
+		"hosts":       ar.docker.GetHosts(),
+		"readOnly":    ar.config.ReadOnly,
 	})
 }
 
@@ -103,12 +116,16 @@ func (ar *APIRouter) RemoveImage(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// PullImage pulls an image and streams progress as NDJSON
+// defaultPullConcurrency bounds how many hosts pull an image at the same time
+const defaultPullConcurrency = 4
+
+// PullImage pulls an image on one or more hosts and streams progress as NDJSON.
+// The host parameter accepts a comma-separated list of hosts or "all".
 func (ar *APIRouter) PullImage(w http.ResponseWriter, r *http.Request) {
-	host := r.URL.Query().Get("host")
+	hostParam := r.URL.Query().Get("host")
 	imageName := r.URL.Query().Get("image")
 
-	if host == "" {
+	if hostParam == "" {
 		http.Error(w, "host parameter is required", http.StatusBadRequest)
 		return
 	}
@@ -118,7 +135,261 @@ func (ar *APIRouter) PullImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reader, err := ar.docker.PullImage(r.Context(), host, imageName)
+	hosts, err := ar.resolveHosts(hostParam)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	concurrency := defaultPullConcurrency
+	if concurrencyStr := r.URL.Query().Get("concurrency"); concurrencyStr != "" {
+		concurrency, err = strconv.Atoi(concurrencyStr)
+		if err != nil || concurrency <= 0 {
+			http.Error(w, "concurrency must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	progressCh, resultsCh := ar.docker.PullImageHosts(r.Context(), hosts, imageName, concurrency)
+
+	encoder := json.NewEncoder(w)
+	for progress := range progressCh {
+		if err := encoder.Encode(progress); err != nil {
+			// Keep draining so the pulls can observe the cancelled context
+			continue
+		}
+		flusher.Flush()
+	}
+
+	// Send completion message with the per-host outcome
+	_ = encoder.Encode(models.ImagePullSummary{
+		Status:  "complete",
+		Results: <-resultsCh,
+	})
+	flusher.Flush()
+}
+
+// CopyImage streams an image from a source host into one or more target hosts
+// without a registry. Progress is streamed as NDJSON.
+func (ar *APIRouter) CopyImage(w http.ResponseWriter, r *http.Request) {
+	sourceHost := r.URL.Query().Get("host")
+	imageName := r.URL.Query().Get("image")
+	targetsParam := r.URL.Query().Get("targets")
+
+	if sourceHost == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if imageName == "" {
+		http.Error(w, "image parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if targetsParam == "" {
+		http.Error(w, "targets parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := ar.docker.GetClient(sourceHost); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resolved, err := ar.resolveHosts(targetsParam)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	targets := make([]string, 0, len(resolved))
+	for _, target := range resolved {
+		if target == sourceHost {
+			if targetsParam == "all" {
+				continue
+			}
+			http.Error(w, "source host cannot be a target", http.StatusBadRequest)
+			return
+		}
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		http.Error(w, "no target hosts to copy to", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	progressCh, summaryCh := ar.docker.CopyImage(r.Context(), sourceHost, imageName, targets)
+
+	encoder := json.NewEncoder(w)
+	for progress := range progressCh {
+		if err := encoder.Encode(progress); err != nil {
+			continue
+		}
+		flusher.Flush()
+	}
+
+	_ = encoder.Encode(<-summaryCh)
+	flusher.Flush()
+}
+
+// SaveImage downloads an image as a tar archive for offline transfer
+func (ar *APIRouter) SaveImage(w http.ResponseWriter, r *http.Request) {
+	rawID := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+
+	// URL-decode the image ID (handles sha256%3A... -> sha256:...)
+	id, err := url.PathUnescape(rawID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid image ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := ar.docker.SaveImage(r.Context(), host, []string{id})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", imageArchiveName(id)))
+
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("Failed to stream image %s from host %s: %v", id, host, err)
+	}
+}
+
+// LoadImage uploads an image tar archive to a host and streams the load
+// output as NDJSON
+func (ar *APIRouter) LoadImage(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	body, err := ar.docker.LoadImage(r.Context(), host, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	encoder := json.NewEncoder(w)
+	images, err := docker.DecodeLoadResponse(body, func(message string) {
+		_ = encoder.Encode(models.ImageTransferProgress{
+			Status:  "loading",
+			Host:    host,
+			Message: message,
+		})
+		flusher.Flush()
+	})
+
+	result := models.ImageTransferResult{Host: host, Images: images, Success: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	_ = encoder.Encode(models.ImageTransferSummary{
+		Status:  "complete",
+		Results: []models.ImageTransferResult{result},
+	})
+	flusher.Flush()
+}
+
+// imageArchiveName builds a safe download file name for an image reference
+func imageArchiveName(ref string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, ref)
+	return name + ".tar"
+}
+
+// BuildImage builds an image from an uploaded tar build context and streams
+// the build output as NDJSON
+func (ar *APIRouter) BuildImage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	host := query.Get("host")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	options := models.ImageBuildOptions{
+		Dockerfile: query.Get("dockerfile"),
+		Target:     query.Get("target"),
+		BuildArgs:  make(map[string]string),
+	}
+
+	for _, tag := range query["tag"] {
+		for t := range strings.SplitSeq(tag, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				options.Tags = append(options.Tags, t)
+			}
+		}
+	}
+
+	for _, arg := range query["buildarg"] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || !envKeyRegex.MatchString(key) {
+			http.Error(w, fmt.Sprintf("invalid build argument: %s (expected KEY=VALUE)", arg), http.StatusBadRequest)
+			return
+		}
+		options.BuildArgs[key] = value
+	}
+
+	if noCache := query.Get("nocache"); noCache != "" {
+		options.NoCache, _ = strconv.ParseBool(noCache)
+	}
+
+	if pull := query.Get("pull"); pull != "" {
+		options.PullParent, _ = strconv.ParseBool(pull)
+	}
+
+	reader, err := ar.docker.BuildImage(r.Context(), host, r.Body, options)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -138,20 +409,25 @@ func (ar *APIRouter) PullImage(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(reader)
 	encoder := json.NewEncoder(w)
 
+	var imageID string
 	for {
-		var progress models.ImagePullProgress
+		var progress models.ImageBuildProgress
 		if err := decoder.Decode(&progress); err != nil {
 			if err == io.EOF {
 				break
 			}
 			// Send error in stream
-			_ = encoder.Encode(models.ImagePullProgress{
+			_ = encoder.Encode(models.ImageBuildProgress{
 				Status: "error",
 				Error:  err.Error(),
 			})
 			break
 		}
 
+		if progress.Aux != nil && progress.Aux.ID != "" {
+			imageID = progress.Aux.ID
+		}
+
 		if err := encoder.Encode(progress); err != nil {
 			break
 		}
@@ -159,8 +435,9 @@ func (ar *APIRouter) PullImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send completion message
-	_ = encoder.Encode(models.ImagePullProgress{
-		Status: "complete",
+	_ = encoder.Encode(models.ImageBuildProgress{
+		Status:  "complete",
+		ImageID: imageID,
 	})
 	flusher.Flush()
 }
@@ -0,0 +1,374 @@
+package api
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// composeProjectLabel is set by Docker Compose on the resources of a project
+const composeProjectLabel = "com.docker.compose.project"
+
+// maxListLimit caps the page size of list endpoints
+const maxListLimit = 1000
+
+// listQuery holds the filters, sorting and pagination shared by the
+// container, image and network list endpoints
+type listQuery struct {
+	hosts   map[string]struct{}
+	states  map[string]struct{}
+	name    textMatcher
+	image   textMatcher
+	labels  []labelSelector
+	project string
+	sort    string
+	desc    bool
+	limit   int
+	offset  int
+}
+
+// textMatcher matches a case-insensitive substring, or a regular expression
+// when the value is wrapped in slashes (e.g. /^web-\d+$/)
+type textMatcher struct {
+	substring string
+	regex     *regexp.Regexp
+}
+
+// labelSelector matches one term of a label selector: key=value, key!=value
+// or key (label present)
+type labelSelector struct {
+	key   string
+	value string
+	op    string // "=", "!=" or "exists"
+}
+
+// listFields describes how a list query reads an item of type T
+type listFields[T any] struct {
+	id     func(T) string
+	host   func(T) string
+	names  func(T) []string
+	image  func(T) string
+	state  func(T) string
+	labels func(T) map[string]string
+	sorts  map[string]func(a, b T) int
+}
+
+// parseListQuery parses the list query parameters. sortFields lists the
+// accepted sort fields, the first one being the default.
+func parseListQuery(values url.Values, sortFields []string) (*listQuery, error) {
+	q := &listQuery{
+		hosts:   splitSet(values["host"]),
+		states:  splitSet(values["state"]),
+		project: values.Get("project"),
+		sort:    sortFields[0],
+	}
+
+	var err error
+	if q.name, err = parseTextMatcher(values.Get("name")); err != nil {
+		return nil, fmt.Errorf("invalid name filter: %w", err)
+	}
+	if q.image, err = parseTextMatcher(values.Get("image")); err != nil {
+		return nil, fmt.Errorf("invalid image filter: %w", err)
+	}
+
+	for _, raw := range values["label"] {
+		for term := range strings.SplitSeq(raw, ",") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				continue
+			}
+			selector, err := parseLabelSelector(term)
+			if err != nil {
+				return nil, err
+			}
+			q.labels = append(q.labels, selector)
+		}
+	}
+
+	if sortField := values.Get("sort"); sortField != "" {
+		if !slices.Contains(sortFields, sortField) {
+			return nil, fmt.Errorf("sort must be one of: %s", strings.Join(sortFields, ", "))
+		}
+		q.sort = sortField
+	}
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	if limitStr := values.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		q.limit = min(limit, maxListLimit)
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		q.offset = offset
+	}
+
+	return q, nil
+}
+
+func parseTextMatcher(raw string) (textMatcher, error) {
+	if len(raw) > 1 && strings.HasPrefix(raw, "/") && strings.HasSuffix(raw, "/") {
+		regex, err := regexp.Compile(raw[1 : len(raw)-1])
+		if err != nil {
+			return textMatcher{}, err
+		}
+		return textMatcher{regex: regex}, nil
+	}
+	return textMatcher{substring: strings.ToLower(raw)}, nil
+}
+
+func (m textMatcher) empty() bool {
+	return m.regex == nil && m.substring == ""
+}
+
+func (m textMatcher) match(value string) bool {
+	if m.regex != nil {
+		return m.regex.MatchString(value)
+	}
+	return strings.Contains(strings.ToLower(value), m.substring)
+}
+
+func parseLabelSelector(term string) (labelSelector, error) {
+	if key, value, ok := strings.Cut(term, "!="); ok {
+		if key == "" {
+			return labelSelector{}, fmt.Errorf("invalid label selector: %s", term)
+		}
+		return labelSelector{key: key, value: value, op: "!="}, nil
+	}
+	if key, value, ok := strings.Cut(term, "="); ok {
+		if key == "" {
+			return labelSelector{}, fmt.Errorf("invalid label selector: %s", term)
+		}
+		return labelSelector{key: key, value: value, op: "="}, nil
+	}
+	return labelSelector{key: term, op: "exists"}, nil
+}
+
+func (s labelSelector) match(labels map[string]string) bool {
+	value, ok := labels[s.key]
+	switch s.op {
+	case "=":
+		return ok && value == s.value
+	case "!=":
+		return !ok || value != s.value
+	default:
+		return ok
+	}
+}
+
+// splitSet collects comma-separated values from repeated query parameters
+func splitSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, raw := range values {
+		for value := range strings.SplitSeq(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				set[value] = struct{}{}
+			}
+		}
+	}
+	return set
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor offset")
+	}
+	return offset, nil
+}
+
+// applyListQuery filters, sorts and paginates items. It returns the page,
+// the number of items matching the filters and the cursor of the next page,
+// which is empty on the last page.
+func applyListQuery[T any](items []T, q *listQuery, fields listFields[T]) ([]T, int, string) {
+	matched := make([]T, 0, len(items))
+	for _, item := range items {
+		if matchesListQuery(item, q, fields) {
+			matched = append(matched, item)
+		}
+	}
+
+	compare := fields.sorts[q.sort]
+	slices.SortStableFunc(matched, func(a, b T) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = cmp.Compare(fields.host(a), fields.host(b))
+		}
+		// Hosts list items in no fixed order, so pages need a total order
+		if c == 0 {
+			c = cmp.Compare(fields.id(a), fields.id(b))
+		}
+		if q.desc {
+			return -c
+		}
+		return c
+	})
+
+	total := len(matched)
+	start := min(q.offset, total)
+	end := total
+	if q.limit > 0 {
+		end = min(start+q.limit, total)
+	}
+
+	nextCursor := ""
+	if end < total {
+		nextCursor = encodeCursor(end)
+	}
+
+	return matched[start:end], total, nextCursor
+}
+
+// matchesListQuery reports whether an item passes every filter of q. Filters
+// that do not apply to T (nil accessor) are ignored.
+func matchesListQuery[T any](item T, q *listQuery, fields listFields[T]) bool {
+	if len(q.hosts) > 0 {
+		if _, ok := q.hosts[fields.host(item)]; !ok {
+			return false
+		}
+	}
+
+	if len(q.states) > 0 && fields.state != nil {
+		if _, ok := q.states[fields.state(item)]; !ok {
+			return false
+		}
+	}
+
+	if !q.name.empty() {
+		if !slices.ContainsFunc(fields.names(item), q.name.match) {
+			return false
+		}
+	}
+
+	if !q.image.empty() && fields.image != nil && !q.image.match(fields.image(item)) {
+		return false
+	}
+
+	labels := fields.labels(item)
+	if q.project != "" && labels[composeProjectLabel] != q.project {
+		return false
+	}
+	for _, selector := range q.labels {
+		if !selector.match(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containerSortFields are the sort fields of the container list, the first
+// one being the default
+var containerSortFields = []string{"name", "created", "state", "image", "host"}
+
+var containerListFields = listFields[models.ContainerInfo]{
+	id:     func(c models.ContainerInfo) string { return c.ID },
+	host:   func(c models.ContainerInfo) string { return c.Host },
+	names:  containerNames,
+	image:  func(c models.ContainerInfo) string { return c.Image },
+	state:  func(c models.ContainerInfo) string { return c.State },
+	labels: func(c models.ContainerInfo) map[string]string { return c.Labels },
+	sorts: map[string]func(a, b models.ContainerInfo) int{
+		"name": func(a, b models.ContainerInfo) int {
+			return cmp.Compare(firstOrEmpty(containerNames(a)), firstOrEmpty(containerNames(b)))
+		},
+		"created": func(a, b models.ContainerInfo) int { return cmp.Compare(a.Created, b.Created) },
+		"state":   func(a, b models.ContainerInfo) int { return cmp.Compare(a.State, b.State) },
+		"image":   func(a, b models.ContainerInfo) int { return cmp.Compare(a.Image, b.Image) },
+		"host":    func(a, b models.ContainerInfo) int { return cmp.Compare(a.Host, b.Host) },
+	},
+}
+
+// imageSortFields are the sort fields of the image list, the first one being
+// the default
+var imageSortFields = []string{"name", "created", "size", "containers", "host"}
+
+var imageListFields = listFields[models.ImageInfo]{
+	id:     func(i models.ImageInfo) string { return i.ID },
+	host:   func(i models.ImageInfo) string { return i.Host },
+	names:  func(i models.ImageInfo) []string { return i.RepoTags },
+	state:  imageState,
+	labels: func(i models.ImageInfo) map[string]string { return i.Labels },
+	sorts: map[string]func(a, b models.ImageInfo) int{
+		"name": func(a, b models.ImageInfo) int {
+			return cmp.Compare(firstOrEmpty(a.RepoTags), firstOrEmpty(b.RepoTags))
+		},
+		"created":    func(a, b models.ImageInfo) int { return cmp.Compare(a.Created, b.Created) },
+		"size":       func(a, b models.ImageInfo) int { return cmp.Compare(a.Size, b.Size) },
+		"containers": func(a, b models.ImageInfo) int { return cmp.Compare(a.Containers, b.Containers) },
+		"host":       func(a, b models.ImageInfo) int { return cmp.Compare(a.Host, b.Host) },
+	},
+}
+
+// networkSortFields are the sort fields of the network list, the first one
+// being the default
+var networkSortFields = []string{"name", "driver", "containers", "host"}
+
+var networkListFields = listFields[models.NetworkInfo]{
+	id:     func(n models.NetworkInfo) string { return n.ID },
+	host:   func(n models.NetworkInfo) string { return n.Host },
+	names:  func(n models.NetworkInfo) []string { return []string{n.Name} },
+	labels: func(n models.NetworkInfo) map[string]string { return n.Labels },
+	sorts: map[string]func(a, b models.NetworkInfo) int{
+		"name":       func(a, b models.NetworkInfo) int { return cmp.Compare(a.Name, b.Name) },
+		"driver":     func(a, b models.NetworkInfo) int { return cmp.Compare(a.Driver, b.Driver) },
+		"containers": func(a, b models.NetworkInfo) int { return cmp.Compare(a.Containers, b.Containers) },
+		"host":       func(a, b models.NetworkInfo) int { return cmp.Compare(a.Host, b.Host) },
+	},
+}
+
+// containerNames returns the names of a container without the leading slash
+func containerNames(c models.ContainerInfo) []string {
+	names := make([]string, len(c.Names))
+	for i, name := range c.Names {
+		names[i] = strings.TrimPrefix(name, "/")
+	}
+	return names
+}
+
+// imageState classifies an image as "dangling", "unused" or "in_use"
+func imageState(i models.ImageInfo) string {
+	switch {
+	case i.Dangling:
+		return "dangling"
+	case i.Unused:
+		return "unused"
+	default:
+		return "in_use"
+	}
+}
+
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
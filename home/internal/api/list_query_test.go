@@ -0,0 +1,265 @@
+package api
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+func TestParseListQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+		check   func(t *testing.T, q *listQuery)
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			check: func(t *testing.T, q *listQuery) {
+				if q.sort != "name" || q.desc || q.limit != 0 || q.offset != 0 {
+					t.Errorf("got sort=%s desc=%t limit=%d offset=%d", q.sort, q.desc, q.limit, q.offset)
+				}
+			},
+		},
+		{
+			name:  "sort and order",
+			query: "sort=created&order=desc",
+			check: func(t *testing.T, q *listQuery) {
+				if q.sort != "created" || !q.desc {
+					t.Errorf("got sort=%s desc=%t", q.sort, q.desc)
+				}
+			},
+		},
+		{
+			name:  "hosts and states are split and merged",
+			query: "host=a,b&host=c&state=running,%20exited",
+			check: func(t *testing.T, q *listQuery) {
+				if len(q.hosts) != 3 || len(q.states) != 2 {
+					t.Errorf("got hosts=%v states=%v", q.hosts, q.states)
+				}
+				if _, ok := q.states["exited"]; !ok {
+					t.Errorf("state filter not trimmed: %v", q.states)
+				}
+			},
+		},
+		{
+			name:  "label selectors",
+			query: "label=env=prod,tier!=db&label=managed",
+			check: func(t *testing.T, q *listQuery) {
+				want := []labelSelector{
+					{key: "env", value: "prod", op: "="},
+					{key: "tier", value: "db", op: "!="},
+					{key: "managed", op: "exists"},
+				}
+				if !slices.Equal(q.labels, want) {
+					t.Errorf("got labels %+v, want %+v", q.labels, want)
+				}
+			},
+		},
+		{
+			name:  "limit is capped",
+			query: "limit=5000",
+			check: func(t *testing.T, q *listQuery) {
+				if q.limit != maxListLimit {
+					t.Errorf("got limit %d, want %d", q.limit, maxListLimit)
+				}
+			},
+		},
+		{
+			name:  "regex name filter",
+			query: "name=/^web-\\d%2B$/",
+			check: func(t *testing.T, q *listQuery) {
+				if q.name.regex == nil || !q.name.match("web-12") || q.name.match("api-web-1") {
+					t.Errorf("regex name filter does not match as expected")
+				}
+			},
+		},
+		{
+			name:  "cursor",
+			query: "cursor=" + encodeCursor(40),
+			check: func(t *testing.T, q *listQuery) {
+				if q.offset != 40 {
+					t.Errorf("got offset %d, want 40", q.offset)
+				}
+			},
+		},
+		{name: "invalid sort", query: "sort=size", wantErr: true},
+		{name: "invalid order", query: "order=up", wantErr: true},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "non-numeric limit", query: "limit=all", wantErr: true},
+		{name: "invalid regex", query: "name=/[/", wantErr: true},
+		{name: "label without key", query: "label==prod", wantErr: true},
+		{name: "cursor not base64", query: "cursor=***", wantErr: true},
+		{name: "cursor not a number", query: "cursor=YWJj", wantErr: true},
+		{name: "negative cursor", query: "cursor=" + encodeCursor(-1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			q, err := parseListQuery(values, containerSortFields)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseListQuery(%q) succeeded, want error", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListQuery(%q) error = %v", tt.query, err)
+			}
+			tt.check(t, q)
+		})
+	}
+}
+
+func TestApplyListQuery(t *testing.T) {
+	containers := []models.ContainerInfo{
+		{ID: "1", Names: []string{"/web-1"}, Image: "nginx:1.27", State: "running", Host: "a", Created: 3,
+			Labels: map[string]string{composeProjectLabel: "shop", "env": "prod"}},
+		{ID: "2", Names: []string{"/web-2"}, Image: "nginx:1.27", State: "exited", Host: "b", Created: 1,
+			Labels: map[string]string{composeProjectLabel: "shop", "env": "staging"}},
+		{ID: "3", Names: []string{"/db"}, Image: "postgres:17", State: "running", Host: "a", Created: 2,
+			Labels: map[string]string{composeProjectLabel: "shop", "env": "prod", "tier": "db"}},
+		{ID: "4", Names: []string{"/cache"}, Image: "redis:7", State: "running", Host: "b", Created: 4},
+		{ID: "5", Names: []string{"/api"}, Image: "api:latest", State: "paused", Host: "a", Created: 5,
+			Labels: map[string]string{"env": "prod"}},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantIDs   []string
+		wantTotal int
+	}{
+		{"default sort by name", "", []string{"5", "4", "3", "1", "2"}, 5},
+		{"sort descending", "sort=created&order=desc", []string{"5", "4", "1", "3", "2"}, 5},
+		{"ties broken by host", "sort=state", []string{"2", "5", "1", "3", "4"}, 5},
+		{"host filter", "host=b", []string{"4", "2"}, 2},
+		{"state filter", "state=running,paused&sort=created", []string{"3", "1", "4", "5"}, 4},
+		{"name substring is case-insensitive", "name=WEB", []string{"1", "2"}, 2},
+		{"name regex", "name=/^(db|api)$/", []string{"5", "3"}, 2},
+		{"image filter", "image=nginx", []string{"1", "2"}, 2},
+		{"compose project", "project=shop", []string{"3", "1", "2"}, 3},
+		{"label equals", "label=env=prod", []string{"5", "3", "1"}, 3},
+		{"label not equals matches missing labels", "label=env!=prod", []string{"4", "2"}, 2},
+		{"label exists", "label=tier", []string{"3"}, 1},
+		{"selectors are combined", "label=env=prod,tier!=db&host=a", []string{"5", "1"}, 2},
+		{"first page", "limit=2", []string{"5", "4"}, 5},
+		{"no matches", "name=missing", []string{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := parseListQuery(values, containerSortFields)
+			if err != nil {
+				t.Fatalf("parseListQuery(%q) error = %v", tt.query, err)
+			}
+
+			page, total, _ := applyListQuery(containers, q, containerListFields)
+			if got := containerIDs(page); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("got %v, want %v", got, tt.wantIDs)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("got total %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+// TestListQueryCursorRoundTrip pages through a list by following the next
+// cursor until it is empty
+func TestListQueryCursorRoundTrip(t *testing.T) {
+	var containers []models.ContainerInfo
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		containers = append(containers, models.ContainerInfo{ID: name, Names: []string{"/" + name}, Host: "local"})
+	}
+
+	for _, limit := range []string{"1", "2", "3", "7", "10"} {
+		t.Run("limit="+limit, func(t *testing.T) {
+			var seen []string
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(containers) {
+					t.Fatal("cursor does not advance")
+				}
+
+				values := url.Values{"limit": {limit}, "order": {"desc"}}
+				if cursor != "" {
+					values.Set("cursor", cursor)
+				}
+				q, err := parseListQuery(values, containerSortFields)
+				if err != nil {
+					t.Fatalf("parseListQuery() error = %v", err)
+				}
+
+				page, total, next := applyListQuery(containers, q, containerListFields)
+				if total != len(containers) {
+					t.Fatalf("got total %d, want %d", total, len(containers))
+				}
+				seen = append(seen, containerIDs(page)...)
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+
+			want := []string{"g", "f", "e", "d", "c", "b", "a"}
+			if !slices.Equal(seen, want) {
+				t.Errorf("got %v, want %v", seen, want)
+			}
+		})
+	}
+
+	// A cursor past the end yields an empty last page
+	q, err := parseListQuery(url.Values{"cursor": {encodeCursor(100)}}, containerSortFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, total, next := applyListQuery(containers, q, containerListFields)
+	if len(page) != 0 || total != len(containers) || next != "" {
+		t.Errorf("got %d items, total %d, next %q past the end", len(page), total, next)
+	}
+}
+
+// TestListQueryTotalOrder sorts items with equal keys on the same host in
+// two input orders, as hosts may list them, and expects the same pages
+func TestListQueryTotalOrder(t *testing.T) {
+	var images []models.ImageInfo
+	for _, id := range []string{"sha256:c", "sha256:a", "sha256:d", "sha256:b"} {
+		images = append(images, models.ImageInfo{ID: id, Host: "local"})
+	}
+	reversed := slices.Clone(images)
+	slices.Reverse(reversed)
+
+	q, err := parseListQuery(url.Values{"limit": {"2"}}, imageSortFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, _ := applyListQuery(images, q, imageListFields)
+	second, _, _ := applyListQuery(reversed, q, imageListFields)
+
+	for i, want := range []string{"sha256:a", "sha256:b"} {
+		if first[i].ID != want || second[i].ID != want {
+			t.Errorf("item %d: got %s and %s, want %s", i, first[i].ID, second[i].ID, want)
+		}
+	}
+}
+
+func containerIDs(containers []models.ContainerInfo) []string {
+	ids := make([]string, 0, len(containers))
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/metrics"
+)
+
+// metricsScrapeTimeout stays below the default Prometheus scrape timeout
+const metricsScrapeTimeout = 9 * time.Second
+
+// GetMetrics serves metrics in the Prometheus text or OpenMetrics format,
+// depending on the Accept header of the scraper
+func (ar *APIRouter) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	if !ar.authorizeMetrics(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), metricsScrapeTimeout)
+	defer cancel()
+
+	families := ar.exporter.Collect(ctx)
+
+	format := metrics.NegotiateFormat(r.Header.Get("Accept"))
+	w.Header().Set("Content-Type", format.ContentType())
+	if err := metrics.Write(w, format, families); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
+	}
+}
+
+// authorizeMetrics checks the bearer token of a scrape. With METRICS_TOKEN
+// set only that token is accepted; otherwise, when authentication is enabled,
+// a token of the API login is required so metrics are never public.
+func (ar *APIRouter) authorizeMetrics(r *http.Request) bool {
+	token := ar.config.Metrics.Token
+	if token == "" && ar.authService == nil {
+		return true
+	}
+
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	if token != "" {
+		return subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+	}
+	_, err := ar.authService.VerifyToken(bearer)
+	return err == nil
+}
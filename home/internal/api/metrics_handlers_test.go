@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hhftechnology/vps-monitor/internal/auth"
+	"github.com/hhftechnology/vps-monitor/internal/config"
+)
+
+func TestAuthorizeMetrics(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("ADMIN_USERNAME", "admin")
+	t.Setenv("ADMIN_PASSWORD", "hash")
+	authService, err := auth.NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiToken, err := authService.GenerateToken("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		metricsToken  string
+		authService   *auth.Service
+		authorization string
+		want          bool
+	}{
+		{"open without any auth", "", nil, "", true},
+		{"metrics token required", "scrape", nil, "", false},
+		{"metrics token accepted", "scrape", nil, "Bearer scrape", true},
+		{"wrong metrics token", "scrape", nil, "Bearer other", false},
+		{"metrics token without Bearer", "scrape", nil, "scrape", false},
+		{"auth enabled requires a token", "", authService, "", false},
+		{"auth enabled accepts an API token", "", authService, "Bearer " + apiToken, true},
+		{"auth enabled rejects an invalid token", "", authService, "Bearer not-a-jwt", false},
+		{"metrics token takes precedence over API tokens", "scrape", authService, "Bearer " + apiToken, false},
+		{"metrics token with auth enabled", "scrape", authService, "Bearer scrape", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &APIRouter{
+				authService: tt.authService,
+				config:      &config.Config{Metrics: &config.MetricsConfig{Token: tt.metricsToken}},
+			}
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			if got := ar.authorizeMetrics(r); got != tt.want {
+				t.Errorf("authorizeMetrics() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
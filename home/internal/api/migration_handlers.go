@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+const (
+	// migrationTimeout bounds how long a single migration may run
+	migrationTimeout = time.Hour
+	// migrationRetention is how long finished migrations stay queryable
+	migrationRetention = 24 * time.Hour
+)
+
+// migrationTracker keeps the state of migrations started through the API so
+// their progress can be queried and streamed
+type migrationTracker struct {
+	mu         sync.RWMutex
+	migrations map[string]*trackedMigration
+}
+
+// trackedMigration holds a migration and wakes up stream readers on change
+type trackedMigration struct {
+	mu        sync.RWMutex
+	migration models.Migration
+	updated   chan struct{}
+}
+
+func newMigrationTracker() *migrationTracker {
+	return &migrationTracker{
+		migrations: make(map[string]*trackedMigration),
+	}
+}
+
+func (t *migrationTracker) start(sourceHost, containerID, targetHost string) *trackedMigration {
+	tm := &trackedMigration{
+		migration: models.Migration{
+			ID:          uuid.New().String(),
+			SourceHost:  sourceHost,
+			TargetHost:  targetHost,
+			ContainerID: containerID,
+			Status:      models.MigrationRunning,
+			Steps:       []models.MigrationStep{},
+			StartedAt:   time.Now().Unix(),
+		},
+		updated: make(chan struct{}),
+	}
+
+	t.mu.Lock()
+	t.prune()
+	t.migrations[tm.migration.ID] = tm
+	t.mu.Unlock()
+
+	return tm
+}
+
+// prune forgets migrations that finished more than migrationRetention ago.
+// The caller must hold t.mu for writing.
+func (t *migrationTracker) prune() {
+	cutoff := time.Now().Add(-migrationRetention).Unix()
+	for id, tm := range t.migrations {
+		tm.mu.RLock()
+		finishedAt := tm.migration.FinishedAt
+		tm.mu.RUnlock()
+		if finishedAt > 0 && finishedAt < cutoff {
+			delete(t.migrations, id)
+		}
+	}
+}
+
+func (t *migrationTracker) get(id string) (*trackedMigration, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tm, ok := t.migrations[id]
+	return tm, ok
+}
+
+func (t *migrationTracker) list() []models.Migration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune()
+
+	result := make([]models.Migration, 0, len(t.migrations))
+	for _, tm := range t.migrations {
+		result = append(result, tm.snapshot())
+	}
+
+	// Newest first
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt > result[j].StartedAt
+	})
+	return result
+}
+
+// addStep records a progress event and notifies stream readers
+func (tm *trackedMigration) addStep(step models.MigrationStep) {
+	tm.mu.Lock()
+	tm.migration.Steps = append(tm.migration.Steps, step)
+	close(tm.updated)
+	tm.updated = make(chan struct{})
+	tm.mu.Unlock()
+}
+
+// finish records the outcome of the migration and notifies stream readers
+func (tm *trackedMigration) finish(newContainerID string, err error) {
+	tm.mu.Lock()
+	tm.migration.NewContainerID = newContainerID
+	tm.migration.FinishedAt = time.Now().Unix()
+	if err != nil {
+		tm.migration.Status = models.MigrationFailed
+		tm.migration.Error = err.Error()
+	} else {
+		tm.migration.Status = models.MigrationCompleted
+	}
+	close(tm.updated)
+	tm.updated = make(chan struct{})
+	tm.mu.Unlock()
+}
+
+func (tm *trackedMigration) snapshot() models.Migration {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	migration := tm.migration
+	migration.Steps = append([]models.MigrationStep(nil), tm.migration.Steps...)
+	return migration
+}
+
+// stepsSince returns the steps after the first n, whether the migration is
+// finished and a channel that is closed on the next change
+func (tm *trackedMigration) stepsSince(n int) ([]models.MigrationStep, bool, <-chan struct{}) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	steps := append([]models.MigrationStep(nil), tm.migration.Steps[n:]...)
+	return steps, tm.migration.Status != models.MigrationRunning, tm.updated
+}
+
+// MigrateContainer starts moving a container to another host. The migration
+// runs in the background and can be followed through the migrations endpoints.
+func (ar *APIRouter) MigrateContainer(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.MigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.TargetHost == "" {
+		http.Error(w, "target_host is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.TargetHost == host {
+		http.Error(w, "target_host must differ from the source host", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := ar.docker.GetClient(req.TargetHost); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.SourceAction {
+	case "", "keep", "stop", "remove":
+	default:
+		http.Error(w, "source_action must be keep, stop or remove", http.StatusBadRequest)
+		return
+	}
+
+	tm := ar.migrations.start(host, id, req.TargetHost)
+	migration := tm.snapshot()
+
+	// Execute migration asynchronously
+	go func() {
+		// Use Background context for async operation as request context will be cancelled
+		ctx, cancel := context.WithTimeout(context.Background(), migrationTimeout)
+		defer cancel()
+
+		newContainerID, err := ar.docker.MigrateContainer(ctx, host, id, req, tm.addStep)
+		if err != nil {
+			log.Printf("Failed to migrate container %s from %s to %s: %v", id, host, req.TargetHost, err)
+		}
+		tm.finish(newContainerID, err)
+	}()
+
+	WriteJsonResponse(w, http.StatusAccepted, map[string]any{
+		"message":   "Container migration started",
+		"migration": migration,
+	})
+}
+
+// GetMigrations lists migrations started since the server was started
+func (ar *APIRouter) GetMigrations(w http.ResponseWriter, r *http.Request) {
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"migrations": ar.migrations.list(),
+	})
+}
+
+// GetMigration returns the current state of a migration
+func (ar *APIRouter) GetMigration(w http.ResponseWriter, r *http.Request) {
+	tm, ok := ar.migrations.get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "migration not found", http.StatusNotFound)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"migration": tm.snapshot(),
+	})
+}
+
+// StreamMigration streams the steps of a migration as NDJSON, starting with
+// the steps that already happened, and ends with the final migration state
+func (ar *APIRouter) StreamMigration(w http.ResponseWriter, r *http.Request) {
+	tm, ok := ar.migrations.get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "migration not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	encoder := json.NewEncoder(w)
+	sent := 0
+	for {
+		steps, finished, updated := tm.stepsSince(sent)
+		for _, step := range steps {
+			if err := encoder.Encode(step); err != nil {
+				return
+			}
+		}
+		sent += len(steps)
+		flusher.Flush()
+
+		if finished {
+			_ = encoder.Encode(tm.snapshot())
+			flusher.Flush()
+			return
+		}
+
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
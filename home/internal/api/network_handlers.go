@@ -12,6 +12,12 @@ import (
 
 // GetNetworks lists all networks across all Docker hosts
 func (ar *APIRouter) GetNetworks(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r.URL.Query(), networkSortFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
@@ -32,9 +38,13 @@ func (ar *APIRouter) GetNetworks(w http.ResponseWriter, r *http.Request) {
 		allNetworks = append(allNetworks, networks...)
 	}
 
+	allNetworks, total, nextCursor := applyListQuery(allNetworks, query, networkListFields)
+
 	WriteJsonResponse(w, http.StatusOK, map[string]any{
-		"networks": allNetworks,
-		"hosts":    ar.docker.GetHosts(),
+		"networks":    allNetworks,
+		"total":       total,
+		"next_cursor": nextCursor,
+		"hosts":       ar.docker.GetHosts(),
 	})
 }
 
@@ -14,8 +14,10 @@ import (
 	"github.com/hhftechnology/vps-monitor/internal/auth"
 	"github.com/hhftechnology/vps-monitor/internal/config"
 	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/metrics"
 	"github.com/hhftechnology/vps-monitor/internal/models"
 	"github.com/hhftechnology/vps-monitor/internal/static"
+	"github.com/hhftechnology/vps-monitor/internal/stats"
 )
 
 // Buffer pool for JSON encoding to reduce allocations
@@ -32,11 +34,17 @@ type APIRouter struct {
 	config        *config.Config
 	alertMonitor  *alerts.Monitor
 	alertHandlers *AlertHandlers
+	migrations    *migrationTracker
+	hostSampler   *stats.HostSampler
+	containers    *stats.ContainerSampler
+	exporter      *metrics.Exporter
 }
 
 // RouterOptions contains optional dependencies for the router
 type RouterOptions struct {
-	AlertMonitor *alerts.Monitor
+	AlertMonitor     *alerts.Monitor
+	HostSampler      *stats.HostSampler
+	ContainerSampler *stats.ContainerSampler
 }
 
 func NewRouter(docker *docker.MultiHostClient, authService *auth.Service, config *config.Config, opts *RouterOptions) *chi.Mux {
@@ -45,6 +53,12 @@ func NewRouter(docker *docker.MultiHostClient, authService *auth.Service, config
 		docker:      docker,
 		authService: authService,
 		config:      config,
+		migrations:  newMigrationTracker(),
+	}
+
+	if opts != nil {
+		r.hostSampler = opts.HostSampler
+		r.containers = opts.ContainerSampler
 	}
 
 	// Set up alert handlers if monitor is provided
@@ -56,6 +70,15 @@ func NewRouter(docker *docker.MultiHostClient, authService *auth.Service, config
 			MemoryThreshold: config.Alerts.MemoryThreshold,
 			CheckInterval:   config.Alerts.CheckInterval.String(),
 			WebhookEnabled:  config.Alerts.WebhookURL != "",
+
+			CrashLoopRestarts: config.Alerts.CrashLoopRestarts,
+			CrashLoopWindow:   config.Alerts.CrashLoopWindow.String(),
+
+			HostDiskThreshold:   config.Alerts.HostDiskThreshold,
+			HostMemoryThreshold: config.Alerts.HostMemoryThreshold,
+			HostLoadThreshold:   config.Alerts.HostLoadThreshold,
+			HostSwapThreshold:   config.Alerts.HostSwapThreshold,
+			HostInodeThreshold:  config.Alerts.HostInodeThreshold,
 		})
 	} else {
 		// Create handlers with nil monitor (alerts disabled)
@@ -64,6 +87,8 @@ func NewRouter(docker *docker.MultiHostClient, authService *auth.Service, config
 		})
 	}
 
+	r.exporter = metrics.NewExporter(docker, r.alertMonitor, r.hostSampler, r.containers)
+
 	return r.Routes()
 }
 
@@ -109,6 +134,7 @@ func (ar *APIRouter) Routes() *chi.Mux {
 				ar.registerContainerRoutes(protected)
 				ar.registerImageRoutes(protected)
 				ar.registerNetworkRoutes(protected)
+				ar.registerHostRoutes(protected)
 				ar.registerAlertRoutes(protected)
 			})
 			return
@@ -118,9 +144,14 @@ func (ar *APIRouter) Routes() *chi.Mux {
 		ar.registerContainerRoutes(r)
 		ar.registerImageRoutes(r)
 		ar.registerNetworkRoutes(r)
+		ar.registerHostRoutes(r)
 		ar.registerAlertRoutes(r)
 	})
 
+	// Prometheus scrape target, protected by its own bearer token as
+	// scrapers cannot log in, or by an API token when only auth is set up
+	ar.router.Get("/metrics", ar.GetMetrics)
+
 	// Serve embedded frontend static files
 	// This handles all non-API routes and serves the React SPA
 	staticFS, err := static.GetFileSystem()
@@ -137,6 +168,7 @@ func (ar *APIRouter) Routes() *chi.Mux {
 
 func (ar *APIRouter) registerContainerRoutes(r chi.Router) {
 	r.Get("/containers", ar.GetContainers)
+	r.Get("/containers/stats/range", ar.GetContainersStatsRange)
 	r.Route("/containers/{id}", func(r chi.Router) {
 		r.Get("/", ar.GetContainer)
 		r.Get("/logs/parsed", ar.GetContainerLogsParsed)
@@ -144,6 +176,12 @@ func (ar *APIRouter) registerContainerRoutes(r chi.Router) {
 		r.Get("/stats", ar.HandleContainerStats)
 		r.Get("/stats/once", ar.GetContainerStatsOnce)
 		r.Get("/stats/history", ar.GetContainerHistoricalStats)
+		r.Get("/top", ar.GetContainerProcesses)
+		r.Get("/diff", ar.GetContainerDiff)
+		r.Get("/files", ar.ListContainerFiles)
+		r.Get("/files/stat", ar.StatContainerFile)
+		r.Get("/files/download", ar.DownloadContainerFile)
+		r.Get("/export", ar.ExportContainer)
 
 		r.Group(func(mutating chi.Router) {
 			mutating.Use(middleware.ReadOnly(ar.config))
@@ -153,14 +191,28 @@ func (ar *APIRouter) registerContainerRoutes(r chi.Router) {
 			mutating.Post("/remove", ar.RemoveContainer)
 			mutating.Put("/env", ar.UpdateEnvVariables)
 			mutating.Get("/exec", ar.HandleTerminal)
+			mutating.Post("/files/upload", ar.UploadContainerFile)
+			mutating.Post("/snapshot", ar.CreateSnapshot)
+			mutating.Post("/migrate", ar.MigrateContainer)
 		})
 	})
+
+	r.Get("/migrations", ar.GetMigrations)
+	r.Get("/migrations/{id}", ar.GetMigration)
+	r.Get("/migrations/{id}/stream", ar.StreamMigration)
+
+	r.Get("/snapshots", ar.GetSnapshots)
+	r.Group(func(mutating chi.Router) {
+		mutating.Use(middleware.ReadOnly(ar.config))
+		mutating.Post("/snapshots/{id}/restore", ar.RestoreSnapshot)
+	})
 }
 
 func (ar *APIRouter) registerImageRoutes(r chi.Router) {
 	r.Get("/images", ar.GetImages)
 	r.Route("/images/{id}", func(r chi.Router) {
 		r.Get("/", ar.GetImage)
+		r.Get("/save", ar.SaveImage)
 
 		// Mutating routes (blocked in read-only mode)
 		r.Group(func(mutating chi.Router) {
@@ -169,10 +221,13 @@ func (ar *APIRouter) registerImageRoutes(r chi.Router) {
 		})
 	})
 
-	// Image pull (mutating)
+	// Image pull and transfer (mutating)
 	r.Group(func(mutating chi.Router) {
 		mutating.Use(middleware.ReadOnly(ar.config))
 		mutating.Post("/images/pull", ar.PullImage)
+		mutating.Post("/images/copy", ar.CopyImage)
+		mutating.Post("/images/load", ar.LoadImage)
+		mutating.Post("/images/build", ar.BuildImage)
 	})
 }
 
@@ -181,6 +236,16 @@ func (ar *APIRouter) registerNetworkRoutes(r chi.Router) {
 	r.Get("/networks/{id}", ar.GetNetwork)
 }
 
+func (ar *APIRouter) registerHostRoutes(r chi.Router) {
+	r.Get("/system/metrics", ar.GetSystemMetrics)
+	r.Get("/system/processes", ar.GetSystemProcesses)
+	r.Get("/system/stats/ws", ar.HandleHostStats)
+	r.Get("/system/stats/history", ar.GetHostStatsHistory)
+	r.Get("/hosts/info", ar.GetHostsInfo)
+	r.Get("/hosts/{name}/info", ar.GetHostInfo)
+	r.Get("/hosts/{name}/system/stats", ar.GetHostSystemStats)
+}
+
 func (ar *APIRouter) registerAlertRoutes(r chi.Router) {
 	r.Get("/alerts", ar.alertHandlers.GetAlerts)
 	r.Get("/alerts/config", ar.alertHandlers.GetAlertConfig)
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// CreateSnapshot commits a container to a new snapshot image
+func (ar *APIRouter) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SnapshotRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	snapshot, err := ar.docker.CommitSnapshot(r.Context(), host, id, req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"message":  "Snapshot created",
+		"snapshot": snapshot,
+	})
+}
+
+// ExportContainer streams a container's filesystem as a tar archive
+func (ar *APIRouter) ExportContainer(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := ar.docker.ExportContainer(r.Context(), host, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", imageArchiveName(id)))
+
+	if _, err := io.Copy(w, reader); err != nil {
+		log.Printf("Failed to export container %s on host %s: %v", id, host, err)
+	}
+}
+
+// GetSnapshots lists snapshots across all hosts, optionally filtered by host
+// and by the container they were taken from
+func (ar *APIRouter) GetSnapshots(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	host := r.URL.Query().Get("host")
+	containerFilter := r.URL.Query().Get("container")
+
+	snapshotsMap, hostErrors, err := ar.docker.ListSnapshotsAllHosts(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(hostErrors) > 0 {
+		http.Error(w, fmt.Sprintf("Error listing snapshots on some hosts: %v", hostErrors), http.StatusInternalServerError)
+		return
+	}
+
+	allSnapshots := []models.Snapshot{}
+	for hostName, snapshots := range snapshotsMap {
+		if host != "" && hostName != host {
+			continue
+		}
+		for _, snapshot := range snapshots {
+			if containerFilter != "" &&
+				snapshot.ContainerName != containerFilter &&
+				!strings.HasPrefix(snapshot.ContainerID, containerFilter) {
+				continue
+			}
+			allSnapshots = append(allSnapshots, snapshot)
+		}
+	}
+
+	// Newest first
+	sort.Slice(allSnapshots, func(i, j int) bool {
+		return allSnapshots[i].Created > allSnapshots[j].Created
+	})
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"snapshots": allSnapshots,
+	})
+}
+
+// RestoreSnapshot recreates a container from a snapshot image
+func (ar *APIRouter) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	rawID := chi.URLParam(r, "id")
+	host := r.URL.Query().Get("host")
+	name := r.URL.Query().Get("name")
+
+	// URL-decode the image ID (handles sha256%3A... -> sha256:...)
+	id, err := url.PathUnescape(rawID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid image ID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if host == "" {
+		http.Error(w, "host parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	newContainerID, err := ar.docker.RestoreSnapshot(r.Context(), host, id, name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to restore snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"message":          "Snapshot restored",
+		"new_container_id": newContainerID,
+	})
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/models"
+	"github.com/hhftechnology/vps-monitor/internal/stats"
+)
+
+// maxRangeContainers caps the containers of a single range query
+const maxRangeContainers = 20
+
+var defaultRangeMetrics = []string{stats.MetricCPUPercent, stats.MetricMemoryPercent}
+
+// GetContainersStatsRange returns the stats history of one or more containers
+// as series for charting. Containers are given by ID or name in ids, or as
+// the services of a compose project.
+func (ar *APIRouter) GetContainersStatsRange(w http.ResponseWriter, r *http.Request) {
+	if ar.containers == nil {
+		http.Error(w, "Stats history not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+
+	from, to, err := parseTimeRange(query, time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var step time.Duration
+	if value := query.Get("step"); value != "" {
+		step, err = time.ParseDuration(value)
+		if err != nil || step <= 0 {
+			http.Error(w, fmt.Sprintf("invalid step: %s", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	metrics := defaultRangeMetrics
+	if value := query.Get("metrics"); value != "" {
+		metrics = splitList(value)
+		for _, metric := range metrics {
+			if !stats.IsMetric(metric) {
+				http.Error(w, fmt.Sprintf("unknown metric: %s (expected one of %s)", metric, strings.Join(stats.Metrics, ", ")), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	ids := splitList(query.Get("ids"))
+	project := query.Get("project")
+	if len(ids) == 0 && project == "" {
+		http.Error(w, "ids or project parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	series, err := ar.resolveSeriesContainers(ctx, ids, project, query.Get("host"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	statsHistory := ar.containers.History()
+	usedStep := step
+	for i := range series {
+		series[i].Metrics, usedStep = statsHistory.GetSeries(series[i].ContainerID, from, to, step, metrics)
+	}
+
+	WriteJsonResponse(w, http.StatusOK, map[string]any{
+		"from":   from.Unix(),
+		"to":     to.Unix(),
+		"step":   int64(usedStep.Seconds()),
+		"series": series,
+	})
+}
+
+// resolveSeriesContainers matches ids against full IDs, ID prefixes and names
+// of existing containers, or selects the containers of a compose project.
+// IDs of removed containers are kept as is, as their history may remain.
+func (ar *APIRouter) resolveSeriesContainers(ctx context.Context, ids []string, project, host string) ([]models.ContainerStatsSeries, error) {
+	containersMap, _, err := ar.docker.ListContainersAllHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	series := []models.ContainerStatsSeries{}
+	seen := make(map[string]struct{})
+	add := func(hostName string, ctr models.ContainerInfo) {
+		if _, dup := seen[ctr.ID]; dup {
+			return
+		}
+		seen[ctr.ID] = struct{}{}
+
+		name := ""
+		if len(ctr.Names) > 0 {
+			name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		series = append(series, models.ContainerStatsSeries{ContainerID: ctr.ID, ContainerName: name, Host: hostName})
+	}
+
+	for _, id := range ids {
+		found := false
+		for hostName, containers := range containersMap {
+			if host != "" && hostName != host {
+				continue
+			}
+			for _, ctr := range containers {
+				if ctr.ID == id || (len(id) >= 12 && strings.HasPrefix(ctr.ID, id)) || slices.Contains(ctr.Names, "/"+id) {
+					add(hostName, ctr)
+					found = true
+				}
+			}
+		}
+		if !found {
+			if _, dup := seen[id]; !dup {
+				seen[id] = struct{}{}
+				series = append(series, models.ContainerStatsSeries{ContainerID: id})
+			}
+		}
+	}
+
+	if project != "" {
+		for hostName, containers := range containersMap {
+			if host != "" && hostName != host {
+				continue
+			}
+			for _, ctr := range containers {
+				if ctr.Labels[composeProjectLabel] == project {
+					add(hostName, ctr)
+				}
+			}
+		}
+	}
+
+	if len(series) == 0 {
+		return nil, fmt.Errorf("no containers found for project %s", project)
+	}
+	if len(series) > maxRangeContainers {
+		return nil, fmt.Errorf("too many containers (%d), at most %d are allowed", len(series), maxRangeContainers)
+	}
+
+	slices.SortFunc(series, func(a, b models.ContainerStatsSeries) int {
+		return strings.Compare(a.ContainerName, b.ContainerName)
+	})
+	return series, nil
+}
+
+// splitList splits a comma-separated parameter, dropping empty entries
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -9,8 +11,35 @@ import (
 )
 
 type DockerHost struct {
-	Name string
-	Host string
+	Name  string
+	Host  string
+	Agent *AgentClientConfig `json:"-"` // Set for agent:// hosts
+}
+
+// AgentClientConfig holds the credentials used to talk to a vps-monitor agent
+type AgentClientConfig struct {
+	Token    string // Bearer token sent with every request
+	CAFile   string // CA verifying the agent certificate instead of the system roots
+	CertFile string // Client certificate for mTLS
+	KeyFile  string // Client key for mTLS
+	Insecure bool   // Reach the agent over plain HTTP
+}
+
+// AgentConfig holds configuration for running as an agent
+type AgentConfig struct {
+	Listen       string
+	Token        string
+	TLSCert      string
+	TLSKey       string
+	TLSClientCA  string // CA verifying client certificates; enables mTLS
+	DockerSocket string
+	Insecure     bool // Allow the token over plain HTTP
+}
+
+// StatsRollup is a downsampled resolution of the stats history
+type StatsRollup struct {
+	Step      time.Duration
+	Retention time.Duration
 }
 
 // AlertConfig holds configuration for the alerting system
@@ -21,13 +50,75 @@ type AlertConfig struct {
 	MemoryThreshold float64       // 0-100, alert when exceeded
 	CheckInterval   time.Duration // How often to check thresholds
 	AlertsFilter    string        // "all" or "critical"
+
+	CrashLoopRestarts int           // Alert when restarts within CrashLoopWindow exceed this
+	CrashLoopWindow   time.Duration // Sliding window for counting restarts
+
+	ExitLogLines int // Log lines attached to container stopped alerts
+
+	// Host thresholds, 0 disables a check
+	HostDiskThreshold   float64 // 0-100, per mounted filesystem
+	HostMemoryThreshold float64 // 0-100
+	HostLoadThreshold   float64 // 1-minute load average per CPU core
+	HostSwapThreshold   float64 // 0-100
+	HostInodeThreshold  float64 // 0-100, per mounted filesystem
+
+	// Hosts without an agent are read by the stats helper container
+	HostHelperImage    string        // Empty in read-only mode, which disables the helper
+	HostHelperInterval time.Duration // How often those hosts are checked, 0 to not check them
 }
 
 type Config struct {
-	ReadOnly    bool
-	Hostname    string // Optional override for displayed hostname
-	DockerHosts []DockerHost
-	Alerts      AlertConfig
+	ReadOnly      bool
+	Hostname      string // Optional override for displayed hostname
+	DockerHosts   []DockerHost
+	Alerts        AlertConfig
+	UploadMaxSize int64 // Maximum size in bytes of a file uploaded into a container
+
+	HostStatsImage string // Image of the helper container reading /proc on remote hosts
+
+	HostMetricsInterval  time.Duration // How often the local host is sampled
+	HostMetricsRetention time.Duration // How much host metrics history is kept
+
+	DataDir        string        // Directory of persistent data such as stats history
+	StatsInterval  time.Duration // How often container stats are recorded
+	StatsRetention time.Duration // How long raw container stats are kept
+	StatsRollups   []StatsRollup // Downsampled resolutions of container stats
+
+	// A pointer keeps the token out of the startup log of the config
+	Metrics *MetricsConfig
+
+	Agent *AgentConfig
+}
+
+// MetricsConfig configures the Prometheus metrics endpoint and the sinks
+// metrics are pushed to
+type MetricsConfig struct {
+	Token string // Bearer token required to scrape, if set
+	Sinks []MetricSink
+}
+
+// Metric sink types, selected by the scheme of the sink URL
+const (
+	SinkInfluxDB = "influxdb" // http:// or https:// write endpoint
+	SinkGraphite = "graphite" // graphite://host:port, plaintext over TCP
+	SinkStatsD   = "statsd"   // statsd://host:port, over UDP
+)
+
+// MetricSink is a destination the collected samples are pushed to
+type MetricSink struct {
+	Name    string
+	Type    string
+	URL     string // Write endpoint of InfluxDB
+	Address string // host:port of Graphite and StatsD
+	Token   string // InfluxDB API token
+
+	Prefix string            // Prepended to measurement and metric names
+	Tags   map[string]string // Added to every sample
+
+	BatchSize     int           // Samples sent per request
+	FlushInterval time.Duration // How often buffered samples are sent
+	BufferSize    int           // Samples kept while the sink is unreachable
 }
 
 func NewConfig() *Config {
@@ -35,6 +126,33 @@ func NewConfig() *Config {
 	hostname := os.Getenv("HOSTNAME_OVERRIDE") // Custom display hostname
 	dockerHosts := parseDockerHosts()
 	alertConfig := parseAlertConfig()
+	uploadMaxSize := parseUploadMaxSize()
+
+	hostStatsImage := os.Getenv("HOST_STATS_IMAGE")
+	if hostStatsImage == "" {
+		hostStatsImage = "busybox:stable"
+	}
+
+	// Starting helper containers is not allowed in read-only mode
+	if !isReadOnlyMode {
+		alertConfig.HostHelperImage = hostStatsImage
+	}
+
+	hostMetricsInterval := parseDuration("HOST_METRICS_INTERVAL", 10*time.Second)
+	hostMetricsRetention := parseDuration("HOST_METRICS_RETENTION", 24*time.Hour)
+
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	statsInterval := parseDuration("STATS_INTERVAL", 30*time.Second)
+	statsRetention := 48 * time.Hour
+	if value := os.Getenv("STATS_RETENTION"); value != "" {
+		if retention, err := parseLongDuration(value); err == nil && retention > 0 {
+			statsRetention = retention
+		}
+	}
+	statsRollups := parseStatsRollups()
 
 	// if we don't have any docker hosts, we should default back to
 	// the unix socket on the machine running vps-monitor.
@@ -43,11 +161,127 @@ func NewConfig() *Config {
 	}
 
 	return &Config{
-		ReadOnly:    isReadOnlyMode,
-		Hostname:    hostname,
-		DockerHosts: dockerHosts,
-		Alerts:      alertConfig,
+		ReadOnly:      isReadOnlyMode,
+		Hostname:      hostname,
+		DockerHosts:   dockerHosts,
+		Alerts:        alertConfig,
+		UploadMaxSize: uploadMaxSize,
+
+		HostStatsImage: hostStatsImage,
+
+		HostMetricsInterval:  hostMetricsInterval,
+		HostMetricsRetention: hostMetricsRetention,
+
+		DataDir:        dataDir,
+		StatsInterval:  statsInterval,
+		StatsRetention: statsRetention,
+		StatsRollups:   statsRollups,
+
+		Metrics: &MetricsConfig{
+			Token: os.Getenv("METRICS_TOKEN"),
+			Sinks: parseMetricSinks(),
+		},
+
+		Agent: parseAgentConfig(),
+	}
+}
+
+// parseDuration reads a positive duration from the environment, falling back
+// to def when unset or invalid
+func parseDuration(key string, def time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return def
+}
+
+// parseLongDuration parses a Go duration, also accepting whole days such as
+// 30d
+func parseLongDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// parseStatsRollups reads the rollup resolutions and their retention.
+// Format: STATS_ROLLUPS=1m=7d,5m=30d,1h=90d,1d=365d
+func parseStatsRollups() []StatsRollup {
+	rollups := []StatsRollup{
+		{Step: time.Minute, Retention: 7 * 24 * time.Hour},
+		{Step: 5 * time.Minute, Retention: 30 * 24 * time.Hour},
+		{Step: time.Hour, Retention: 90 * 24 * time.Hour},
+		{Step: 24 * time.Hour, Retention: 365 * 24 * time.Hour},
+	}
+
+	value := os.Getenv("STATS_ROLLUPS")
+	if value == "" {
+		return rollups
+	}
+
+	configured := []StatsRollup{}
+	for _, entry := range strings.Split(value, ",") {
+		stepStr, retentionStr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			log.Printf("Ignoring invalid STATS_ROLLUPS entry %q, expected step=retention", entry)
+			continue
+		}
+		step, err := parseLongDuration(stepStr)
+		if err != nil || step < time.Second {
+			log.Printf("Ignoring STATS_ROLLUPS entry %q with an invalid step", entry)
+			continue
+		}
+		retention, err := parseLongDuration(retentionStr)
+		if err != nil || retention < 0 {
+			log.Printf("Ignoring STATS_ROLLUPS entry %q with an invalid retention", entry)
+			continue
+		}
+		// A retention of 0 disables the resolution
+		if retention > 0 {
+			configured = append(configured, StatsRollup{Step: step, Retention: retention})
+		}
+	}
+	return configured
+}
+
+func parseAgentConfig() *AgentConfig {
+	config := &AgentConfig{
+		Listen:       os.Getenv("AGENT_LISTEN"),
+		Token:        os.Getenv("AGENT_TOKEN"),
+		TLSCert:      os.Getenv("AGENT_TLS_CERT"),
+		TLSKey:       os.Getenv("AGENT_TLS_KEY"),
+		TLSClientCA:  os.Getenv("AGENT_TLS_CLIENT_CA"),
+		DockerSocket: os.Getenv("AGENT_DOCKER_SOCKET"),
+		Insecure:     os.Getenv("AGENT_INSECURE") == "true",
 	}
+
+	if config.Listen == "" {
+		config.Listen = ":6790"
+	}
+
+	if config.DockerSocket == "" {
+		config.DockerSocket = "/var/run/docker.sock"
+	}
+
+	return config
+}
+
+func parseUploadMaxSize() int64 {
+	maxSizeMB := int64(50) // Default: 50 MB
+
+	if sizeStr := os.Getenv("UPLOAD_MAX_SIZE_MB"); sizeStr != "" {
+		if size, err := strconv.ParseInt(sizeStr, 10, 64); err == nil && size > 0 {
+			maxSizeMB = size
+		}
+	}
+
+	return maxSizeMB << 20
 }
 
 func parseAlertConfig() AlertConfig {
@@ -58,6 +292,17 @@ func parseAlertConfig() AlertConfig {
 		MemoryThreshold: 90, // Default: 90%
 		CheckInterval:   30 * time.Second,
 		AlertsFilter:    "all",
+
+		CrashLoopRestarts: 3,
+		CrashLoopWindow:   10 * time.Minute,
+
+		ExitLogLines: 20,
+
+		HostDiskThreshold:   90,
+		HostMemoryThreshold: 90,
+		HostLoadThreshold:   2,
+		HostSwapThreshold:   80,
+		HostInodeThreshold:  90,
 	}
 
 	if filter := os.Getenv("ALERTS_FILTER"); filter != "" {
@@ -82,11 +327,151 @@ func parseAlertConfig() AlertConfig {
 		}
 	}
 
+	if restartsStr := os.Getenv("ALERTS_CRASHLOOP_RESTARTS"); restartsStr != "" {
+		if restarts, err := strconv.Atoi(restartsStr); err == nil && restarts > 0 {
+			config.CrashLoopRestarts = restarts
+		}
+	}
+
+	if windowStr := os.Getenv("ALERTS_CRASHLOOP_WINDOW"); windowStr != "" {
+		if window, err := time.ParseDuration(windowStr); err == nil && window > 0 {
+			config.CrashLoopWindow = window
+		}
+	}
+
+	if linesStr := os.Getenv("ALERTS_EXIT_LOG_LINES"); linesStr != "" {
+		if lines, err := strconv.Atoi(linesStr); err == nil && lines >= 0 {
+			config.ExitLogLines = lines
+		}
+	}
+
+	config.HostDiskThreshold = parseHostThreshold("ALERTS_HOST_DISK_THRESHOLD", config.HostDiskThreshold, 100)
+	config.HostMemoryThreshold = parseHostThreshold("ALERTS_HOST_MEMORY_THRESHOLD", config.HostMemoryThreshold, 100)
+	config.HostLoadThreshold = parseHostThreshold("ALERTS_HOST_LOAD_THRESHOLD", config.HostLoadThreshold, 0)
+	config.HostSwapThreshold = parseHostThreshold("ALERTS_HOST_SWAP_THRESHOLD", config.HostSwapThreshold, 100)
+	config.HostInodeThreshold = parseHostThreshold("ALERTS_HOST_INODE_THRESHOLD", config.HostInodeThreshold, 100)
+	// Opt-in, since the helper bind-mounts the root of every checked host
+	config.HostHelperInterval = parseDuration("ALERTS_HOST_HELPER_INTERVAL", 0)
+
 	return config
 }
 
+// parseHostThreshold reads a host alert threshold. 0 disables the check and
+// limit, when set, is the highest accepted value.
+func parseHostThreshold(key string, def, limit float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if threshold, err := strconv.ParseFloat(value, 64); err == nil && threshold >= 0 && (limit == 0 || threshold <= limit) {
+			return threshold
+		}
+	}
+	return def
+}
+
+// parseMetricSinks reads the push destinations of metrics. Each sink is
+// further configured by METRICS_SINK_<NAME>_* variables.
+// Format: METRICS_SINKS=influx=http://influxdb:8086/api/v2/write?org=acme&bucket=vps,graphite=graphite://graphite:2003,statsd=statsd://statsd:8125
+func parseMetricSinks() []MetricSink {
+	value := os.Getenv("METRICS_SINKS")
+	if value == "" {
+		return nil
+	}
+
+	sinks := []MetricSink{}
+	for _, entry := range strings.Split(value, ",") {
+		name, rawURL, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			log.Printf("Ignoring invalid METRICS_SINKS entry %q, expected name=url", entry)
+			continue
+		}
+
+		u, err := url.Parse(strings.TrimSpace(rawURL))
+		if err != nil || u.Host == "" {
+			log.Printf("Ignoring METRICS_SINKS entry %q with an invalid URL", entry)
+			continue
+		}
+
+		sink := MetricSink{Name: name}
+		switch u.Scheme {
+		case "http", "https":
+			sink.Type = SinkInfluxDB
+			sink.URL = u.String()
+		case "graphite":
+			sink.Type = SinkGraphite
+			sink.Address = hostWithDefaultPort(u, "2003")
+		case "statsd":
+			sink.Type = SinkStatsD
+			sink.Address = hostWithDefaultPort(u, "8125")
+		default:
+			log.Printf("Ignoring METRICS_SINKS entry %q, expected an http(s)://, graphite:// or statsd:// URL", entry)
+			continue
+		}
+
+		env := "METRICS_SINK_" + envName(name) + "_"
+		sink.Token = os.Getenv(env + "TOKEN")
+		sink.Prefix = "vps_monitor"
+		if prefix, set := os.LookupEnv(env + "PREFIX"); set {
+			sink.Prefix = prefix
+		}
+		sink.Tags = parseTags(env+"TAGS", os.Getenv(env+"TAGS"))
+		sink.BatchSize = parsePositiveInt(env+"BATCH_SIZE", 1000)
+		sink.FlushInterval = parseDuration(env+"FLUSH_INTERVAL", 10*time.Second)
+		sink.BufferSize = max(parsePositiveInt(env+"BUFFER_SIZE", 10000), sink.BatchSize)
+
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// hostWithDefaultPort returns host:port of u, adding port when u has none
+func hostWithDefaultPort(u *url.URL, port string) string {
+	if u.Port() == "" {
+		return u.Host + ":" + port
+	}
+	return u.Host
+}
+
+// envName turns a name into the form used in environment variable names
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+}
+
+// parseTags reads comma-separated key=value pairs
+func parseTags(key, value string) map[string]string {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			log.Printf("Ignoring invalid %s entry %q, expected key=value", key, pair)
+			continue
+		}
+		tags[k] = v
+	}
+	return tags
+}
+
+// parsePositiveInt reads a positive integer from the environment, falling
+// back to def when unset or invalid
+func parsePositiveInt(key string, def int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
 func parseDockerHosts() []DockerHost {
-	// Format: DOCKER_HOSTS=local=unix:///var/run/docker.sock,remote=ssh://root@X.X.X.X
+	// Format: DOCKER_HOSTS=local=unix:///var/run/docker.sock,remote=ssh://root@X.X.X.X,vps=agent://X.X.X.X:6790
 	dockerHosts := os.Getenv("DOCKER_HOSTS")
 	if dockerHosts == "" {
 		return []DockerHost{}
@@ -107,7 +492,24 @@ func parseDockerHosts() []DockerHost {
 			log.Fatalf("Invalid DOCKER_HOSTS format: %s (name and host cannot be empty)", dockerHostString)
 		}
 
-		dockerHostsList = append(dockerHostsList, DockerHost{Name: name, Host: host})
+		dockerHost := DockerHost{Name: name, Host: host}
+		if strings.HasPrefix(host, "agent://") {
+			dockerHost.Agent = &AgentClientConfig{
+				Token:    os.Getenv("AGENT_TOKEN"),
+				CAFile:   os.Getenv("AGENT_CA"),
+				CertFile: os.Getenv("AGENT_CLIENT_CERT"),
+				KeyFile:  os.Getenv("AGENT_CLIENT_KEY"),
+				Insecure: os.Getenv("AGENT_INSECURE") == "true",
+			}
+			if dockerHost.Agent.Token == "" && dockerHost.Agent.CertFile == "" {
+				log.Fatalf("Agent host %s requires AGENT_TOKEN or AGENT_CLIENT_CERT", name)
+			}
+			if dockerHost.Agent.Insecure && (dockerHost.Agent.CAFile != "" || dockerHost.Agent.CertFile != "") {
+				log.Fatalf("Agent host %s: AGENT_INSECURE cannot be combined with AGENT_CA or AGENT_CLIENT_CERT", name)
+			}
+		}
+
+		dockerHostsList = append(dockerHostsList, dockerHost)
 	}
 
 	return dockerHostsList
@@ -0,0 +1,184 @@
+package docker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/hhftechnology/vps-monitor/internal/config"
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// AgentDockerPath is the path prefix under which an agent proxies the Docker
+// API of its host
+const AgentDockerPath = "/docker"
+
+// ErrNotAgentHost is returned for operations only agents support
+var ErrNotAgentHost = errors.New("host is not an agent host")
+
+// agentEndpoint holds what is needed to call the non-Docker API of an agent
+type agentEndpoint struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// newAgentClient creates a Docker client talking to an agent, which forwards
+// requests to the Docker daemon of its host
+func newAgentClient(host config.DockerHost, recorder *apiRecorder) (*client.Client, *agentEndpoint, error) {
+	address := strings.TrimSuffix(strings.TrimPrefix(host.Host, "agent://"), "/")
+	if address == "" {
+		return nil, nil, fmt.Errorf("invalid agent address: %s", host.Host)
+	}
+
+	tlsConfig, err := agentTLSConfig(host.Agent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The token must never travel in clear text, so agents are reached over
+	// TLS unless explicitly configured otherwise
+	scheme := "https"
+	if host.Agent.Insecure {
+		scheme = "http"
+	}
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	var headers map[string]string
+	if host.Agent.Token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + host.Agent.Token}
+	}
+
+	apiClient, err := client.NewClientWithOpts(
+		client.WithHTTPClient(httpClient),
+		client.WithHost("tcp://"+address+AgentDockerPath),
+		client.WithScheme(scheme),
+		client.WithHTTPHeaders(headers),
+		client.WithAPIVersionNegotiation(),
+		client.WithTraceProvider(recorder),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return apiClient, &agentEndpoint{
+		baseURL:    scheme + "://" + address,
+		token:      host.Agent.Token,
+		httpClient: httpClient,
+	}, nil
+}
+
+// agentTLSConfig builds the TLS configuration for an agent, or nil when the
+// agent is reached over plain HTTP. Without a CA, the agent certificate is
+// verified against the system roots.
+func agentTLSConfig(agent *config.AgentClientConfig) (*tls.Config, error) {
+	if agent.Insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if agent.CAFile != "" {
+		caPEM, err := os.ReadFile(agent.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read agent CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("agent CA %s contains no certificate", agent.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if agent.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(agent.CertFile, agent.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load agent client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// getAgentSystemStats returns the system stats reported by an agent
+func (e *agentEndpoint) getAgentSystemStats(ctx context.Context) (*system.SystemStats, error) {
+	var stats system.SystemStats
+	if err := e.getJSON(ctx, "/system/stats", &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// getJSON fetches path from the agent and decodes the JSON response into v
+func (e *agentEndpoint) getJSON(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if e.token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.token)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("agent returned status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// GetHostMetrics returns detailed metrics of an agent host. Only agents can
+// report them, as they need gopsutil running on the host.
+func (c *MultiHostClient) GetHostMetrics(ctx context.Context, hostName string) (*system.HostMetrics, error) {
+	if _, err := c.GetClient(hostName); err != nil {
+		return nil, err
+	}
+
+	agent, ok := c.agents[hostName]
+	if !ok {
+		return nil, ErrNotAgentHost
+	}
+
+	var metrics system.HostMetrics
+	if err := agent.getJSON(ctx, "/system/metrics", &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// GetHostProcesses returns the top processes of an agent host
+func (c *MultiHostClient) GetHostProcesses(ctx context.Context, hostName string, query url.Values) ([]system.Process, error) {
+	if _, err := c.GetClient(hostName); err != nil {
+		return nil, err
+	}
+
+	agent, ok := c.agents[hostName]
+	if !ok {
+		return nil, ErrNotAgentHost
+	}
+
+	var processes []system.Process
+	if err := agent.getJSON(ctx, "/system/processes?"+query.Encode(), &processes); err != nil {
+		return nil, err
+	}
+	return processes, nil
+}
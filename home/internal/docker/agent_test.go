@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hhftechnology/vps-monitor/internal/config"
+)
+
+func TestNewAgentClientScheme(t *testing.T) {
+	tests := []struct {
+		name       string
+		agent      config.AgentClientConfig
+		wantScheme string
+	}{
+		{"token only uses the system roots", config.AgentClientConfig{Token: "secret"}, "https"},
+		{"insecure", config.AgentClientConfig{Token: "secret", Insecure: true}, "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := config.DockerHost{Name: "vps", Host: "agent://vps.example.com:6790", Agent: &tt.agent}
+			apiClient, endpoint, err := newAgentClient(host, newAPIRecorder())
+			if err != nil {
+				t.Fatalf("newAgentClient() error = %v", err)
+			}
+			defer apiClient.Close()
+
+			if !strings.HasPrefix(endpoint.baseURL, tt.wantScheme+"://") {
+				t.Errorf("got base URL %s, want scheme %s", endpoint.baseURL, tt.wantScheme)
+			}
+			if got := apiClient.DaemonHost(); got != "tcp://vps.example.com:6790"+AgentDockerPath {
+				t.Errorf("got Docker host %s", got)
+			}
+
+			tlsConfig, err := agentTLSConfig(&tt.agent)
+			if err != nil {
+				t.Fatalf("agentTLSConfig() error = %v", err)
+			}
+			if (tlsConfig != nil) != (tt.wantScheme == "https") {
+				t.Errorf("agentTLSConfig() = %v for scheme %s", tlsConfig, tt.wantScheme)
+			}
+			if tlsConfig != nil && tlsConfig.RootCAs != nil {
+				t.Error("agentTLSConfig() without a CA does not use the system roots")
+			}
+		})
+	}
+
+	if _, err := agentTLSConfig(&config.AgentClientConfig{CAFile: "/nonexistent/ca.pem"}); err == nil {
+		t.Error("agentTLSConfig() with a missing CA succeeded")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 
@@ -17,10 +18,18 @@ import (
 type MultiHostClient struct {
 	clients map[string]*client.Client
 	hosts   []config.DockerHost
+	agents  map[string]*agentEndpoint
+
+	hostStats   map[string]*hostStatsEntry
+	hostStatsMu sync.Mutex
+
+	recorders map[string]*apiRecorder
 }
 
 func NewMultiHostClient(hosts []config.DockerHost) (*MultiHostClient, error) {
 	clients := make(map[string]*client.Client)
+	agents := make(map[string]*agentEndpoint)
+	recorders := make(map[string]*apiRecorder)
 
 	for _, host := range hosts {
 		var (
@@ -28,7 +37,16 @@ func NewMultiHostClient(hosts []config.DockerHost) (*MultiHostClient, error) {
 			err       error
 		)
 
-		if strings.HasPrefix(host.Host, "ssh://") {
+		recorder := newAPIRecorder()
+		recorders[host.Name] = recorder
+
+		if host.Agent != nil {
+			var agent *agentEndpoint
+			apiClient, agent, err = newAgentClient(host, recorder)
+			if err == nil {
+				agents[host.Name] = agent
+			}
+		} else if strings.HasPrefix(host.Host, "ssh://") {
 			helper, helperErr := connhelper.GetConnectionHelper(host.Host)
 			if helperErr != nil {
 				return nil, fmt.Errorf("failed to setup SSH helper for host %s (%s): %w", host.Name, host.Host, helperErr)
@@ -45,12 +63,14 @@ func NewMultiHostClient(hosts []config.DockerHost) (*MultiHostClient, error) {
 				client.WithHost(helper.Host),
 				client.WithDialContext(helper.Dialer),
 				client.WithAPIVersionNegotiation(),
+				client.WithTraceProvider(recorder),
 			)
 		} else {
 			apiClient, err = client.NewClientWithOpts(
 				client.WithHost(host.Host),
 				client.WithAPIVersionNegotiation(),
 				client.FromEnv,
+				client.WithTraceProvider(recorder),
 			)
 		}
 
@@ -61,8 +81,11 @@ func NewMultiHostClient(hosts []config.DockerHost) (*MultiHostClient, error) {
 	}
 
 	return &MultiHostClient{
-		clients: clients,
-		hosts:   hosts,
+		clients:   clients,
+		hosts:     hosts,
+		agents:    agents,
+		hostStats: make(map[string]*hostStatsEntry),
+		recorders: recorders,
 	}, nil
 }
 
@@ -79,6 +102,13 @@ type hostResult struct {
 }
 
 func (c *MultiHostClient) ListContainersAllHosts(ctx context.Context) (map[string][]models.ContainerInfo, []HostError, error) {
+	return c.ListContainersAllHostsWithSize(ctx, false)
+}
+
+// ListContainersAllHostsWithSize lists containers on all hosts. When withSize
+// is set the daemons also compute SizeRw and SizeRootFs, which is slow on
+// hosts with many containers.
+func (c *MultiHostClient) ListContainersAllHostsWithSize(ctx context.Context, withSize bool) (map[string][]models.ContainerInfo, []HostError, error) {
 	numHosts := len(c.clients)
 	if numHosts == 0 {
 		return make(map[string][]models.ContainerInfo), nil, nil
@@ -93,7 +123,7 @@ func (c *MultiHostClient) ListContainersAllHosts(ctx context.Context) (map[strin
 		wg.Add(1)
 		go func(name string, client *client.Client) {
 			defer wg.Done()
-			c.queryHost(ctx, name, client, resultCh)
+			c.queryHost(ctx, name, client, withSize, resultCh)
 		}(hostName, apiClient)
 	}
 
@@ -119,8 +149,8 @@ func (c *MultiHostClient) ListContainersAllHosts(ctx context.Context) (map[strin
 }
 
 // queryHost queries a single Docker host and sends result to channel
-func (c *MultiHostClient) queryHost(ctx context.Context, hostName string, apiClient *client.Client, resultCh chan<- hostResult) {
-	containers, err := apiClient.ContainerList(ctx, container.ListOptions{All: true})
+func (c *MultiHostClient) queryHost(ctx context.Context, hostName string, apiClient *client.Client, withSize bool, resultCh chan<- hostResult) {
+	containers, err := apiClient.ContainerList(ctx, container.ListOptions{All: true, Size: withSize})
 	if err != nil {
 		resultCh <- hostResult{hostName: hostName, err: err}
 		return
@@ -128,7 +158,7 @@ func (c *MultiHostClient) queryHost(ctx context.Context, hostName string, apiCli
 
 	hostContainers := make([]models.ContainerInfo, 0, len(containers))
 	for _, ctr := range containers {
-		hostContainers = append(hostContainers, models.ContainerInfo{
+		info := models.ContainerInfo{
 			ID:      ctr.ID,
 			Names:   ctr.Names,
 			Image:   ctr.Image,
@@ -139,12 +169,108 @@ func (c *MultiHostClient) queryHost(ctx context.Context, hostName string, apiCli
 			Status:  ctr.Status,
 			Labels:  ctr.Labels,
 			Host:    hostName,
-		})
+			Health:  parseHealthStatus(ctr.Status),
+
+			Ports:    containerPorts(ctr.Ports),
+			Mounts:   containerMounts(ctr.Mounts),
+			Networks: containerNetworks(ctr.NetworkSettings),
+		}
+
+		if withSize {
+			sizeRw, sizeRootFs := ctr.SizeRw, ctr.SizeRootFs
+			info.SizeRw = &sizeRw
+			info.SizeRootFs = &sizeRootFs
+		}
+
+		// The list endpoint only reports health in the status text, so only
+		// containers with a healthcheck are inspected for the failing streak
+		if info.Health != "" {
+			if inspect, err := apiClient.ContainerInspect(ctx, ctr.ID); err == nil {
+				if health := ContainerHealthFromState(inspect.State); health != nil {
+					info.Health = health.Status
+					info.FailingStreak = health.FailingStreak
+				}
+			}
+		}
+
+		hostContainers = append(hostContainers, info)
 	}
 
 	resultCh <- hostResult{hostName: hostName, containers: hostContainers}
 }
 
+// containerPorts converts the ports of a container summary
+func containerPorts(ports []container.Port) []models.ContainerPort {
+	result := make([]models.ContainerPort, 0, len(ports))
+	for _, port := range ports {
+		result = append(result, models.ContainerPort{
+			HostIP:        port.IP,
+			HostPort:      port.PublicPort,
+			ContainerPort: port.PrivatePort,
+			Protocol:      port.Type,
+		})
+	}
+	return result
+}
+
+// containerMounts converts the mounts of a container summary
+func containerMounts(mounts []container.MountPoint) []models.ContainerMount {
+	result := make([]models.ContainerMount, 0, len(mounts))
+	for _, mount := range mounts {
+		result = append(result, models.ContainerMount{
+			Type:        string(mount.Type),
+			Name:        mount.Name,
+			Source:      mount.Source,
+			Destination: mount.Destination,
+			RW:          mount.RW,
+		})
+	}
+	return result
+}
+
+// containerNetworks converts the networks of a container summary, sorted by
+// name
+func containerNetworks(settings *container.NetworkSettingsSummary) []models.ContainerNetwork {
+	result := []models.ContainerNetwork{}
+	if settings == nil {
+		return result
+	}
+
+	for name, endpoint := range settings.Networks {
+		if endpoint == nil {
+			continue
+		}
+		result = append(result, models.ContainerNetwork{
+			Name:        name,
+			NetworkID:   endpoint.NetworkID,
+			IPAddress:   endpoint.IPAddress,
+			IPv6Address: endpoint.GlobalIPv6Address,
+			Gateway:     endpoint.Gateway,
+			MacAddress:  endpoint.MacAddress,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// parseHealthStatus extracts the healthcheck status from a container status
+// such as "Up 5 minutes (healthy)"
+func parseHealthStatus(status string) string {
+	switch {
+	case strings.HasSuffix(status, "(healthy)"):
+		return string(container.Healthy)
+	case strings.HasSuffix(status, "(unhealthy)"):
+		return string(container.Unhealthy)
+	case strings.HasSuffix(status, "(health: starting)"):
+		return string(container.Starting)
+	default:
+		return ""
+	}
+}
+
 func (c *MultiHostClient) GetClient(hostName string) (*client.Client, error) {
 	apiClient, ok := c.clients[hostName]
 	if !ok {
@@ -2,11 +2,19 @@ package docker
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"maps"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/network"
+	"github.com/hhftechnology/vps-monitor/internal/models"
 )
 
 func (c *MultiHostClient) GetContainer(ctx context.Context, hostName, id string) (container.InspectResponse, error) {
@@ -21,6 +29,79 @@ func (c *MultiHostClient) GetContainer(ctx context.Context, hostName, id string)
 	return result, nil
 }
 
+// GetContainerHealth returns the healthcheck state of a container, or nil when
+// the container has no healthcheck
+func (c *MultiHostClient) GetContainerHealth(ctx context.Context, hostName, id string) (*models.ContainerHealth, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+	inspect, err := apiClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ContainerHealthFromState(inspect.State), nil
+}
+
+// ContainerHealthFromState converts Docker's health state, including the
+// output of the most recent probes
+func ContainerHealthFromState(state *container.State) *models.ContainerHealth {
+	if state == nil || state.Health == nil || state.Health.Status == container.NoHealthcheck {
+		return nil
+	}
+
+	health := &models.ContainerHealth{
+		Status:        string(state.Health.Status),
+		FailingStreak: state.Health.FailingStreak,
+		Probes:        make([]models.HealthProbe, 0, len(state.Health.Log)),
+	}
+	for _, probe := range state.Health.Log {
+		if probe == nil {
+			continue
+		}
+		health.Probes = append(health.Probes, models.HealthProbe{
+			Start:    probe.Start.Unix(),
+			End:      probe.End.Unix(),
+			ExitCode: probe.ExitCode,
+			Output:   strings.TrimSpace(probe.Output),
+		})
+	}
+	return health
+}
+
+// GetContainerStarts returns the times containers on a host were started
+// between since and until, by container ID. It reads the daemon's event log,
+// so a single call covers every container.
+func (c *MultiHostClient) GetContainerStarts(ctx context.Context, hostName string, since, until time.Time) (map[string][]time.Time, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, errs := apiClient.Events(ctx, events.ListOptions{
+		Since: fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+		Until: fmt.Sprintf("%d.%09d", until.Unix(), until.Nanosecond()),
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("event", string(events.ActionStart)),
+		),
+	})
+
+	starts := make(map[string][]time.Time)
+	for {
+		select {
+		case msg := <-messages:
+			starts[msg.Actor.ID] = append(starts[msg.Actor.ID], time.Unix(0, msg.TimeNano))
+		case err := <-errs:
+			// The stream ends with EOF once until has passed
+			if errors.Is(err, io.EOF) {
+				return starts, nil
+			}
+			return nil, err
+		}
+	}
+}
+
 func (c *MultiHostClient) StartContainer(ctx context.Context, hostName, id string) error {
 	apiClient, err := c.GetClient(hostName)
 	if err != nil {
@@ -146,3 +227,114 @@ func (c *MultiHostClient) SetEnvVariables(ctx context.Context, hostName, id stri
 
 	return resp.ID, nil
 }
+
+// GetContainerProcesses lists the processes running in a container. psArgs is
+// passed to ps on the Docker host and defaults to "-ef" when empty.
+func (c *MultiHostClient) GetContainerProcesses(ctx context.Context, hostName, id, psArgs string) (*models.ContainerProcesses, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	var arguments []string
+	if psArgs != "" {
+		arguments = []string{psArgs}
+	}
+
+	top, err := apiClient.ContainerTop(ctx, id, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	processes := top.Processes
+	if processes == nil {
+		processes = [][]string{}
+	}
+
+	return &models.ContainerProcesses{
+		Titles:    top.Titles,
+		Processes: processes,
+	}, nil
+}
+
+// GetContainerDiff returns the paths added, changed or deleted in a container's
+// writable layer
+func (c *MultiHostClient) GetContainerDiff(ctx context.Context, hostName, id string) (*models.FilesystemDiff, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := apiClient.ContainerDiff(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &models.FilesystemDiff{
+		Changes: make([]models.FilesystemChange, 0, len(changes)),
+	}
+	for _, change := range changes {
+		var kind string
+		switch change.Kind {
+		case container.ChangeAdd:
+			kind = "added"
+			diff.Added++
+		case container.ChangeDelete:
+			kind = "deleted"
+			diff.Deleted++
+		default:
+			kind = "changed"
+			diff.Changed++
+		}
+		diff.Changes = append(diff.Changes, models.FilesystemChange{
+			Path: change.Path,
+			Kind: kind,
+		})
+	}
+
+	return diff, nil
+}
+
+// GetExitDiagnostics returns why a container stopped along with its last
+// logLines log lines. Logs are skipped when logLines is zero.
+func (c *MultiHostClient) GetExitDiagnostics(ctx context.Context, hostName, id string, logLines int) (*models.ExitDiagnostics, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := apiClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := &models.ExitDiagnostics{}
+	if inspect.ContainerJSONBase != nil && inspect.State != nil {
+		diagnostics.ExitCode = inspect.State.ExitCode
+		diagnostics.OOMKilled = inspect.State.OOMKilled
+		diagnostics.Error = inspect.State.Error
+		if finishedAt, err := time.Parse(time.RFC3339Nano, inspect.State.FinishedAt); err == nil && !finishedAt.IsZero() {
+			diagnostics.FinishedAt = finishedAt.Unix()
+		}
+	}
+
+	if logLines > 0 {
+		logs, err := apiClient.ContainerLogs(ctx, id, container.LogsOptions{
+			Timestamps: true,
+			Tail:       strconv.Itoa(logLines),
+			ShowStdout: true,
+			ShowStderr: true,
+		})
+		if err != nil {
+			return diagnostics, err
+		}
+		defer logs.Close()
+
+		diagnostics.Logs, err = parseDockerLogs(logs)
+		if err != nil {
+			return diagnostics, err
+		}
+	}
+
+	return diagnostics, nil
+}
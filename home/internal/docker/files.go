@@ -0,0 +1,274 @@
+package docker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// StatContainerPath returns information about a path inside a container
+func (c *MultiHostClient) StatContainerPath(ctx context.Context, hostName, id, filePath string) (*models.FileEntry, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := apiClient.ContainerStatPath(ctx, id, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := fileEntryFromMode(stat.Name, path.Clean(filePath), stat.Mode, stat.Size, stat.Mtime, stat.LinkTarget)
+	return &entry, nil
+}
+
+// maxListingBytes caps the archive read to list a directory. The archive API
+// always returns the whole subtree, so listing / would otherwise stream the
+// entire container filesystem.
+const maxListingBytes = 32 << 20
+
+// errListingLimit stops reading the archive of a directory listing
+var errListingLimit = errors.New("directory listing limit reached")
+
+// limitedReader fails with errListingLimit once more than n bytes were read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, errListingLimit
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// ListContainerDirectory lists the direct children of a directory inside a
+// container. Entries are collected from the archive of the subtree; when it
+// exceeds maxListingBytes the listing is returned truncated.
+func (c *MultiHostClient) ListContainerDirectory(ctx context.Context, hostName, id, dirPath string) (*models.DirectoryListing, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	dirPath = path.Clean("/" + dirPath)
+
+	reader, stat, err := apiClient.CopyFromContainer(ctx, id, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if !stat.Mode.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dirPath)
+	}
+
+	listing := &models.DirectoryListing{
+		Path:    dirPath,
+		Entries: []models.FileEntry{},
+	}
+
+	tr := tar.NewReader(&limitedReader{r: reader, n: maxListingBytes})
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errListingLimit) {
+			listing.Truncated = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Entries are rooted at the base name of the requested directory
+		name := strings.Trim(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if dirPath != "/" {
+			_, name, _ = strings.Cut(name, "/")
+		}
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+
+		listing.Entries = append(listing.Entries, fileEntryFromMode(
+			name,
+			path.Join(dirPath, name),
+			hdr.FileInfo().Mode(),
+			hdr.Size,
+			hdr.ModTime,
+			hdr.Linkname,
+		))
+	}
+
+	sort.Slice(listing.Entries, func(i, j int) bool {
+		a, b := listing.Entries[i], listing.Entries[j]
+		if (a.Type == "dir") != (b.Type == "dir") {
+			return a.Type == "dir"
+		}
+		return a.Name < b.Name
+	})
+
+	return listing, nil
+}
+
+// CopyFromContainer returns a tar stream of a path inside a container along
+// with information about the path
+func (c *MultiHostClient) CopyFromContainer(ctx context.Context, hostName, id, srcPath string) (io.ReadCloser, *models.FileEntry, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, stat, err := apiClient.CopyFromContainer(ctx, id, srcPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry := fileEntryFromMode(stat.Name, path.Clean(srcPath), stat.Mode, stat.Size, stat.Mtime, stat.LinkTarget)
+	return reader, &entry, nil
+}
+
+// CopyFileToContainer writes a single file into a container. The content is
+// streamed into a tar archive, so size must match the number of bytes content
+// will yield.
+func (c *MultiHostClient) CopyFileToContainer(ctx context.Context, hostName, id, dstPath string, content io.Reader, size int64) error {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return err
+	}
+
+	dstPath = path.Clean("/" + dstPath)
+	dir, name := path.Split(dstPath)
+	if name == "" {
+		return fmt.Errorf("invalid destination path: %s", dstPath)
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+
+	go func() {
+		tw := tar.NewWriter(pipeWriter)
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o644,
+			Size:     size,
+			ModTime:  time.Now(),
+			Typeflag: tar.TypeReg,
+		})
+		if err == nil {
+			_, err = io.CopyN(tw, content, size)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	err = apiClient.CopyToContainer(ctx, id, dir, pipeReader, container.CopyToContainerOptions{})
+	// Unblock the tar writer if the daemon rejected the upload early
+	pipeReader.CloseWithError(io.ErrClosedPipe)
+	return err
+}
+
+// ExtractFirstFile copies the content of the first regular file in a tar
+// stream to w
+func ExtractFirstFile(w io.Writer, archive io.Reader) error {
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("archive contains no regular file")
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			_, err = io.Copy(w, tr)
+			return err
+		}
+	}
+}
+
+// TarToZip converts a tar stream into a zip archive written to w
+func TarToZip(w io.Writer, archive io.Reader) error {
+	zw := zip.NewWriter(w)
+	tr := tar.NewReader(archive)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		info := hdr.FileInfo()
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			continue
+		}
+
+		zh, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		zh.Name = strings.TrimPrefix(hdr.Name, "/")
+		if info.IsDir() {
+			zh.Name = strings.TrimSuffix(zh.Name, "/") + "/"
+		} else {
+			zh.Method = zip.Deflate
+		}
+
+		fw, err := zw.CreateHeader(zh)
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			if _, err := io.Copy(fw, tr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+// fileEntryFromMode builds a FileEntry from file metadata
+func fileEntryFromMode(name, fullPath string, mode fs.FileMode, size int64, modTime time.Time, linkTarget string) models.FileEntry {
+	fileType := "other"
+	switch {
+	case mode.IsDir():
+		fileType = "dir"
+	case mode&fs.ModeSymlink != 0:
+		fileType = "symlink"
+	case mode.IsRegular():
+		fileType = "file"
+	}
+
+	return models.FileEntry{
+		Name:       name,
+		Path:       fullPath,
+		Type:       fileType,
+		Mode:       mode.String(),
+		Size:       size,
+		ModTime:    modTime.Unix(),
+		LinkTarget: linkTarget,
+	}
+}
@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// hostInfoResult holds the engine information of a single host
+type hostInfoResult struct {
+	hostName string
+	info     *models.HostInfo
+	err      error
+}
+
+// GetHostInfo returns information about the Docker engine of a host
+func (c *MultiHostClient) GetHostInfo(ctx context.Context, hostName string) (*models.HostInfo, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := apiClient.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := apiClient.ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := info.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	return &models.HostInfo{
+		Name:              hostName,
+		DaemonName:        info.Name,
+		EngineVersion:     version.Version,
+		APIVersion:        version.APIVersion,
+		MinAPIVersion:     version.MinAPIVersion,
+		GitCommit:         version.GitCommit,
+		GoVersion:         version.GoVersion,
+		OperatingSystem:   info.OperatingSystem,
+		OSType:            info.OSType,
+		OSVersion:         info.OSVersion,
+		KernelVersion:     info.KernelVersion,
+		Architecture:      info.Architecture,
+		StorageDriver:     info.Driver,
+		LoggingDriver:     info.LoggingDriver,
+		CgroupDriver:      info.CgroupDriver,
+		CgroupVersion:     info.CgroupVersion,
+		DefaultRuntime:    info.DefaultRuntime,
+		DockerRootDir:     info.DockerRootDir,
+		CPUs:              info.NCPU,
+		MemoryTotal:       info.MemTotal,
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		ContainersPaused:  info.ContainersPaused,
+		ContainersStopped: info.ContainersStopped,
+		Images:            info.Images,
+		Warnings:          warnings,
+		Plugins: models.HostPlugins{
+			Volume:        nonNilStrings(info.Plugins.Volume),
+			Network:       nonNilStrings(info.Plugins.Network),
+			Authorization: nonNilStrings(info.Plugins.Authorization),
+			Log:           nonNilStrings(info.Plugins.Log),
+		},
+	}, nil
+}
+
+// GetHostInfoAllHosts returns engine information for all hosts in parallel
+func (c *MultiHostClient) GetHostInfoAllHosts(ctx context.Context) (map[string]*models.HostInfo, []HostError) {
+	resultCh := make(chan hostInfoResult, len(c.clients))
+
+	var wg sync.WaitGroup
+	for hostName := range c.clients {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			info, err := c.GetHostInfo(ctx, name)
+			resultCh <- hostInfoResult{hostName: name, info: info, err: err}
+		}(hostName)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	result := make(map[string]*models.HostInfo, len(c.clients))
+	var hostErrors []HostError
+
+	for hr := range resultCh {
+		if hr.err != nil {
+			hostErrors = append(hostErrors, HostError{HostName: hr.hostName, Err: hr.err})
+			continue
+		}
+		result[hr.hostName] = hr.info
+	}
+
+	return result, hostErrors
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,295 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// hostStatsTTL is how long collected host stats are reused, so frequent
+// polling does not start a helper container per request
+const hostStatsTTL = 10 * time.Second
+
+// hostStatsSeparator separates the sections printed by hostStatsScript
+const hostStatsSeparator = "--vps-monitor--"
+
+// hostStatsScript prints the /proc files needed for host stats. /proc/stat,
+// /proc/meminfo, /proc/loadavg and /proc/uptime are not namespaced, so the
+// helper needs no privileges; the host root is mounted read-only for df and
+// os-release.
+const hostStatsScript = `head -n1 /proc/stat; sleep 1; head -n1 /proc/stat
+echo ` + hostStatsSeparator + `; cat /proc/meminfo
+echo ` + hostStatsSeparator + `; cat /proc/loadavg
+echo ` + hostStatsSeparator + `; cat /proc/uptime
+echo ` + hostStatsSeparator + `; cat /proc/sys/kernel/hostname
+echo ` + hostStatsSeparator + `; cat /proc/sys/kernel/osrelease
+echo ` + hostStatsSeparator + `; uname -m
+echo ` + hostStatsSeparator + `; cat /host/etc/os-release 2>/dev/null
+echo ` + hostStatsSeparator + `; df -kP /host | tail -n1`
+
+// hostStatsEntry caches the stats of one host. mu is held while collecting so
+// concurrent requests share a single helper run.
+type hostStatsEntry struct {
+	mu        sync.Mutex
+	stats     *system.SystemStats
+	fetchedAt time.Time
+}
+
+// ErrStatsHelperDisabled is returned when the stats of a host need a helper
+// container but none may be started
+var ErrStatsHelperDisabled = errors.New("stats of this host need a helper container, which is disabled in read-only mode")
+
+// GetHostSystemStats returns CPU, memory, disk, load and uptime of a Docker
+// host. Agents report their own stats; for other hosts the values are read by
+// a short-lived helper container running helperImage, which is pulled when
+// missing. An empty helperImage disables the helper.
+func (c *MultiHostClient) GetHostSystemStats(ctx context.Context, hostName, helperImage string) (*system.SystemStats, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	if agent, ok := c.agents[hostName]; ok {
+		return agent.getAgentSystemStats(ctx)
+	}
+
+	c.hostStatsMu.Lock()
+	entry, ok := c.hostStats[hostName]
+	if !ok {
+		entry = &hostStatsEntry{}
+		c.hostStats[hostName] = entry
+	}
+	c.hostStatsMu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.stats != nil && time.Since(entry.fetchedAt) < hostStatsTTL {
+		return entry.stats, nil
+	}
+	if helperImage == "" {
+		return nil, ErrStatsHelperDisabled
+	}
+
+	output, err := runStatsHelper(ctx, apiClient, helperImage)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := parseHostStats(output)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.stats = stats
+	entry.fetchedAt = time.Now()
+	return stats, nil
+}
+
+// runStatsHelper runs hostStatsScript in a helper container and returns its
+// output
+func runStatsHelper(ctx context.Context, apiClient *client.Client, helperImage string) (string, error) {
+	if err := ensureImage(ctx, apiClient, helperImage); err != nil {
+		return "", err
+	}
+
+	resp, err := apiClient.ContainerCreate(
+		ctx,
+		&container.Config{
+			Image:      helperImage,
+			Entrypoint: []string{"sh", "-c", hostStatsScript},
+			Labels:     map[string]string{"vps-monitor.stats-helper": "true"},
+		},
+		&container.HostConfig{
+			UTSMode:     "host",
+			NetworkMode: "none",
+			Mounts: []mount.Mount{{
+				Type:     mount.TypeBind,
+				Source:   "/",
+				Target:   "/host",
+				ReadOnly: true,
+			}},
+		},
+		nil,
+		nil,
+		"",
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create stats helper: %w", err)
+	}
+	defer removeHelperContainer(apiClient, resp.ID)
+
+	if err := apiClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return "", fmt.Errorf("failed to start stats helper: %w", err)
+	}
+
+	statusCh, errCh := apiClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return "", err
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return "", fmt.Errorf("stats helper exited with code %d", status.StatusCode)
+		}
+	}
+
+	logs, err := apiClient.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true})
+	if err != nil {
+		return "", err
+	}
+	defer logs.Close()
+
+	var stdout bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, io.Discard, logs); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// ensureImage pulls imageRef on a host unless it is already present
+func ensureImage(ctx context.Context, apiClient *client.Client, imageRef string) error {
+	if _, err := apiClient.ImageInspect(ctx, imageRef); err == nil {
+		return nil
+	}
+
+	reader, err := apiClient.ImagePull(ctx, imageRef, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %w", imageRef, err)
+	}
+	defer reader.Close()
+
+	_, err = DecodeLoadResponse(reader, nil)
+	return err
+}
+
+// parseHostStats converts the output of hostStatsScript into system stats
+func parseHostStats(output string) (*system.SystemStats, error) {
+	sections := strings.Split(output, hostStatsSeparator+"\n")
+	if len(sections) != 9 {
+		return nil, fmt.Errorf("unexpected stats helper output")
+	}
+
+	stats := &system.SystemStats{}
+
+	cpuLines := strings.Split(strings.TrimSpace(sections[0]), "\n")
+	if len(cpuLines) == 2 {
+		idle1, total1 := parseCPULine(cpuLines[0])
+		idle2, total2 := parseCPULine(cpuLines[1])
+		if total2 > total1 {
+			stats.Usage.CPUPercent = 100 * (1 - float64(idle2-idle1)/float64(total2-total1))
+		}
+	}
+
+	// Used memory follows the kernel's estimate of available memory
+	memInfo := parseKeyValues(sections[1], ":")
+	memTotal := parseKB(memInfo["MemTotal"])
+	memAvailable := parseKB(memInfo["MemAvailable"])
+	stats.Usage.MemoryTotal = memTotal
+	if memTotal > 0 && memAvailable <= memTotal {
+		stats.Usage.MemoryUsed = memTotal - memAvailable
+		stats.Usage.MemoryPercent = 100 * float64(stats.Usage.MemoryUsed) / float64(memTotal)
+	}
+
+	if fields := strings.Fields(sections[2]); len(fields) >= 3 {
+		stats.Usage.Load1, _ = strconv.ParseFloat(fields[0], 64)
+		stats.Usage.Load5, _ = strconv.ParseFloat(fields[1], 64)
+		stats.Usage.Load15, _ = strconv.ParseFloat(fields[2], 64)
+	}
+
+	if fields := strings.Fields(sections[3]); len(fields) >= 1 {
+		uptime, _ := strconv.ParseFloat(fields[0], 64)
+		stats.HostInfo.Uptime = uint64(uptime)
+	}
+
+	stats.HostInfo.Hostname = strings.TrimSpace(sections[4])
+	stats.HostInfo.KernelVersion = strings.TrimSpace(sections[5])
+	stats.HostInfo.Arch = normalizeArch(strings.TrimSpace(sections[6]))
+
+	osRelease := parseKeyValues(sections[7], "=")
+	stats.HostInfo.Platform = strings.Trim(osRelease["ID"], `"`)
+	stats.HostInfo.PlatformVersion = strings.Trim(osRelease["VERSION_ID"], `"`)
+
+	// df -kP: filesystem, 1024-blocks, used, available, capacity, mount point
+	if fields := strings.Fields(sections[8]); len(fields) >= 4 {
+		total, _ := strconv.ParseUint(fields[1], 10, 64)
+		used, _ := strconv.ParseUint(fields[2], 10, 64)
+		available, _ := strconv.ParseUint(fields[3], 10, 64)
+		stats.Usage.DiskTotal = total * 1024
+		stats.Usage.DiskUsed = used * 1024
+		if used+available > 0 {
+			stats.Usage.DiskPercent = 100 * float64(used) / float64(used+available)
+		}
+	}
+
+	return stats, nil
+}
+
+// parseCPULine returns the idle and total jiffies of the "cpu" line of
+// /proc/stat
+func parseCPULine(line string) (idle, total uint64) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0
+	}
+
+	// user nice system idle iowait irq softirq steal; guest time is already
+	// included in user and nice
+	for i, field := range fields[1:min(len(fields), 9)] {
+		value, _ := strconv.ParseUint(field, 10, 64)
+		total += value
+		if i == 3 || i == 4 {
+			idle += value
+		}
+	}
+	return idle, total
+}
+
+// parseKeyValues parses "key<sep>value" lines
+func parseKeyValues(text, sep string) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), sep); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// parseKB parses a /proc/meminfo value such as "16314252 kB" into bytes
+func parseKB(value string) uint64 {
+	n, _ := strconv.ParseUint(strings.TrimSuffix(value, " kB"), 10, 64)
+	return n * 1024
+}
+
+// normalizeArch maps uname machine names to Go architecture names, matching
+// the local stats
+func normalizeArch(machine string) string {
+	switch machine {
+	case "x86_64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "i386", "i686":
+		return "386"
+	default:
+		if strings.HasPrefix(machine, "armv") {
+			return "arm"
+		}
+		return machine
+	}
+}
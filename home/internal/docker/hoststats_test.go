@@ -0,0 +1,145 @@
+package docker
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// helperOutput joins the sections of hostStatsScript output
+func helperOutput(sections ...string) string {
+	var b strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString(hostStatsSeparator + "\n")
+		}
+		b.WriteString(section)
+	}
+	return b.String()
+}
+
+func TestParseHostStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    system.SystemStats
+		wantErr bool
+	}{
+		{
+			name: "full output",
+			output: helperOutput(
+				"cpu  100 0 100 700 100 0 0 0 0 0\ncpu  150 0 150 750 150 0 0 0 0 0\n",
+				"MemTotal:        4000000 kB\nMemFree:          500000 kB\nMemAvailable:    1000000 kB\n",
+				"0.50 0.25 0.10 1/200 1234\n",
+				"3600.55 7000.00\n",
+				"web-1\n",
+				"6.1.0-18-amd64\n",
+				"x86_64\n",
+				"PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nID=debian\nVERSION_ID=\"12\"\n",
+				"/dev/sda1 1000 250 750 25% /host\n",
+			),
+			want: system.SystemStats{
+				HostInfo: system.HostInfo{
+					Hostname:        "web-1",
+					Platform:        "debian",
+					PlatformVersion: "12",
+					KernelVersion:   "6.1.0-18-amd64",
+					Arch:            "amd64",
+					Uptime:          3600,
+				},
+				Usage: system.Usage{
+					CPUPercent:    50,
+					MemoryPercent: 75,
+					MemoryTotal:   4000000 * 1024,
+					MemoryUsed:    3000000 * 1024,
+					DiskPercent:   25,
+					DiskTotal:     1000 * 1024,
+					DiskUsed:      250 * 1024,
+					Load1:         0.5,
+					Load5:         0.25,
+					Load15:        0.1,
+				},
+			},
+		},
+		{
+			name:   "empty sections",
+			output: helperOutput("", "", "", "", "", "", "aarch64\n", "", ""),
+			want: system.SystemStats{
+				HostInfo: system.HostInfo{Arch: "arm64"},
+			},
+		},
+		{
+			name:   "single cpu sample",
+			output: helperOutput("cpu  100 0 100 700 100 0 0 0\n", "", "", "", "", "", "", "", ""),
+			want:   system.SystemStats{},
+		},
+		{
+			name:   "available above total",
+			output: helperOutput("", "MemTotal: 100 kB\nMemAvailable: 200 kB\n", "", "", "", "", "", "", ""),
+			want: system.SystemStats{
+				Usage: system.Usage{MemoryTotal: 100 * 1024},
+			},
+		},
+		{
+			name:    "missing sections",
+			output:  helperOutput("", "", ""),
+			wantErr: true,
+		},
+		{
+			name:    "empty output",
+			output:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHostStats(tt.output)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseHostStats() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseHostStats() error = %v", err)
+			}
+
+			if got.HostInfo != tt.want.HostInfo {
+				t.Errorf("HostInfo = %+v, want %+v", got.HostInfo, tt.want.HostInfo)
+			}
+			gotUsage, wantUsage := got.Usage, tt.want.Usage
+			if math.Abs(gotUsage.CPUPercent-wantUsage.CPUPercent) > 1e-9 {
+				t.Errorf("CPUPercent = %v, want %v", gotUsage.CPUPercent, wantUsage.CPUPercent)
+			}
+			gotUsage.CPUPercent, wantUsage.CPUPercent = 0, 0
+			if gotUsage != wantUsage {
+				t.Errorf("Usage = %+v, want %+v", gotUsage, wantUsage)
+			}
+		})
+	}
+}
+
+func TestParseCPULine(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantIdle  uint64
+		wantTotal uint64
+	}{
+		{"cpu  10 20 30 40 50 60 70 80", 90, 360},
+		{"cpu  10 20 30 40 50 60 70 80 90 100", 90, 360}, // Guest time is not added twice
+		{"cpu  1 2 3 4", 4, 10},
+		{"cpu0 10 20 30 40 50", 0, 0},
+		{"cpu 1 2", 0, 0},
+		{"", 0, 0},
+	}
+
+	for _, tt := range tests {
+		idle, total := parseCPULine(tt.line)
+		if idle != tt.wantIdle || total != tt.wantTotal {
+			t.Errorf("parseCPULine(%q) = %d, %d, want %d, %d", tt.line, idle, total, tt.wantIdle, tt.wantTotal)
+		}
+	}
+}
@@ -2,10 +2,16 @@ package docker
 
 import (
 	"context"
+	"encoding/json"
 	"io"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/hhftechnology/vps-monitor/internal/models"
 )
@@ -57,6 +63,7 @@ func (c *MultiHostClient) ListImagesAllHosts(ctx context.Context) (map[string][]
 // dockerClient interface for testing
 type dockerClient interface {
 	ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
 }
 
 // queryImages queries images from a single Docker host
@@ -67,6 +74,18 @@ func (c *MultiHostClient) queryImages(ctx context.Context, hostName string, apiC
 		return
 	}
 
+	containers, err := apiClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		resultCh <- imageResult{hostName: hostName, err: err}
+		return
+	}
+
+	// Count containers per image so unused images can be flagged
+	usage := make(map[string]int, len(images))
+	for _, ctr := range containers {
+		usage[ctr.ImageID]++
+	}
+
 	hostImages := make([]models.ImageInfo, 0, len(images))
 	for _, img := range images {
 		hostImages = append(hostImages, models.ImageInfo{
@@ -78,20 +97,44 @@ func (c *MultiHostClient) queryImages(ctx context.Context, hostName string, apiC
 			Created:     img.Created,
 			Labels:      img.Labels,
 			Host:        hostName,
+			Containers:  usage[img.ID],
+			Unused:      usage[img.ID] == 0,
+			Dangling:    isDanglingImage(img.RepoTags),
 		})
 	}
 
 	resultCh <- imageResult{hostName: hostName, images: hostImages}
 }
 
-// GetImage returns details of a specific image
-func (c *MultiHostClient) GetImage(ctx context.Context, hostName, imageID string) (*models.ImageInfo, error) {
+// isDanglingImage reports whether an image has no usable tag
+func isDanglingImage(repoTags []string) bool {
+	for _, tag := range repoTags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// GetImage returns details of a specific image, including its layer history
+// and the containers on the host that use it
+func (c *MultiHostClient) GetImage(ctx context.Context, hostName, imageID string) (*models.ImageDetails, error) {
 	apiClient, err := c.GetClient(hostName)
 	if err != nil {
 		return nil, err
 	}
 
-	inspect, _, err := apiClient.ImageInspectWithRaw(ctx, imageID)
+	inspect, err := apiClient.ImageInspect(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := apiClient.ImageHistory(ctx, inspect.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := apiClient.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return nil, err
 	}
@@ -102,21 +145,69 @@ func (c *MultiHostClient) GetImage(ctx context.Context, hostName, imageID string
 		createdUnix = t.Unix()
 	}
 
-	var labels map[string]string
-	if inspect.Config != nil {
-		labels = inspect.Config.Labels
-	}
-
-	return &models.ImageInfo{
+	details := &models.ImageDetails{
 		ID:          inspect.ID,
 		RepoTags:    inspect.RepoTags,
 		RepoDigests: inspect.RepoDigests,
 		Size:        inspect.Size,
 		VirtualSize: inspect.VirtualSize,
 		Created:     createdUnix,
-		Labels:      labels,
 		Host:        hostName,
-	}, nil
+		Author:      inspect.Author,
+		Comment:     inspect.Comment,
+		Platform: models.ImagePlatform{
+			OS:           inspect.Os,
+			OSVersion:    inspect.OsVersion,
+			Architecture: inspect.Architecture,
+			Variant:      inspect.Variant,
+		},
+		History:    make([]models.ImageLayer, 0, len(history)),
+		Containers: []models.ImageContainer{},
+		Dangling:   isDanglingImage(inspect.RepoTags),
+	}
+
+	if cfg := inspect.Config; cfg != nil {
+		details.Labels = cfg.Labels
+		details.Config = models.ImageConfig{
+			Entrypoint:   cfg.Entrypoint,
+			Cmd:          cfg.Cmd,
+			WorkingDir:   cfg.WorkingDir,
+			User:         cfg.User,
+			Env:          cfg.Env,
+			ExposedPorts: slices.Sorted(maps.Keys(cfg.ExposedPorts)),
+			Volumes:      slices.Sorted(maps.Keys(cfg.Volumes)),
+			StopSignal:   cfg.StopSignal,
+			Labels:       cfg.Labels,
+		}
+	}
+
+	for _, layer := range history {
+		details.History = append(details.History, models.ImageLayer{
+			ID:        layer.ID,
+			CreatedBy: layer.CreatedBy,
+			Created:   layer.Created,
+			Size:      layer.Size,
+			Comment:   layer.Comment,
+			Tags:      layer.Tags,
+		})
+	}
+
+	for _, ctr := range containers {
+		if ctr.ImageID != inspect.ID {
+			continue
+		}
+		name := ctr.ID[:12]
+		if len(ctr.Names) > 0 {
+			name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		details.Containers = append(details.Containers, models.ImageContainer{
+			ContainerID:   ctr.ID,
+			ContainerName: name,
+			State:         ctr.State,
+		})
+	}
+
+	return details, nil
 }
 
 // RemoveImage removes an image from a host
@@ -161,3 +252,126 @@ func (c *MultiHostClient) PullImage(ctx context.Context, hostName, imageName str
 
 	return reader, nil
 }
+
+// PullImageHosts pulls an image on several hosts in parallel, running at most
+// concurrency pulls at a time. Progress from every host is tagged with its host
+// name and merged into the returned channel, which is closed once all pulls have
+// finished. The per-host results are sent on the second channel afterwards.
+func (c *MultiHostClient) PullImageHosts(ctx context.Context, hostNames []string, imageName string, concurrency int) (<-chan models.ImagePullProgress, <-chan []models.ImagePullHostResult) {
+	progressCh := make(chan models.ImagePullProgress)
+	resultsCh := make(chan []models.ImagePullHostResult, 1)
+
+	if concurrency <= 0 || concurrency > len(hostNames) {
+		concurrency = len(hostNames)
+	}
+
+	go func() {
+		defer close(resultsCh)
+
+		results := make([]models.ImagePullHostResult, len(hostNames))
+		sem := make(chan struct{}, max(concurrency, 1))
+
+		var wg sync.WaitGroup
+		for i, hostName := range hostNames {
+			wg.Add(1)
+			go func(i int, hostName string) {
+				defer wg.Done()
+
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					results[i] = models.ImagePullHostResult{Host: hostName, Error: ctx.Err().Error()}
+					return
+				}
+
+				results[i] = c.pullImageOnHost(ctx, hostName, imageName, progressCh)
+			}(i, hostName)
+		}
+
+		wg.Wait()
+		close(progressCh)
+		resultsCh <- results
+	}()
+
+	return progressCh, resultsCh
+}
+
+// pullImageOnHost pulls an image on a single host, forwarding tagged progress
+// messages until the pull stream ends
+func (c *MultiHostClient) pullImageOnHost(ctx context.Context, hostName, imageName string, progressCh chan<- models.ImagePullProgress) models.ImagePullHostResult {
+	result := models.ImagePullHostResult{Host: hostName}
+
+	send := func(progress models.ImagePullProgress) bool {
+		progress.Host = hostName
+		select {
+		case progressCh <- progress:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	reader, err := c.PullImage(ctx, hostName, imageName)
+	if err != nil {
+		result.Error = err.Error()
+		send(models.ImagePullProgress{Status: "error", Error: err.Error()})
+		return result
+	}
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var progress models.ImagePullProgress
+		if err := decoder.Decode(&progress); err != nil {
+			if err != io.EOF {
+				result.Error = err.Error()
+				send(models.ImagePullProgress{Status: "error", Error: err.Error()})
+			}
+			break
+		}
+
+		// Docker reports pull failures in-band rather than as a transport error
+		if progress.Error != "" {
+			result.Error = progress.Error
+		}
+
+		if !send(progress) {
+			result.Error = ctx.Err().Error()
+			return result
+		}
+	}
+
+	result.Success = result.Error == ""
+	return result
+}
+
+// BuildImage builds an image from a tar build context and returns a reader for
+// the build output
+func (c *MultiHostClient) BuildImage(ctx context.Context, hostName string, buildContext io.Reader, options models.ImageBuildOptions) (io.ReadCloser, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	buildArgs := make(map[string]*string, len(options.BuildArgs))
+	for key, value := range options.BuildArgs {
+		buildArgs[key] = &value
+	}
+
+	resp, err := apiClient.ImageBuild(ctx, buildContext, build.ImageBuildOptions{
+		Dockerfile:  options.Dockerfile,
+		Tags:        options.Tags,
+		BuildArgs:   buildArgs,
+		Target:      options.Target,
+		NoCache:     options.NoCache,
+		PullParent:  options.PullParent,
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
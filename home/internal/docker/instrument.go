@@ -0,0 +1,145 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// APILatencyBuckets are the upper bounds in seconds of the Docker API latency
+// histogram
+var APILatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// APIStats is a snapshot of the Docker API requests made to one host
+type APIStats struct {
+	Host string
+	// Buckets holds the cumulative request count of each APILatencyBuckets bound
+	Buckets []uint64
+	// Sum is the total latency in seconds
+	Sum    float64
+	Count  uint64
+	Errors uint64
+}
+
+// apiRecorder measures the requests of the Docker client of one host. The
+// client wraps its transport in OpenTelemetry instrumentation, so the
+// recorder plugs in as a tracer provider and measures the request spans.
+type apiRecorder struct {
+	noop.TracerProvider
+
+	mu      sync.Mutex
+	buckets []uint64
+	sum     float64
+	count   uint64
+	errors  uint64
+}
+
+func newAPIRecorder() *apiRecorder {
+	return &apiRecorder{buckets: make([]uint64, len(APILatencyBuckets))}
+}
+
+func (r *apiRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return apiTracer{recorder: r}
+}
+
+// observe records a request which got its response after elapsed
+func (r *apiRecorder) observe(elapsed time.Duration, failed bool) {
+	seconds := elapsed.Seconds()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, bound := range APILatencyBuckets {
+		if seconds <= bound {
+			r.buckets[i]++
+		}
+	}
+	r.sum += seconds
+	r.count++
+	if failed {
+		r.errors++
+	}
+}
+
+func (r *apiRecorder) snapshot(host string) APIStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return APIStats{
+		Host:    host,
+		Buckets: append([]uint64(nil), r.buckets...),
+		Sum:     r.sum,
+		Count:   r.count,
+		Errors:  r.errors,
+	}
+}
+
+type apiTracer struct {
+	noop.Tracer
+	recorder *apiRecorder
+}
+
+func (t apiTracer) Start(ctx context.Context, _ string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &apiSpan{recorder: t.recorder, start: time.Now()}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// apiSpan is the span of a single request. The instrumentation sets its
+// status once the response headers arrive, or on a transport error, which
+// keeps streaming requests such as logs from counting their whole duration.
+type apiSpan struct {
+	noop.Span
+	recorder *apiRecorder
+	start    time.Time
+
+	statusCode atomic.Int64
+	done       atomic.Bool
+}
+
+func (s *apiSpan) IsRecording() bool {
+	return true
+}
+
+func (s *apiSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		switch attr.Key {
+		case "http.response.status_code", "http.status_code":
+			s.statusCode.Store(attr.Value.AsInt64())
+		}
+	}
+}
+
+func (s *apiSpan) SetStatus(code codes.Code, _ string) {
+	if !s.done.CompareAndSwap(false, true) {
+		return
+	}
+
+	// Client errors such as a missing container are answers, not failures
+	status := s.statusCode.Load()
+	failed := status >= http.StatusInternalServerError || (status == 0 && code == codes.Error)
+	s.recorder.observe(time.Since(s.start), failed)
+}
+
+func (s *apiSpan) TracerProvider() trace.TracerProvider {
+	return s.recorder
+}
+
+// APIStats returns the Docker API request stats of every host, sorted by host
+func (c *MultiHostClient) APIStats() []APIStats {
+	result := make([]APIStats, 0, len(c.recorders))
+	for host, recorder := range c.recorders {
+		result = append(result, recorder.snapshot(host))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Host < result[j].Host
+	})
+	return result
+}
@@ -0,0 +1,534 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+const (
+	// migrationVolumePath is where helper containers mount a volume being copied
+	migrationVolumePath = "/vps-monitor-volume"
+
+	defaultHealthTimeout = 2 * time.Minute
+
+	// healthGracePeriod is how long a container without a healthcheck must stay
+	// running before it is considered healthy
+	healthGracePeriod = 10 * time.Second
+)
+
+// anonymousVolumeRegex matches the generated names of anonymous volumes
+var anonymousVolumeRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// builtinNetworks exist on every Docker host and are never created by users
+var builtinNetworks = map[string]struct{}{
+	"bridge":  {},
+	"host":    {},
+	"none":    {},
+	"default": {},
+}
+
+// MigrationReporter receives progress events of a migration
+type MigrationReporter func(step models.MigrationStep)
+
+// MigrateContainer moves a container from one host to another. It captures the
+// source configuration, makes the image available on the target, copies named
+// volume data through helper containers while the source is stopped, recreates
+// the container attaching networks that exist on the target by name, waits for
+// it to become healthy and finally stops or removes the source if requested.
+// When a step fails, the target container and the volumes created for it are
+// removed and a stopped source is started again. Every step is reported
+// through report. It returns the ID of the new container.
+func (c *MultiHostClient) MigrateContainer(ctx context.Context, sourceHost, id string, req models.MigrationRequest, report MigrationReporter) (string, error) {
+	step := func(name, status, message string, err error) {
+		event := models.MigrationStep{
+			Step:      name,
+			Status:    status,
+			Message:   message,
+			Timestamp: time.Now().Unix(),
+		}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		report(event)
+	}
+
+	// State undone by rollback when a later step fails
+	var (
+		sourceClient, targetClient *client.Client
+		sourceID, targetID         string
+		createdVolumes             []string
+		sourceStopped              bool
+	)
+
+	rollback := func() {
+		if targetID == "" && len(createdVolumes) == 0 && !sourceStopped {
+			return
+		}
+
+		// Use a fresh context so the rollback also runs after cancellation
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		step(models.MigrationStepRollback, models.MigrationRunning, "Undoing migration", nil)
+		var leftBehind []string
+		if targetID != "" {
+			if err := targetClient.ContainerRemove(ctx, targetID, container.RemoveOptions{Force: true}); err != nil {
+				leftBehind = append(leftBehind, "container "+targetID[:12])
+				step(models.MigrationStepRollback, models.MigrationRunning, fmt.Sprintf("Failed to remove container %s on %s", targetID[:12], req.TargetHost), err)
+			}
+		}
+		for _, volumeName := range createdVolumes {
+			if err := targetClient.VolumeRemove(ctx, volumeName, true); err != nil {
+				leftBehind = append(leftBehind, "volume "+volumeName)
+				step(models.MigrationStepRollback, models.MigrationRunning, fmt.Sprintf("Failed to remove volume %s on %s", volumeName, req.TargetHost), err)
+			}
+		}
+		if sourceStopped {
+			if err := sourceClient.ContainerStart(ctx, sourceID, container.StartOptions{}); err != nil {
+				step(models.MigrationStepRollback, models.MigrationFailed, "Failed to start the source container again", err)
+				return
+			}
+		}
+
+		if len(leftBehind) > 0 {
+			step(models.MigrationStepRollback, models.MigrationFailed, fmt.Sprintf("Left behind on %s: %s", req.TargetHost, strings.Join(leftBehind, ", ")), nil)
+			return
+		}
+		step(models.MigrationStepRollback, models.MigrationCompleted, "Removed everything created on the target", nil)
+	}
+
+	fail := func(name string, err error) (string, error) {
+		step(name, models.MigrationFailed, "", err)
+		rollback()
+		return "", err
+	}
+
+	if sourceHost == req.TargetHost {
+		return fail(models.MigrationStepInspect, fmt.Errorf("source and target host are the same"))
+	}
+
+	var err error
+	sourceClient, err = c.GetClient(sourceHost)
+	if err != nil {
+		return fail(models.MigrationStepInspect, err)
+	}
+	targetClient, err = c.GetClient(req.TargetHost)
+	if err != nil {
+		return fail(models.MigrationStepInspect, err)
+	}
+
+	healthTimeout := defaultHealthTimeout
+	if req.HealthTimeout != "" {
+		healthTimeout, err = time.ParseDuration(req.HealthTimeout)
+		if err != nil {
+			return fail(models.MigrationStepInspect, fmt.Errorf("invalid health timeout: %w", err))
+		}
+	}
+
+	// Capture the source configuration
+	step(models.MigrationStepInspect, models.MigrationRunning, fmt.Sprintf("Inspecting container %s on %s", id, sourceHost), nil)
+	inspect, err := sourceClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return fail(models.MigrationStepInspect, err)
+	}
+	if inspect.Config == nil || inspect.HostConfig == nil {
+		return fail(models.MigrationStepInspect, fmt.Errorf("container %s has no configuration", id))
+	}
+	if inspect.HostConfig.NetworkMode.IsContainer() {
+		return fail(models.MigrationStepInspect, fmt.Errorf("containers sharing another container's network cannot be migrated"))
+	}
+	sourceID = inspect.ID
+
+	name := req.Name
+	if name == "" {
+		name = strings.TrimPrefix(inspect.Name, "/")
+	}
+	if _, err := targetClient.ContainerInspect(ctx, name); err == nil {
+		return fail(models.MigrationStepInspect, fmt.Errorf("container %s already exists on %s", name, req.TargetHost))
+	}
+	step(models.MigrationStepInspect, models.MigrationCompleted, fmt.Sprintf("Captured configuration of %s", name), nil)
+
+	// Make the image available on the target
+	imageRef := inspect.Config.Image
+	if err := c.transferMigrationImage(ctx, sourceHost, req.TargetHost, imageRef, req.ImageTransfer, step); err != nil {
+		return fail(models.MigrationStepImage, err)
+	}
+
+	// Match networks by name
+	step(models.MigrationStepNetworks, models.MigrationRunning, "Matching networks on target", nil)
+	endpoints, networkMode, err := matchMigrationNetworks(ctx, targetClient, inspect, step)
+	if err != nil {
+		return fail(models.MigrationStepNetworks, err)
+	}
+	step(models.MigrationStepNetworks, models.MigrationCompleted, fmt.Sprintf("Attaching %d network(s)", len(endpoints)), nil)
+
+	// Copy named volumes, with the source stopped so files such as databases
+	// are not copied halfway through a write
+	if req.CopyVolumes != nil && !*req.CopyVolumes {
+		step(models.MigrationStepVolumes, models.MigrationSkipped, "Volume copy disabled", nil)
+	} else {
+		stopSource := req.StopSource == nil || *req.StopSource
+		if stopSource && inspect.State != nil && inspect.State.Running && hasNamedVolumes(inspect) {
+			step(models.MigrationStepVolumes, models.MigrationRunning, "Stopping source container for a consistent copy", nil)
+			if err := sourceClient.ContainerStop(ctx, inspect.ID, container.StopOptions{}); err != nil {
+				return fail(models.MigrationStepVolumes, err)
+			}
+			sourceStopped = true
+		}
+
+		created, err := c.copyMigrationVolumes(ctx, sourceClient, targetClient, inspect, imageRef, req.ReuseVolumes, step)
+		createdVolumes = created
+		if err != nil {
+			return fail(models.MigrationStepVolumes, err)
+		}
+	}
+
+	// Recreate the container on the target
+	step(models.MigrationStepCreate, models.MigrationRunning, fmt.Sprintf("Creating %s on %s", name, req.TargetHost), nil)
+	hostConfig := *inspect.HostConfig
+	hostConfig.NetworkMode = networkMode
+	resp, err := targetClient.ContainerCreate(
+		ctx,
+		inspect.Config,
+		&hostConfig,
+		&network.NetworkingConfig{EndpointsConfig: endpoints},
+		nil,
+		name,
+	)
+	if err != nil {
+		return fail(models.MigrationStepCreate, err)
+	}
+	targetID = resp.ID
+	for _, warning := range resp.Warnings {
+		step(models.MigrationStepCreate, models.MigrationRunning, warning, nil)
+	}
+	step(models.MigrationStepCreate, models.MigrationCompleted, fmt.Sprintf("Created container %s", resp.ID[:12]), nil)
+
+	step(models.MigrationStepStart, models.MigrationRunning, "Starting container", nil)
+	if err := targetClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fail(models.MigrationStepStart, err)
+	}
+	step(models.MigrationStepStart, models.MigrationCompleted, "Container started", nil)
+
+	step(models.MigrationStepHealth, models.MigrationRunning, fmt.Sprintf("Waiting up to %s for container to become healthy", healthTimeout), nil)
+	if err := waitContainerHealthy(ctx, targetClient, resp.ID, healthTimeout); err != nil {
+		return fail(models.MigrationStepHealth, err)
+	}
+	step(models.MigrationStepHealth, models.MigrationCompleted, "Container is healthy", nil)
+
+	switch req.SourceAction {
+	case "stop":
+		if sourceStopped {
+			step(models.MigrationStepSource, models.MigrationCompleted, "Source container stays stopped", nil)
+			break
+		}
+		step(models.MigrationStepSource, models.MigrationRunning, "Stopping source container", nil)
+		if err := sourceClient.ContainerStop(ctx, inspect.ID, container.StopOptions{}); err != nil {
+			step(models.MigrationStepSource, models.MigrationFailed, "", err)
+			return resp.ID, err
+		}
+		step(models.MigrationStepSource, models.MigrationCompleted, "Source container stopped", nil)
+	case "remove":
+		step(models.MigrationStepSource, models.MigrationRunning, "Removing source container", nil)
+		if err := sourceClient.ContainerStop(ctx, inspect.ID, container.StopOptions{}); err != nil {
+			step(models.MigrationStepSource, models.MigrationFailed, "", err)
+			return resp.ID, err
+		}
+		if err := sourceClient.ContainerRemove(ctx, inspect.ID, container.RemoveOptions{}); err != nil {
+			step(models.MigrationStepSource, models.MigrationFailed, "", err)
+			return resp.ID, err
+		}
+		step(models.MigrationStepSource, models.MigrationCompleted, "Source container removed", nil)
+	default:
+		if sourceStopped {
+			if err := sourceClient.ContainerStart(ctx, inspect.ID, container.StartOptions{}); err != nil {
+				step(models.MigrationStepSource, models.MigrationFailed, "Failed to start the source container again", err)
+				return resp.ID, err
+			}
+			step(models.MigrationStepSource, models.MigrationCompleted, "Source container started again", nil)
+			break
+		}
+		step(models.MigrationStepSource, models.MigrationSkipped, "Source container kept", nil)
+	}
+
+	return resp.ID, nil
+}
+
+// transferMigrationImage makes imageRef available on the target host, either by
+// pulling it there or by streaming it from the source host
+func (c *MultiHostClient) transferMigrationImage(ctx context.Context, sourceHost, targetHost, imageRef, mode string, step func(string, string, string, error)) error {
+	if mode == "" {
+		mode = "auto"
+	}
+	if mode != "auto" && mode != "pull" && mode != "copy" {
+		return fmt.Errorf("unknown image transfer mode: %s", mode)
+	}
+
+	// Locally built images referenced by ID can only be copied
+	if strings.HasPrefix(imageRef, "sha256:") && mode == "auto" {
+		mode = "copy"
+	}
+
+	if mode == "auto" || mode == "pull" {
+		step(models.MigrationStepImage, models.MigrationRunning, fmt.Sprintf("Pulling %s on %s", imageRef, targetHost), nil)
+
+		progressCh, resultsCh := c.PullImageHosts(ctx, []string{targetHost}, imageRef, 1)
+		for range progressCh {
+		}
+		results := <-resultsCh
+
+		if len(results) == 1 && results[0].Success {
+			step(models.MigrationStepImage, models.MigrationCompleted, fmt.Sprintf("Pulled %s", imageRef), nil)
+			return nil
+		}
+
+		pullErr := fmt.Errorf("pull failed")
+		if len(results) == 1 && results[0].Error != "" {
+			pullErr = fmt.Errorf("pull failed: %s", results[0].Error)
+		}
+		if mode == "pull" {
+			return pullErr
+		}
+		step(models.MigrationStepImage, models.MigrationRunning, "Pull failed, copying image from source instead", pullErr)
+	}
+
+	step(models.MigrationStepImage, models.MigrationRunning, fmt.Sprintf("Copying %s from %s to %s", imageRef, sourceHost, targetHost), nil)
+
+	progressCh, summaryCh := c.CopyImage(ctx, sourceHost, imageRef, []string{targetHost})
+	for progress := range progressCh {
+		if progress.Status == "copied" {
+			step(models.MigrationStepImage, models.MigrationRunning, fmt.Sprintf("Transferred %d bytes", progress.BytesCopied), nil)
+		}
+	}
+	summary := <-summaryCh
+
+	if len(summary.Results) != 1 || !summary.Results[0].Success {
+		if len(summary.Results) == 1 {
+			return fmt.Errorf("image copy failed: %s", summary.Results[0].Error)
+		}
+		return fmt.Errorf("image copy failed")
+	}
+
+	step(models.MigrationStepImage, models.MigrationCompleted, fmt.Sprintf("Copied %s (%d bytes)", imageRef, summary.BytesCopied), nil)
+	return nil
+}
+
+// matchMigrationNetworks builds endpoint settings for the networks of the
+// source container that exist on the target by name. Runtime fields such as
+// network IDs and addresses are dropped since they are host specific.
+func matchMigrationNetworks(ctx context.Context, targetClient *client.Client, inspect container.InspectResponse, step func(string, string, string, error)) (map[string]*network.EndpointSettings, container.NetworkMode, error) {
+	networkMode := inspect.HostConfig.NetworkMode
+	endpoints := make(map[string]*network.EndpointSettings)
+
+	if inspect.NetworkSettings == nil || networkMode.IsHost() || networkMode.IsNone() {
+		return endpoints, networkMode, nil
+	}
+
+	targetNetworks, err := targetClient.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	available := make(map[string]struct{}, len(targetNetworks))
+	for _, net := range targetNetworks {
+		available[net.Name] = struct{}{}
+	}
+
+	for name, settings := range inspect.NetworkSettings.Networks {
+		if _, ok := available[name]; !ok {
+			step(models.MigrationStepNetworks, models.MigrationRunning, fmt.Sprintf("Network %s does not exist on target, skipping", name), nil)
+			continue
+		}
+
+		endpoints[name] = userEndpointSettings(settings)
+	}
+
+	// Fall back to the default bridge if the primary network is missing
+	if _, builtin := builtinNetworks[string(networkMode)]; !builtin {
+		if _, ok := endpoints[string(networkMode)]; !ok {
+			step(models.MigrationStepNetworks, models.MigrationRunning, fmt.Sprintf("Primary network %s missing on target, using bridge", networkMode), nil)
+			networkMode = network.NetworkBridge
+		}
+	}
+
+	return endpoints, networkMode, nil
+}
+
+// copyMigrationVolumes recreates the named volumes of a container on the target
+// and streams their data across using stopped helper containers on both hosts
+func (c *MultiHostClient) copyMigrationVolumes(ctx context.Context, sourceClient, targetClient *client.Client, inspect container.InspectResponse, imageRef string, reuse bool, step func(string, string, string, error)) ([]string, error) {
+	var created []string
+
+	for _, mp := range inspect.Mounts {
+		switch {
+		case mp.Type == mount.TypeBind:
+			step(models.MigrationStepVolumes, models.MigrationRunning, fmt.Sprintf("Bind mount %s is not copied; make sure it exists on the target", mp.Source), nil)
+			continue
+		case mp.Type != mount.TypeVolume:
+			continue
+		case anonymousVolumeRegex.MatchString(mp.Name):
+			step(models.MigrationStepVolumes, models.MigrationRunning, fmt.Sprintf("Anonymous volume at %s is not copied", mp.Destination), nil)
+			continue
+		}
+
+		if _, err := targetClient.VolumeInspect(ctx, mp.Name); err == nil {
+			if !reuse {
+				return created, fmt.Errorf("volume %s already exists on target", mp.Name)
+			}
+			step(models.MigrationStepVolumes, models.MigrationRunning, fmt.Sprintf("Reusing existing volume %s", mp.Name), nil)
+			continue
+		}
+
+		sourceVolume, err := sourceClient.VolumeInspect(ctx, mp.Name)
+		if err != nil {
+			return created, err
+		}
+
+		if _, err := targetClient.VolumeCreate(ctx, volume.CreateOptions{
+			Name:       sourceVolume.Name,
+			Driver:     sourceVolume.Driver,
+			DriverOpts: sourceVolume.Options,
+			Labels:     sourceVolume.Labels,
+		}); err != nil {
+			return created, fmt.Errorf("failed to create volume %s: %w", mp.Name, err)
+		}
+		created = append(created, sourceVolume.Name)
+
+		step(models.MigrationStepVolumes, models.MigrationRunning, fmt.Sprintf("Copying volume %s", mp.Name), nil)
+		if err := copyVolumeData(ctx, sourceClient, targetClient, mp.Name, imageRef); err != nil {
+			return created, fmt.Errorf("failed to copy volume %s: %w", mp.Name, err)
+		}
+	}
+
+	if len(created) == 0 {
+		step(models.MigrationStepVolumes, models.MigrationSkipped, "No named volumes to copy", nil)
+		return created, nil
+	}
+
+	step(models.MigrationStepVolumes, models.MigrationCompleted, fmt.Sprintf("Copied %d volume(s)", len(created)), nil)
+	return created, nil
+}
+
+// hasNamedVolumes reports whether a container mounts volumes that a migration
+// copies
+func hasNamedVolumes(inspect container.InspectResponse) bool {
+	for _, mp := range inspect.Mounts {
+		if mp.Type == mount.TypeVolume && !anonymousVolumeRegex.MatchString(mp.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyVolumeData streams the contents of a volume between hosts. The helper
+// containers are created but never started: the archive API mounts volumes on
+// its own, so the image needs no shell or tools.
+func copyVolumeData(ctx context.Context, sourceClient, targetClient *client.Client, volumeName, imageRef string) error {
+	sourceHelper, err := createVolumeHelper(ctx, sourceClient, volumeName, imageRef)
+	if err != nil {
+		return err
+	}
+	defer removeHelperContainer(sourceClient, sourceHelper)
+
+	targetHelper, err := createVolumeHelper(ctx, targetClient, volumeName, imageRef)
+	if err != nil {
+		return err
+	}
+	defer removeHelperContainer(targetClient, targetHelper)
+
+	reader, _, err := sourceClient.CopyFromContainer(ctx, sourceHelper, migrationVolumePath+"/.")
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return targetClient.CopyToContainer(ctx, targetHelper, migrationVolumePath, reader, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	})
+}
+
+func createVolumeHelper(ctx context.Context, apiClient *client.Client, volumeName, imageRef string) (string, error) {
+	resp, err := apiClient.ContainerCreate(
+		ctx,
+		&container.Config{
+			Image:      imageRef,
+			Entrypoint: []string{"true"},
+			Labels:     map[string]string{"vps-monitor.migration-helper": "true"},
+		},
+		&container.HostConfig{
+			Mounts: []mount.Mount{{
+				Type:   mount.TypeVolume,
+				Source: volumeName,
+				Target: migrationVolumePath,
+			}},
+		},
+		nil,
+		nil,
+		"",
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create helper container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+func removeHelperContainer(apiClient *client.Client, id string) {
+	// Use a fresh context so helpers are cleaned up even after cancellation
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_ = apiClient.ContainerRemove(ctx, id, container.RemoveOptions{Force: true})
+}
+
+// waitContainerHealthy waits until a container reports healthy, or has been
+// running for a grace period when it has no healthcheck
+func waitContainerHealthy(ctx context.Context, apiClient *client.Client, id string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	runningSince := time.Time{}
+	for {
+		inspect, err := apiClient.ContainerInspect(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		state := inspect.State
+		switch {
+		case state == nil:
+			return fmt.Errorf("container has no state")
+		case !state.Running:
+			return fmt.Errorf("container is %s (exit code %d)", state.Status, state.ExitCode)
+		case state.Health != nil:
+			switch state.Health.Status {
+			case container.Healthy:
+				return nil
+			case container.Unhealthy:
+				return fmt.Errorf("container is unhealthy")
+			}
+		default:
+			if runningSince.IsZero() {
+				runningSince = time.Now()
+			} else if time.Since(runningSince) >= healthGracePeriod {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for container to become healthy")
+		case <-ticker.C:
+		}
+	}
+}
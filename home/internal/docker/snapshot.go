@@ -0,0 +1,359 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// Labels attached to snapshot images so they can be listed and restored
+const (
+	SnapshotLabel              = "vps-monitor.snapshot"
+	SnapshotContainerIDLabel   = "vps-monitor.snapshot.container-id"
+	SnapshotContainerNameLabel = "vps-monitor.snapshot.container-name"
+	SnapshotMessageLabel       = "vps-monitor.snapshot.message"
+	SnapshotCreatedLabel       = "vps-monitor.snapshot.created"
+	SnapshotConfigLabel        = "vps-monitor.snapshot.config"
+)
+
+// snapshotConfig is the container configuration stored with a snapshot so the
+// container can be recreated from it
+type snapshotConfig struct {
+	Name       string                               `json:"name"`
+	Config     *container.Config                    `json:"config"`
+	HostConfig *container.HostConfig                `json:"host_config"`
+	Networks   map[string]*network.EndpointSettings `json:"networks"`
+}
+
+// CommitSnapshot commits a container to a new image labelled as a vps-monitor
+// snapshot. The container's configuration is stored on the image for restores.
+func (c *MultiHostClient) CommitSnapshot(ctx context.Context, hostName, id string, req models.SnapshotRequest) (*models.Snapshot, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := apiClient.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	containerName := strings.TrimPrefix(inspect.Name, "/")
+	now := time.Now()
+
+	repo := req.Repo
+	if repo == "" {
+		repo = containerName + "-snapshot"
+	}
+	tag := req.Tag
+	if tag == "" {
+		tag = now.UTC().Format("20060102-150405")
+	}
+	reference := repo + ":" + tag
+
+	networks := make(map[string]*network.EndpointSettings)
+	if inspect.NetworkSettings != nil {
+		for name, settings := range inspect.NetworkSettings.Networks {
+			networks[name] = userEndpointSettings(settings)
+		}
+	}
+
+	// A container restored from a snapshot inherits the labels of the image,
+	// which must not end up nested in the stored config of the next one
+	config := inspect.Config
+	if config != nil {
+		configCopy := *config
+		configCopy.Labels = withoutSnapshotLabels(config.Labels)
+		config = &configCopy
+	}
+
+	stored, err := json.Marshal(snapshotConfig{
+		Name:       containerName,
+		Config:     config,
+		HostConfig: inspect.HostConfig,
+		Networks:   networks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode container config: %w", err)
+	}
+
+	// Only labels are set here; the daemon fills in everything else from the
+	// container's own config
+	labels := make(map[string]string)
+	if config != nil {
+		maps.Copy(labels, config.Labels)
+	}
+	labels[SnapshotLabel] = "true"
+	labels[SnapshotContainerIDLabel] = inspect.ID
+	labels[SnapshotContainerNameLabel] = containerName
+	labels[SnapshotMessageLabel] = req.Message
+	labels[SnapshotCreatedLabel] = strconv.FormatInt(now.Unix(), 10)
+	labels[SnapshotConfigLabel] = string(stored)
+
+	pause := true
+	if req.Pause != nil {
+		pause = *req.Pause
+	}
+
+	resp, err := apiClient.ContainerCommit(ctx, id, container.CommitOptions{
+		Reference: reference,
+		Comment:   req.Message,
+		Author:    req.Author,
+		Pause:     pause,
+		Config:    &container.Config{Labels: labels},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Snapshot{
+		ImageID:       resp.ID,
+		Reference:     reference,
+		Host:          hostName,
+		ContainerID:   inspect.ID,
+		ContainerName: containerName,
+		Message:       req.Message,
+		Created:       now.Unix(),
+	}, nil
+}
+
+// ExportContainer returns a tar stream of a container's filesystem
+func (c *MultiHostClient) ExportContainer(ctx context.Context, hostName, id string) (io.ReadCloser, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiClient.ContainerExport(ctx, id)
+}
+
+// snapshotResult holds the result of listing snapshots on a single host
+type snapshotResult struct {
+	hostName  string
+	snapshots []models.Snapshot
+	err       error
+}
+
+// ListSnapshotsAllHosts lists vps-monitor snapshot images across all Docker
+// hosts in parallel
+func (c *MultiHostClient) ListSnapshotsAllHosts(ctx context.Context) (map[string][]models.Snapshot, []HostError, error) {
+	numHosts := len(c.clients)
+	if numHosts == 0 {
+		return make(map[string][]models.Snapshot), nil, nil
+	}
+
+	resultCh := make(chan snapshotResult, numHosts)
+
+	var wg sync.WaitGroup
+	for hostName := range c.clients {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			snapshots, err := c.ListSnapshots(ctx, name)
+			resultCh <- snapshotResult{hostName: name, snapshots: snapshots, err: err}
+		}(hostName)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	result := make(map[string][]models.Snapshot, numHosts)
+	var hostErrors []HostError
+
+	for sr := range resultCh {
+		if sr.err != nil {
+			hostErrors = append(hostErrors, HostError{HostName: sr.hostName, Err: sr.err})
+			continue
+		}
+		result[sr.hostName] = sr.snapshots
+	}
+
+	return result, hostErrors, nil
+}
+
+// ListSnapshots lists vps-monitor snapshot images on a single host
+func (c *MultiHostClient) ListSnapshots(ctx context.Context, hostName string) ([]models.Snapshot, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := apiClient.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", SnapshotLabel+"=true")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]models.Snapshot, 0, len(images))
+	for _, img := range images {
+		var reference string
+		if len(img.RepoTags) > 0 {
+			reference = img.RepoTags[0]
+		}
+
+		created := img.Created
+		if ts, err := strconv.ParseInt(img.Labels[SnapshotCreatedLabel], 10, 64); err == nil {
+			created = ts
+		}
+
+		snapshots = append(snapshots, models.Snapshot{
+			ImageID:       img.ID,
+			Reference:     reference,
+			Host:          hostName,
+			ContainerID:   img.Labels[SnapshotContainerIDLabel],
+			ContainerName: img.Labels[SnapshotContainerNameLabel],
+			Message:       img.Labels[SnapshotMessageLabel],
+			Created:       created,
+			Size:          img.Size,
+		})
+	}
+
+	return snapshots, nil
+}
+
+// RestoreSnapshot recreates a container from a snapshot image using the
+// configuration captured at commit time. It returns the new container ID.
+// An existing container with the same name is replaced: the new container is
+// created under a temporary name and the existing one is only removed once
+// the new one started, otherwise it is started again.
+func (c *MultiHostClient) RestoreSnapshot(ctx context.Context, hostName, imageID, name string) (string, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return "", err
+	}
+
+	inspect, err := apiClient.ImageInspect(ctx, imageID)
+	if err != nil {
+		return "", err
+	}
+
+	if inspect.Config == nil || inspect.Config.Labels[SnapshotLabel] != "true" {
+		return "", fmt.Errorf("image %s is not a vps-monitor snapshot", imageID)
+	}
+
+	var stored snapshotConfig
+	if err := json.Unmarshal([]byte(inspect.Config.Labels[SnapshotConfigLabel]), &stored); err != nil {
+		return "", fmt.Errorf("failed to decode snapshot config: %w", err)
+	}
+	if stored.Config == nil {
+		return "", fmt.Errorf("snapshot %s has no stored container config", imageID)
+	}
+
+	if name == "" {
+		name = stored.Name
+	}
+
+	newConfig := stored.Config
+	newConfig.Image = inspect.ID
+	// The container inherits the labels of the image, and inherited labels
+	// can only be overridden, so the snapshot labels are blanked
+	newConfig.Labels = withoutSnapshotLabels(newConfig.Labels)
+	for label := range inspect.Config.Labels {
+		if isSnapshotLabel(label) {
+			newConfig.Labels[label] = ""
+		}
+	}
+
+	endpoints := make(map[string]*network.EndpointSettings, len(stored.Networks))
+	for networkName, settings := range stored.Networks {
+		endpoints[networkName] = userEndpointSettings(settings)
+	}
+
+	existing, err := apiClient.ContainerInspect(ctx, name)
+	hasExisting := err == nil
+
+	createName := name
+	if hasExisting {
+		createName = fmt.Sprintf("%s-restore-%d", name, time.Now().Unix())
+	}
+
+	resp, err := apiClient.ContainerCreate(
+		ctx,
+		newConfig,
+		stored.HostConfig,
+		&network.NetworkingConfig{
+			EndpointsConfig: endpoints,
+		},
+		nil,
+		createName,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	wasRunning := false
+	if hasExisting {
+		wasRunning = existing.State != nil && existing.State.Running
+		// The existing container may hold ports or volumes the new one needs
+		if err := apiClient.ContainerStop(ctx, existing.ID, container.StopOptions{}); err != nil {
+			_ = apiClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+			return "", err
+		}
+	}
+
+	if err := apiClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		_ = apiClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+		if wasRunning {
+			if startErr := apiClient.ContainerStart(ctx, existing.ID, container.StartOptions{}); startErr != nil {
+				return "", fmt.Errorf("%w (restarting the original container failed too: %v)", err, startErr)
+			}
+		}
+		return "", err
+	}
+
+	if hasExisting {
+		if err := apiClient.ContainerRemove(ctx, existing.ID, container.RemoveOptions{}); err != nil {
+			return resp.ID, fmt.Errorf("restored as %s, but removing the original container failed: %w", createName, err)
+		}
+		if err := apiClient.ContainerRename(ctx, resp.ID, name); err != nil {
+			return resp.ID, fmt.Errorf("restored as %s, but renaming it to %s failed: %w", createName, name, err)
+		}
+	}
+
+	return resp.ID, nil
+}
+
+// isSnapshotLabel reports whether label is one of the vps-monitor.snapshot*
+// labels
+func isSnapshotLabel(label string) bool {
+	return label == SnapshotLabel || strings.HasPrefix(label, SnapshotLabel+".")
+}
+
+// withoutSnapshotLabels returns a copy of labels without snapshot labels
+func withoutSnapshotLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for label, value := range labels {
+		if !isSnapshotLabel(label) {
+			result[label] = value
+		}
+	}
+	return result
+}
+
+// userEndpointSettings keeps the configurable part of a network endpoint,
+// dropping runtime fields such as IDs and assigned addresses
+func userEndpointSettings(settings *network.EndpointSettings) *network.EndpointSettings {
+	endpoint := &network.EndpointSettings{}
+	if settings != nil {
+		endpoint.IPAMConfig = settings.IPAMConfig
+		endpoint.Links = settings.Links
+		endpoint.Aliases = settings.Aliases
+		endpoint.DriverOpts = settings.DriverOpts
+	}
+	return endpoint
+}
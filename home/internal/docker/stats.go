@@ -181,6 +181,7 @@ func parseDockerStats(raw dockerStats, containerID, host string) models.Containe
 		ContainerID:   containerID,
 		Host:          host,
 		CPUPercent:    cpuPercent,
+		CPUUsage:      raw.CPUStats.CPUUsage.TotalUsage,
 		MemoryUsage:   raw.MemoryStats.Usage,
 		MemoryLimit:   raw.MemoryStats.Limit,
 		MemoryPercent: memPercent,
@@ -0,0 +1,260 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// transferProgressInterval controls how often byte counts are reported while
+// an image is being copied
+const transferProgressInterval = time.Second
+
+// loadMessage is a single message from Docker's image load response
+type loadMessage struct {
+	Stream string `json:"stream"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// SaveImage returns a tar stream of the given images from a host
+func (c *MultiHostClient) SaveImage(ctx context.Context, hostName string, imageRefs []string) (io.ReadCloser, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiClient.ImageSave(ctx, imageRefs)
+}
+
+// LoadImage loads an image tar stream on a host and returns Docker's load
+// response messages
+func (c *MultiHostClient) LoadImage(ctx context.Context, hostName string, input io.Reader) (io.ReadCloser, error) {
+	apiClient, err := c.GetClient(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := apiClient.ImageLoad(ctx, input, client.ImageLoadWithQuiet(true))
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// DecodeLoadResponse reads Docker's image load messages, calling onMessage for
+// each line of output. It returns the loaded image references, or the error
+// reported by the daemon.
+func DecodeLoadResponse(body io.Reader, onMessage func(string)) ([]string, error) {
+	var loaded []string
+
+	decoder := json.NewDecoder(body)
+	for {
+		var msg loadMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return loaded, nil
+			}
+			return loaded, err
+		}
+
+		if msg.Error != "" {
+			return loaded, errors.New(msg.Error)
+		}
+
+		text := strings.TrimSpace(msg.Stream)
+		if text == "" {
+			text = strings.TrimSpace(msg.Status)
+		}
+		if text == "" {
+			continue
+		}
+
+		for _, prefix := range []string{"Loaded image: ", "Loaded image ID: "} {
+			if ref, ok := strings.CutPrefix(text, prefix); ok {
+				loaded = append(loaded, ref)
+			}
+		}
+
+		if onMessage != nil {
+			onMessage(text)
+		}
+	}
+}
+
+// CopyImage streams an image saved on the source host straight into image load
+// on every target host, without staging the tarball. Progress is sent on the
+// first channel, which is closed when the copy has finished; the per-target
+// outcome is then sent on the second channel.
+func (c *MultiHostClient) CopyImage(ctx context.Context, sourceHost, imageRef string, targetHosts []string) (<-chan models.ImageTransferProgress, <-chan models.ImageTransferSummary) {
+	progressCh := make(chan models.ImageTransferProgress)
+	summaryCh := make(chan models.ImageTransferSummary, 1)
+
+	go func() {
+		defer close(summaryCh)
+
+		send := func(progress models.ImageTransferProgress) {
+			select {
+			case progressCh <- progress:
+			case <-ctx.Done():
+			}
+		}
+
+		summary := c.copyImage(ctx, sourceHost, imageRef, targetHosts, send)
+		close(progressCh)
+		summaryCh <- summary
+	}()
+
+	return progressCh, summaryCh
+}
+
+// transferTarget tracks the load of a copied image on a single host
+type transferTarget struct {
+	host   string
+	writer *io.PipeWriter
+	result models.ImageTransferResult
+	// writeErr is only touched by the copy loop; result is owned by the load
+	// goroutine until it finishes
+	writeErr error
+}
+
+func (c *MultiHostClient) copyImage(ctx context.Context, sourceHost, imageRef string, targetHosts []string, send func(models.ImageTransferProgress)) models.ImageTransferSummary {
+	summary := models.ImageTransferSummary{Status: "complete"}
+
+	failAll := func(err error) models.ImageTransferSummary {
+		send(models.ImageTransferProgress{Status: "error", Host: sourceHost, Error: err.Error()})
+		for _, host := range targetHosts {
+			summary.Results = append(summary.Results, models.ImageTransferResult{Host: host, Error: err.Error()})
+		}
+		return summary
+	}
+
+	send(models.ImageTransferProgress{Status: "saving", Host: sourceHost, Message: fmt.Sprintf("Saving %s", imageRef)})
+
+	source, err := c.SaveImage(ctx, sourceHost, []string{imageRef})
+	if err != nil {
+		return failAll(fmt.Errorf("failed to save image on %s: %w", sourceHost, err))
+	}
+	defer source.Close()
+
+	targets := make([]*transferTarget, 0, len(targetHosts))
+	var wg sync.WaitGroup
+
+	for _, host := range targetHosts {
+		reader, writer := io.Pipe()
+		target := &transferTarget{
+			host:   host,
+			writer: writer,
+			result: models.ImageTransferResult{Host: host},
+		}
+		targets = append(targets, target)
+
+		wg.Add(1)
+		go func(target *transferTarget, reader *io.PipeReader) {
+			defer wg.Done()
+
+			body, err := c.LoadImage(ctx, target.host, reader)
+			if err != nil {
+				reader.CloseWithError(err)
+				target.result.Error = err.Error()
+				return
+			}
+			defer body.Close()
+
+			images, err := DecodeLoadResponse(body, func(message string) {
+				send(models.ImageTransferProgress{Status: "loading", Host: target.host, Message: message})
+			})
+			// Unblock the copy loop if the daemon stopped reading early
+			reader.CloseWithError(io.ErrClosedPipe)
+
+			target.result.Images = images
+			if err != nil {
+				target.result.Error = err.Error()
+			}
+		}(target, reader)
+	}
+
+	copied, copyErr := fanOutImage(ctx, source, targets, sourceHost, send)
+	summary.BytesCopied = copied
+
+	for _, target := range targets {
+		if copyErr != nil {
+			target.writer.CloseWithError(copyErr)
+		} else {
+			target.writer.Close()
+		}
+	}
+
+	wg.Wait()
+
+	for _, target := range targets {
+		if target.result.Error == "" {
+			if target.writeErr != nil {
+				target.result.Error = fmt.Sprintf("transfer interrupted: %v", target.writeErr)
+			} else if copyErr != nil {
+				target.result.Error = copyErr.Error()
+			}
+		}
+		target.result.Success = target.result.Error == ""
+		summary.Results = append(summary.Results, target.result)
+	}
+
+	return summary
+}
+
+// fanOutImage copies the source stream to every target that is still accepting
+// data, reporting the byte count periodically. Targets whose load fails are
+// dropped without interrupting the others.
+func fanOutImage(ctx context.Context, source io.Reader, targets []*transferTarget, sourceHost string, send func(models.ImageTransferProgress)) (int64, error) {
+	buffer := make([]byte, 256*1024)
+	var copied int64
+	lastReport := time.Now()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return copied, err
+		}
+
+		n, readErr := source.Read(buffer)
+		if n > 0 {
+			active := 0
+			for _, target := range targets {
+				if target.writeErr != nil {
+					continue
+				}
+				if _, err := target.writer.Write(buffer[:n]); err != nil {
+					target.writeErr = err
+					continue
+				}
+				active++
+			}
+			copied += int64(n)
+
+			if active == 0 {
+				return copied, errors.New("all target hosts failed")
+			}
+
+			if time.Since(lastReport) >= transferProgressInterval {
+				lastReport = time.Now()
+				send(models.ImageTransferProgress{Status: "copying", Host: sourceHost, BytesCopied: copied})
+			}
+		}
+
+		if readErr != nil {
+			if readErr == io.EOF {
+				send(models.ImageTransferProgress{Status: "copied", Host: sourceHost, BytesCopied: copied})
+				return copied, nil
+			}
+			return copied, readErr
+		}
+	}
+}
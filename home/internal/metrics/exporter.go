@@ -0,0 +1,299 @@
+package metrics
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/alerts"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+	"github.com/hhftechnology/vps-monitor/internal/stats"
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// namespace prefixes every metric of vps-monitor
+const namespace = "vps_monitor_"
+
+// composeProjectLabel is set by Docker Compose on the containers of a project
+const composeProjectLabel = "com.docker.compose.project"
+
+var (
+	containerStates = []string{"created", "running", "paused", "restarting", "removing", "exited", "dead"}
+	healthStates    = []string{"starting", "healthy", "unhealthy"}
+)
+
+// Exporter collects the metrics of containers, hosts, alerts and
+// vps-monitor itself on every scrape. Container stats and restart counts come
+// from the latest samples, so a scrape only lists the containers of each host.
+type Exporter struct {
+	docker           *docker.MultiHostClient
+	alertMonitor     *alerts.Monitor
+	hostSampler      *stats.HostSampler
+	containerSampler *stats.ContainerSampler
+	startedAt        time.Time
+}
+
+// NewExporter creates an exporter. alertMonitor, hostSampler and
+// containerSampler are optional.
+func NewExporter(dockerClient *docker.MultiHostClient, alertMonitor *alerts.Monitor, hostSampler *stats.HostSampler, containerSampler *stats.ContainerSampler) *Exporter {
+	return &Exporter{
+		docker:           dockerClient,
+		alertMonitor:     alertMonitor,
+		hostSampler:      hostSampler,
+		containerSampler: containerSampler,
+		startedAt:        time.Now(),
+	}
+}
+
+// containerSample is what is collected of a single container
+type containerSample struct {
+	host     string
+	info     models.ContainerInfo
+	stats    *models.ContainerStats
+	restarts int
+}
+
+// Collect gathers all metric families
+func (e *Exporter) Collect(ctx context.Context) []Family {
+	start := time.Now()
+
+	var families []Family
+	families = append(families, e.collectContainers(ctx)...)
+	families = append(families, e.collectHosts(ctx)...)
+	families = append(families, e.collectAlerts()...)
+	families = append(families, e.collectInternals()...)
+
+	scrapeDuration := Family{Name: namespace + "scrape_duration_seconds", Help: "Time taken to collect these metrics.", Type: TypeGauge}
+	scrapeDuration.Add(time.Since(start).Seconds())
+	return append(families, scrapeDuration)
+}
+
+func (e *Exporter) collectContainers(ctx context.Context) []Family {
+	containersMap, hostErrors, _ := e.docker.ListContainersAllHosts(ctx)
+
+	up := Family{Name: namespace + "docker_up", Help: "Whether the Docker daemon of the host answered the last scrape.", Type: TypeGauge}
+	for _, host := range e.docker.GetHosts() {
+		value := 1.0
+		if slices.ContainsFunc(hostErrors, func(hostErr docker.HostError) bool { return hostErr.HostName == host.Name }) {
+			value = 0
+		}
+		up.Add(value, Label{"host", host.Name})
+	}
+
+	var samples []*containerSample
+	for host, containers := range containersMap {
+		for _, ctr := range containers {
+			samples = append(samples, &containerSample{host: host, info: ctr})
+		}
+	}
+	slices.SortFunc(samples, func(a, b *containerSample) int {
+		if c := strings.Compare(a.host, b.host); c != 0 {
+			return c
+		}
+		return strings.Compare(containerName(a.info), containerName(b.info))
+	})
+
+	if e.containerSampler != nil {
+		latest := make(map[string]*models.ContainerStats)
+		for _, sampled := range e.containerSampler.Latest() {
+			latest[sampled.Host+":"+sampled.Container.ID] = &sampled.Stats
+		}
+		for _, sample := range samples {
+			sample.restarts, _ = e.containerSampler.RestartCount(sample.host, sample.info.ID)
+			if sample.info.State == "running" {
+				sample.stats = latest[sample.host+":"+sample.info.ID]
+			}
+		}
+	}
+
+	state := Family{Name: namespace + "container_state", Help: "Current state of the container, 1 for the active state.", Type: TypeGauge}
+	health := Family{Name: namespace + "container_health", Help: "Healthcheck status of the container, 1 for the active status.", Type: TypeGauge}
+	restarts := Family{Name: namespace + "container_restarts", Help: "Number of times Docker restarted the container.", Type: TypeCounter}
+	cpuSeconds := Family{Name: namespace + "container_cpu_usage_seconds", Help: "CPU time consumed by the container.", Type: TypeCounter}
+	cpuPercent := Family{Name: namespace + "container_cpu_percent", Help: "CPU usage of the container as reported by Docker, 100 per core.", Type: TypeGauge}
+	memUsage := Family{Name: namespace + "container_memory_usage_bytes", Help: "Memory used by the container.", Type: TypeGauge}
+	memLimit := Family{Name: namespace + "container_memory_limit_bytes", Help: "Memory limit of the container.", Type: TypeGauge}
+	netRx := Family{Name: namespace + "container_network_receive_bytes", Help: "Bytes received by the container on all interfaces.", Type: TypeCounter}
+	netTx := Family{Name: namespace + "container_network_transmit_bytes", Help: "Bytes sent by the container on all interfaces.", Type: TypeCounter}
+	blockRead := Family{Name: namespace + "container_block_read_bytes", Help: "Bytes read by the container from block devices.", Type: TypeCounter}
+	blockWrite := Family{Name: namespace + "container_block_write_bytes", Help: "Bytes written by the container to block devices.", Type: TypeCounter}
+	pids := Family{Name: namespace + "container_pids", Help: "Number of processes in the container.", Type: TypeGauge}
+
+	for _, sample := range samples {
+		labels := []Label{
+			{"host", sample.host},
+			{"name", containerName(sample.info)},
+			{"image", sample.info.Image},
+			{"compose_project", sample.info.Labels[composeProjectLabel]},
+		}
+
+		for _, s := range containerStates {
+			state.Add(boolValue(sample.info.State == s), withLabel(labels, "state", s)...)
+		}
+		if sample.info.Health != "" {
+			for _, s := range healthStates {
+				health.Add(boolValue(sample.info.Health == s), withLabel(labels, "health", s)...)
+			}
+		}
+		restarts.AddCounter(float64(sample.restarts), labels...)
+
+		if s := sample.stats; s != nil {
+			cpuSeconds.AddCounter(float64(s.CPUUsage)/1e9, labels...)
+			cpuPercent.Add(s.CPUPercent, labels...)
+			memUsage.Add(float64(s.MemoryUsage), labels...)
+			memLimit.Add(float64(s.MemoryLimit), labels...)
+			netRx.AddCounter(float64(s.NetworkRx), labels...)
+			netTx.AddCounter(float64(s.NetworkTx), labels...)
+			blockRead.AddCounter(float64(s.BlockRead), labels...)
+			blockWrite.AddCounter(float64(s.BlockWrite), labels...)
+			pids.Add(float64(s.PIDs), labels...)
+		}
+	}
+
+	return []Family{up, state, health, restarts, cpuSeconds, cpuPercent, memUsage, memLimit, netRx, netTx, blockRead, blockWrite, pids}
+}
+
+func (e *Exporter) collectHosts(ctx context.Context) []Family {
+	type hostSample struct {
+		host    string
+		metrics *system.HostMetrics
+	}
+
+	var hosts []hostSample
+	localDone := false
+	for _, host := range e.docker.GetHosts() {
+		switch {
+		case host.Agent != nil:
+			if hostMetrics, err := e.docker.GetHostMetrics(ctx, host.Name); err == nil {
+				hosts = append(hosts, hostSample{host.Name, hostMetrics})
+			}
+		case strings.HasPrefix(host.Host, "unix://") && !localDone && e.hostSampler != nil:
+			// Every socket host is the machine running vps-monitor
+			localDone = true
+			if latest := e.hostSampler.Latest(); latest != nil {
+				hosts = append(hosts, hostSample{host.Name, latest})
+			}
+		}
+	}
+
+	cpuPercent := Family{Name: namespace + "host_cpu_percent", Help: "CPU usage of the host over all cores.", Type: TypeGauge}
+	cores := Family{Name: namespace + "host_cpu_cores", Help: "Number of logical CPU cores of the host.", Type: TypeGauge}
+	load := Family{Name: namespace + "host_load", Help: "Load average of the host.", Type: TypeGauge}
+	memTotal := Family{Name: namespace + "host_memory_total_bytes", Help: "Total memory of the host.", Type: TypeGauge}
+	memUsed := Family{Name: namespace + "host_memory_used_bytes", Help: "Memory used on the host.", Type: TypeGauge}
+	memAvailable := Family{Name: namespace + "host_memory_available_bytes", Help: "Memory available for new processes on the host.", Type: TypeGauge}
+	swapTotal := Family{Name: namespace + "host_swap_total_bytes", Help: "Total swap of the host.", Type: TypeGauge}
+	swapUsed := Family{Name: namespace + "host_swap_used_bytes", Help: "Swap used on the host.", Type: TypeGauge}
+	fsSize := Family{Name: namespace + "host_filesystem_size_bytes", Help: "Size of the filesystem.", Type: TypeGauge}
+	fsUsed := Family{Name: namespace + "host_filesystem_used_bytes", Help: "Space used on the filesystem.", Type: TypeGauge}
+	fsInodes := Family{Name: namespace + "host_filesystem_inodes", Help: "Total inodes of the filesystem.", Type: TypeGauge}
+	fsInodesUsed := Family{Name: namespace + "host_filesystem_inodes_used", Help: "Inodes used on the filesystem.", Type: TypeGauge}
+	netRx := Family{Name: namespace + "host_network_receive_bytes", Help: "Bytes received on the network interface.", Type: TypeCounter}
+	netTx := Family{Name: namespace + "host_network_transmit_bytes", Help: "Bytes sent on the network interface.", Type: TypeCounter}
+	diskRead := Family{Name: namespace + "host_disk_read_bytes", Help: "Bytes read from the disk.", Type: TypeCounter}
+	diskWrite := Family{Name: namespace + "host_disk_write_bytes", Help: "Bytes written to the disk.", Type: TypeCounter}
+
+	for _, sample := range hosts {
+		host := Label{"host", sample.host}
+		m := sample.metrics
+
+		cpuPercent.Add(m.CPU.Percent, host)
+		cores.Add(float64(m.CPU.Cores), host)
+		load.Add(m.Load.Load1, host, Label{"period", "1m"})
+		load.Add(m.Load.Load5, host, Label{"period", "5m"})
+		load.Add(m.Load.Load15, host, Label{"period", "15m"})
+		memTotal.Add(float64(m.Memory.Total), host)
+		memUsed.Add(float64(m.Memory.Used), host)
+		memAvailable.Add(float64(m.Memory.Available), host)
+		swapTotal.Add(float64(m.Swap.Total), host)
+		swapUsed.Add(float64(m.Swap.Used), host)
+
+		for _, fs := range m.Filesystems {
+			labels := []Label{host, {"mountpoint", fs.Mountpoint}, {"device", fs.Device}, {"fstype", fs.Fstype}}
+			fsSize.Add(float64(fs.Total), labels...)
+			fsUsed.Add(float64(fs.Used), labels...)
+			fsInodes.Add(float64(fs.InodesTotal), labels...)
+			fsInodesUsed.Add(float64(fs.InodesUsed), labels...)
+		}
+		for _, iface := range m.Network {
+			labels := []Label{host, {"interface", iface.Name}}
+			netRx.AddCounter(float64(iface.BytesRecv), labels...)
+			netTx.AddCounter(float64(iface.BytesSent), labels...)
+		}
+		for _, disk := range m.DiskIO {
+			labels := []Label{host, {"device", disk.Device}}
+			diskRead.AddCounter(float64(disk.ReadBytes), labels...)
+			diskWrite.AddCounter(float64(disk.WriteBytes), labels...)
+		}
+	}
+
+	return []Family{cpuPercent, cores, load, memTotal, memUsed, memAvailable, swapTotal, swapUsed,
+		fsSize, fsUsed, fsInodes, fsInodesUsed, netRx, netTx, diskRead, diskWrite}
+}
+
+func (e *Exporter) collectAlerts() []Family {
+	if e.alertMonitor == nil {
+		return nil
+	}
+	history := e.alertMonitor.GetHistory()
+
+	totals := Family{Name: namespace + "alerts", Help: "Alerts raised since startup by type.", Type: TypeCounter}
+	counts := history.GetTotals()
+	types := make([]string, 0, len(counts))
+	for alertType := range counts {
+		types = append(types, string(alertType))
+	}
+	slices.Sort(types)
+	for _, alertType := range types {
+		totals.AddCounter(float64(counts[models.AlertType(alertType)]), Label{"type", alertType})
+	}
+
+	unacknowledged := Family{Name: namespace + "alerts_unacknowledged", Help: "Alerts in the history not yet acknowledged.", Type: TypeGauge}
+	unacknowledged.Add(float64(history.GetUnacknowledgedCount()))
+
+	return []Family{totals, unacknowledged}
+}
+
+func (e *Exporter) collectInternals() []Family {
+	latency := Family{Name: namespace + "docker_api_request_duration_seconds", Help: "Time until the Docker API answered a request, by host.", Type: TypeHistogram}
+	apiErrors := Family{Name: namespace + "docker_api_errors", Help: "Docker API requests that failed to connect or got a server error, by host.", Type: TypeCounter}
+	for _, apiStats := range e.docker.APIStats() {
+		host := Label{"host", apiStats.Host}
+		latency.AddHistogram(docker.APILatencyBuckets, apiStats.Buckets, apiStats.Sum, apiStats.Count, host)
+		apiErrors.AddCounter(float64(apiStats.Errors), host)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	goroutines := Family{Name: "go_goroutines", Help: "Number of goroutines that currently exist.", Type: TypeGauge}
+	goroutines.Add(float64(runtime.NumGoroutine()))
+	heap := Family{Name: "go_memstats_heap_alloc_bytes", Help: "Number of heap bytes allocated and still in use.", Type: TypeGauge}
+	heap.Add(float64(mem.HeapAlloc))
+	startTime := Family{Name: "process_start_time_seconds", Help: "Start time of the process since unix epoch in seconds.", Type: TypeGauge}
+	startTime.Add(float64(e.startedAt.Unix()))
+
+	return []Family{latency, apiErrors, goroutines, heap, startTime}
+}
+
+func containerName(ctr models.ContainerInfo) string {
+	if len(ctr.Names) == 0 {
+		return ctr.ID
+	}
+	return strings.TrimPrefix(ctr.Names[0], "/")
+}
+
+// withLabel returns labels with one more label, leaving labels untouched
+func withLabel(labels []Label, name, value string) []Label {
+	return append(append([]Label(nil), labels...), Label{name, value})
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format is a Prometheus exposition format
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatOpenMetrics
+)
+
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// NegotiateFormat picks OpenMetrics when the Accept header asks for it, and
+// the classic text format otherwise
+func NegotiateFormat(accept string) Format {
+	if strings.Contains(accept, "application/openmetrics-text") {
+		return FormatOpenMetrics
+	}
+	return FormatText
+}
+
+// ContentType returns the Content-Type header of the format
+func (f Format) ContentType() string {
+	if f == FormatOpenMetrics {
+		return openMetricsContentType
+	}
+	return textContentType
+}
+
+// Type is the type of a metric family
+type Type string
+
+const (
+	TypeGauge     Type = "gauge"
+	TypeCounter   Type = "counter"
+	TypeHistogram Type = "histogram"
+)
+
+// Label is a name and value pair of a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a family. Suffix is appended to the family
+// name, such as _total for counters or _bucket for histograms.
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a named group of samples sharing a type and help text
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Add appends a sample without suffix
+func (f *Family) Add(value float64, labels ...Label) {
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: value})
+}
+
+// AddCounter appends the _total sample of a counter
+func (f *Family) AddCounter(value float64, labels ...Label) {
+	f.Samples = append(f.Samples, Sample{Suffix: "_total", Labels: labels, Value: value})
+}
+
+// AddHistogram appends the buckets, sum and count of a histogram. counts are
+// cumulative and match bounds; the +Inf bucket is the total count.
+func (f *Family) AddHistogram(bounds []float64, counts []uint64, sum float64, count uint64, labels ...Label) {
+	for i, bound := range bounds {
+		f.Samples = append(f.Samples, Sample{
+			Suffix: "_bucket",
+			Labels: append(append([]Label(nil), labels...), Label{"le", formatFloat(bound)}),
+			Value:  float64(counts[i]),
+		})
+	}
+	f.Samples = append(f.Samples,
+		Sample{Suffix: "_bucket", Labels: append(append([]Label(nil), labels...), Label{"le", "+Inf"}), Value: float64(count)},
+		Sample{Suffix: "_sum", Labels: labels, Value: sum},
+		Sample{Suffix: "_count", Labels: labels, Value: float64(count)},
+	)
+}
+
+// Write renders families in the given format. Counter families are named
+// with _total in the text format, as Prometheus expects, and without it in
+// OpenMetrics, where the suffix belongs to the sample only.
+func Write(w io.Writer, format Format, families []Family) error {
+	buf := bufio.NewWriter(w)
+
+	for _, family := range families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+
+		name := family.Name
+		if family.Type == TypeCounter && format == FormatText {
+			name += "_total"
+		}
+
+		buf.WriteString("# HELP " + name + " " + escapeHelp(family.Help) + "\n")
+		buf.WriteString("# TYPE " + name + " " + string(family.Type) + "\n")
+
+		for _, sample := range family.Samples {
+			buf.WriteString(family.Name + sample.Suffix)
+			if len(sample.Labels) > 0 {
+				buf.WriteByte('{')
+				for i, label := range sample.Labels {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					buf.WriteString(label.Name + `="` + escapeLabelValue(label.Value) + `"`)
+				}
+				buf.WriteByte('}')
+			}
+			buf.WriteString(" " + formatFloat(sample.Value) + "\n")
+		}
+	}
+
+	if format == FormatOpenMetrics {
+		buf.WriteString("# EOF\n")
+	}
+
+	return buf.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
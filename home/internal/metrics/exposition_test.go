@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func testFamilies() []Family {
+	up := Family{Name: "vps_monitor_docker_up", Help: "Whether the daemon answered.", Type: TypeGauge}
+	up.Add(1, Label{"host", "local"})
+	up.Add(0, Label{"host", `we"ird\host` + "\n"})
+
+	restarts := Family{Name: "vps_monitor_container_restarts", Help: "Restarts.", Type: TypeCounter}
+	restarts.AddCounter(3, Label{"name", "web"}, Label{"image", "nginx:1.27"})
+
+	latency := Family{Name: "vps_monitor_docker_api_request_duration_seconds", Help: "Latency.", Type: TypeHistogram}
+	latency.AddHistogram([]float64{0.1, 1}, []uint64{2, 5}, 1.5, 6, Label{"host", "local"})
+
+	empty := Family{Name: "vps_monitor_alerts", Help: "Alerts.", Type: TypeCounter}
+
+	help := Family{Name: "vps_monitor_help", Help: `Backslash \ and` + "\n" + `newline, "quotes" kept.`, Type: TypeGauge}
+	help.Add(math.Inf(1))
+
+	return []Family{up, restarts, latency, empty, help}
+}
+
+func TestWriteText(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, FormatText, testFamilies()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP vps_monitor_docker_up Whether the daemon answered.
+# TYPE vps_monitor_docker_up gauge
+vps_monitor_docker_up{host="local"} 1
+vps_monitor_docker_up{host="we\"ird\\host\n"} 0
+# HELP vps_monitor_container_restarts_total Restarts.
+# TYPE vps_monitor_container_restarts_total counter
+vps_monitor_container_restarts_total{name="web",image="nginx:1.27"} 3
+# HELP vps_monitor_docker_api_request_duration_seconds Latency.
+# TYPE vps_monitor_docker_api_request_duration_seconds histogram
+vps_monitor_docker_api_request_duration_seconds_bucket{host="local",le="0.1"} 2
+vps_monitor_docker_api_request_duration_seconds_bucket{host="local",le="1"} 5
+vps_monitor_docker_api_request_duration_seconds_bucket{host="local",le="+Inf"} 6
+vps_monitor_docker_api_request_duration_seconds_sum{host="local"} 1.5
+vps_monitor_docker_api_request_duration_seconds_count{host="local"} 6
+# HELP vps_monitor_help Backslash \\ and\nnewline, "quotes" kept.
+# TYPE vps_monitor_help gauge
+vps_monitor_help +Inf
+`
+	if got := b.String(); got != want {
+		t.Errorf("text exposition mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, FormatOpenMetrics, testFamilies()); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+
+	// Counter families drop _total, samples keep it
+	for _, line := range []string{
+		"# TYPE vps_monitor_container_restarts counter\n",
+		"# HELP vps_monitor_container_restarts Restarts.\n",
+		"vps_monitor_container_restarts_total{name=\"web\",image=\"nginx:1.27\"} 3\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("OpenMetrics output is missing %q", line)
+		}
+	}
+	if strings.Contains(got, "# TYPE vps_monitor_container_restarts_total") {
+		t.Error("OpenMetrics counter family is named with _total")
+	}
+
+	if !strings.HasSuffix(got, "\n# EOF\n") || strings.Count(got, "# EOF") != 1 {
+		t.Errorf("OpenMetrics output does not end with a single # EOF:\n%s", got)
+	}
+
+	// An empty exposition is still terminated
+	b.Reset()
+	if err := Write(&b, FormatOpenMetrics, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "# EOF\n" {
+		t.Errorf("got %q for no families, want only # EOF", b.String())
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantHelp  string
+		wantLabel string
+	}{
+		{"plain", "plain", "plain"},
+		{`back\slash`, `back\\slash`, `back\\slash`},
+		{"new\nline", `new\nline`, `new\nline`},
+		{`"quoted"`, `"quoted"`, `\"quoted\"`},
+		{`\n`, `\\n`, `\\n`},
+		{"tab\tand ünïcode", "tab\tand ünïcode", "tab\tand ünïcode"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := escapeHelp(tt.input); got != tt.wantHelp {
+			t.Errorf("escapeHelp(%q) = %q, want %q", tt.input, got, tt.wantHelp)
+		}
+		if got := escapeLabelValue(tt.input); got != tt.wantLabel {
+			t.Errorf("escapeLabelValue(%q) = %q, want %q", tt.input, got, tt.wantLabel)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{-2.5, "-2.5"},
+		{0.005, "0.005"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := formatFloat(tt.value); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Format
+	}{
+		{"", FormatText},
+		{"text/plain;version=0.0.4", FormatText},
+		{"application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5", FormatOpenMetrics},
+		{"*/*", FormatText},
+	}
+
+	for _, tt := range tests {
+		if got := NegotiateFormat(tt.accept); got != tt.want {
+			t.Errorf("NegotiateFormat(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -4,10 +4,21 @@ package models
 type AlertType string
 
 const (
-	AlertContainerStopped AlertType = "container_stopped"
-	AlertContainerStarted AlertType = "container_started"
-	AlertCPUThreshold     AlertType = "cpu_threshold"
-	AlertMemoryThreshold  AlertType = "memory_threshold"
+	AlertContainerStopped   AlertType = "container_stopped"
+	AlertContainerStarted   AlertType = "container_started"
+	AlertCPUThreshold       AlertType = "cpu_threshold"
+	AlertMemoryThreshold    AlertType = "memory_threshold"
+	AlertContainerUnhealthy AlertType = "container_unhealthy"
+	AlertContainerRecovered AlertType = "container_recovered"
+	AlertCrashLoop          AlertType = "crash_loop"
+	AlertCrashLoopResolved  AlertType = "crash_loop_resolved"
+	AlertContainerOOMKilled AlertType = "container_oom_killed"
+	AlertHostDisk           AlertType = "host_disk"
+	AlertHostMemory         AlertType = "host_memory"
+	AlertHostLoad           AlertType = "host_load"
+	AlertHostSwap           AlertType = "host_swap"
+	AlertHostInodes         AlertType = "host_inodes"
+	AlertHostResolved       AlertType = "host_resolved"
 )
 
 // Alert represents a system alert
@@ -22,6 +33,22 @@ type Alert struct {
 	Threshold     float64   `json:"threshold,omitempty"`
 	Timestamp     int64     `json:"timestamp"`
 	Acknowledged  bool      `json:"acknowledged"`
+
+	RestartCount   int     `json:"restart_count,omitempty"`
+	RestartHistory []int64 `json:"restart_history,omitempty"` // Unix timestamps of recent restarts
+
+	Exit *ExitDiagnostics `json:"exit,omitempty"`
+
+	Mount string `json:"mount,omitempty"` // Filesystem of host disk and inode alerts
+}
+
+// ExitDiagnostics represents why a container stopped
+type ExitDiagnostics struct {
+	ExitCode   int        `json:"exit_code"`
+	OOMKilled  bool       `json:"oom_killed"`
+	FinishedAt int64      `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Logs       []LogEntry `json:"logs,omitempty"` // Last log lines from stdout and stderr
 }
 
 // AlertConfigResponse represents the alert configuration for API responses
@@ -31,4 +58,13 @@ type AlertConfigResponse struct {
 	MemoryThreshold float64 `json:"memory_threshold"`
 	CheckInterval   string  `json:"check_interval"`
 	WebhookEnabled  bool    `json:"webhook_enabled"`
+
+	CrashLoopRestarts int    `json:"crash_loop_restarts,omitempty"`
+	CrashLoopWindow   string `json:"crash_loop_window,omitempty"`
+
+	HostDiskThreshold   float64 `json:"host_disk_threshold"`
+	HostMemoryThreshold float64 `json:"host_memory_threshold"`
+	HostLoadThreshold   float64 `json:"host_load_threshold"`
+	HostSwapThreshold   float64 `json:"host_swap_threshold"`
+	HostInodeThreshold  float64 `json:"host_inode_threshold"`
 }
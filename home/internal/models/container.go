@@ -2,17 +2,66 @@ package models
 
 // ContainerInfo represents the minimal container information exposed by the API
 type ContainerInfo struct {
-	ID              string            `json:"id"`
-	Names           []string          `json:"names"`
-	Image           string            `json:"image"`
-	ImageID         string            `json:"image_id"`
-	Command         string            `json:"command"`
-	Created         int64             `json:"created"`
-	State           string            `json:"state"`
-	Status          string            `json:"status"`
-	Labels          map[string]string `json:"labels,omitempty"`
-	Host            string            `json:"host"`
-	HistoricalStats *HistoricalStats  `json:"historical_stats,omitempty"`
+	ID              string             `json:"id"`
+	Names           []string           `json:"names"`
+	Image           string             `json:"image"`
+	ImageID         string             `json:"image_id"`
+	Command         string             `json:"command"`
+	Created         int64              `json:"created"`
+	State           string             `json:"state"`
+	Status          string             `json:"status"`
+	Labels          map[string]string  `json:"labels,omitempty"`
+	Host            string             `json:"host"`
+	Health          string             `json:"health,omitempty"` // "healthy", "unhealthy" or "starting"
+	FailingStreak   int                `json:"failing_streak,omitempty"`
+	Ports           []ContainerPort    `json:"ports"`
+	Mounts          []ContainerMount   `json:"mounts"`
+	Networks        []ContainerNetwork `json:"networks"`
+	SizeRw          *int64             `json:"size_rw,omitempty"`      // Only set when sizes are requested
+	SizeRootFs      *int64             `json:"size_root_fs,omitempty"` // Only set when sizes are requested
+	HistoricalStats *HistoricalStats   `json:"historical_stats,omitempty"`
+}
+
+// ContainerPort represents a port exposed by a container
+type ContainerPort struct {
+	HostIP        string `json:"host_ip,omitempty"`
+	HostPort      uint16 `json:"host_port,omitempty"` // Zero when the port is not published
+	ContainerPort uint16 `json:"container_port"`
+	Protocol      string `json:"protocol"`
+}
+
+// ContainerMount represents a volume or bind mount of a container
+type ContainerMount struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	RW          bool   `json:"rw"`
+}
+
+// ContainerNetwork represents a network a container is attached to
+type ContainerNetwork struct {
+	Name        string `json:"name"`
+	NetworkID   string `json:"network_id"`
+	IPAddress   string `json:"ip_address,omitempty"`
+	IPv6Address string `json:"ipv6_address,omitempty"`
+	Gateway     string `json:"gateway,omitempty"`
+	MacAddress  string `json:"mac_address,omitempty"`
+}
+
+// ContainerHealth represents the healthcheck state of a container
+type ContainerHealth struct {
+	Status        string        `json:"status"`
+	FailingStreak int           `json:"failing_streak"`
+	Probes        []HealthProbe `json:"probes"`
+}
+
+// HealthProbe represents the result of a single healthcheck run
+type HealthProbe struct {
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
 }
 
 // HistoricalStats contains historical CPU and memory averages
@@ -51,3 +100,23 @@ func DefaultLogOptions() LogOptions {
 type EnvVariables struct {
 	Env map[string]string `json:"env"`
 }
+
+// ContainerProcesses represents the process list of a container as reported by ps
+type ContainerProcesses struct {
+	Titles    []string   `json:"titles"`
+	Processes [][]string `json:"processes"`
+}
+
+// FilesystemChange represents a path changed in a container's writable layer
+type FilesystemChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"` // "added", "changed" or "deleted"
+}
+
+// FilesystemDiff represents all changes in a container's writable layer
+type FilesystemDiff struct {
+	Changes []FilesystemChange `json:"changes"`
+	Added   int                `json:"added"`
+	Changed int                `json:"changed"`
+	Deleted int                `json:"deleted"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// FileEntry represents a file or directory inside a container
+type FileEntry struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Type       string `json:"type"` // "file", "dir", "symlink" or "other"
+	Mode       string `json:"mode"`
+	Size       int64  `json:"size"`
+	ModTime    int64  `json:"mod_time"`
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+// DirectoryListing represents the contents of a directory inside a container
+type DirectoryListing struct {
+	Path    string      `json:"path"`
+	Entries []FileEntry `json:"entries"`
+	// Truncated is set when the directory was too large to list in full
+	Truncated bool `json:"truncated,omitempty"`
+}
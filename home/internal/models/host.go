@@ -0,0 +1,59 @@
+package models
+
+// HostInfo represents the Docker engine running on a host
+type HostInfo struct {
+	Name              string      `json:"name"`
+	DaemonName        string      `json:"daemon_name"`
+	EngineVersion     string      `json:"engine_version"`
+	APIVersion        string      `json:"api_version"`
+	MinAPIVersion     string      `json:"min_api_version,omitempty"`
+	GitCommit         string      `json:"git_commit,omitempty"`
+	GoVersion         string      `json:"go_version,omitempty"`
+	OperatingSystem   string      `json:"operating_system"`
+	OSType            string      `json:"os_type"`
+	OSVersion         string      `json:"os_version,omitempty"`
+	KernelVersion     string      `json:"kernel_version"`
+	Architecture      string      `json:"architecture"`
+	StorageDriver     string      `json:"storage_driver"`
+	LoggingDriver     string      `json:"logging_driver"`
+	CgroupDriver      string      `json:"cgroup_driver"`
+	CgroupVersion     string      `json:"cgroup_version,omitempty"`
+	DefaultRuntime    string      `json:"default_runtime,omitempty"`
+	DockerRootDir     string      `json:"docker_root_dir,omitempty"`
+	CPUs              int         `json:"cpus"`
+	MemoryTotal       int64       `json:"memory_total"`
+	Containers        int         `json:"containers"`
+	ContainersRunning int         `json:"containers_running"`
+	ContainersPaused  int         `json:"containers_paused"`
+	ContainersStopped int         `json:"containers_stopped"`
+	Images            int         `json:"images"`
+	Warnings          []string    `json:"warnings"`
+	Plugins           HostPlugins `json:"plugins"`
+}
+
+// HostPlugins represents the plugins registered with a Docker engine
+type HostPlugins struct {
+	Volume        []string `json:"volume"`
+	Network       []string `json:"network"`
+	Authorization []string `json:"authorization"`
+	Log           []string `json:"log"`
+}
+
+// HostSummary represents one row of the fleet-wide engine table
+type HostSummary struct {
+	Name              string `json:"name"`
+	EngineVersion     string `json:"engine_version,omitempty"`
+	APIVersion        string `json:"api_version,omitempty"`
+	OperatingSystem   string `json:"operating_system,omitempty"`
+	KernelVersion     string `json:"kernel_version,omitempty"`
+	StorageDriver     string `json:"storage_driver,omitempty"`
+	CgroupVersion     string `json:"cgroup_version,omitempty"`
+	CPUs              int    `json:"cpus"`
+	MemoryTotal       int64  `json:"memory_total"`
+	Containers        int    `json:"containers"`
+	ContainersRunning int    `json:"containers_running"`
+	Images            int    `json:"images"`
+	Warnings          int    `json:"warnings"`
+	Outdated          bool   `json:"outdated"` // Engine is older than the newest engine in the fleet
+	Error             string `json:"error,omitempty"`
+}
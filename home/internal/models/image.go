@@ -10,6 +10,66 @@ type ImageInfo struct {
 	Created     int64             `json:"created"`
 	Labels      map[string]string `json:"labels,omitempty"`
 	Host        string            `json:"host"`
+	Containers  int               `json:"containers"` // Count of containers using the image
+	Unused      bool              `json:"unused"`     // No container on the host uses the image
+	Dangling    bool              `json:"dangling"`   // Untagged image
+}
+
+// ImageDetails represents detailed image information
+type ImageDetails struct {
+	ID          string            `json:"id"`
+	RepoTags    []string          `json:"repo_tags"`
+	RepoDigests []string          `json:"repo_digests,omitempty"`
+	Size        int64             `json:"size"`
+	VirtualSize int64             `json:"virtual_size,omitempty"`
+	Created     int64             `json:"created"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Host        string            `json:"host"`
+	Author      string            `json:"author,omitempty"`
+	Comment     string            `json:"comment,omitempty"`
+	Platform    ImagePlatform     `json:"platform"`
+	Config      ImageConfig       `json:"config"`
+	History     []ImageLayer      `json:"history"`
+	Containers  []ImageContainer  `json:"used_by"`
+	Dangling    bool              `json:"dangling"`
+}
+
+// ImagePlatform represents the platform an image was built for
+type ImagePlatform struct {
+	OS           string `json:"os"`
+	OSVersion    string `json:"os_version,omitempty"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// ImageConfig represents the runtime configuration baked into an image
+type ImageConfig struct {
+	Entrypoint   []string          `json:"entrypoint,omitempty"`
+	Cmd          []string          `json:"cmd,omitempty"`
+	WorkingDir   string            `json:"working_dir,omitempty"`
+	User         string            `json:"user,omitempty"`
+	Env          []string          `json:"env,omitempty"`
+	ExposedPorts []string          `json:"exposed_ports,omitempty"`
+	Volumes      []string          `json:"volumes,omitempty"`
+	StopSignal   string            `json:"stop_signal,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// ImageLayer represents a single entry of an image's build history
+type ImageLayer struct {
+	ID        string   `json:"id"`
+	CreatedBy string   `json:"created_by"`
+	Created   int64    `json:"created"`
+	Size      int64    `json:"size"`
+	Comment   string   `json:"comment,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// ImageContainer represents a container that uses an image
+type ImageContainer struct {
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name"`
+	State         string `json:"state"`
 }
 
 // ImagePullProgress represents progress during image pull
@@ -22,6 +82,20 @@ type ImagePullProgress struct {
 	} `json:"progressDetail,omitempty"`
 	ID    string `json:"id,omitempty"`
 	Error string `json:"error,omitempty"`
+	Host  string `json:"host,omitempty"`
+}
+
+// ImagePullHostResult represents the outcome of an image pull on a single host
+type ImagePullHostResult struct {
+	Host    string `json:"host"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ImagePullSummary is the final message of an image pull stream
+type ImagePullSummary struct {
+	Status  string                `json:"status"`
+	Results []ImagePullHostResult `json:"results"`
 }
 
 // ImageRemoveResult represents the result of removing an image
@@ -29,3 +103,53 @@ type ImageRemoveResult struct {
 	Untagged []string `json:"untagged,omitempty"`
 	Deleted  []string `json:"deleted,omitempty"`
 }
+
+// ImageTransferProgress represents progress while copying or loading an image
+type ImageTransferProgress struct {
+	Status      string `json:"status"`
+	Host        string `json:"host,omitempty"`
+	BytesCopied int64  `json:"bytes_copied,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ImageTransferResult represents the outcome of loading an image on a single host
+type ImageTransferResult struct {
+	Host    string   `json:"host"`
+	Success bool     `json:"success"`
+	Images  []string `json:"images,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// ImageTransferSummary is the final message of an image copy stream
+type ImageTransferSummary struct {
+	Status      string                `json:"status"`
+	BytesCopied int64                 `json:"bytes_copied"`
+	Results     []ImageTransferResult `json:"results"`
+}
+
+// ImageBuildOptions represents the options for building an image
+type ImageBuildOptions struct {
+	Dockerfile string
+	Tags       []string
+	BuildArgs  map[string]string
+	Target     string
+	NoCache    bool
+	PullParent bool
+}
+
+// ImageBuildProgress represents a message streamed during an image build
+type ImageBuildProgress struct {
+	Stream   string         `json:"stream,omitempty"`
+	Status   string         `json:"status,omitempty"`
+	Progress string         `json:"progress,omitempty"`
+	ID       string         `json:"id,omitempty"`
+	Aux      *ImageBuildAux `json:"aux,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	ImageID  string         `json:"image_id,omitempty"`
+}
+
+// ImageBuildAux carries the ID of the built image
+type ImageBuildAux struct {
+	ID string `json:"ID"`
+}
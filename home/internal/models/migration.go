@@ -0,0 +1,57 @@
+package models
+
+// MigrationRequest represents a request to move a container to another host
+type MigrationRequest struct {
+	TargetHost    string `json:"target_host"`
+	Name          string `json:"name,omitempty"`           // Container name on the target, defaults to the source name
+	ImageTransfer string `json:"image_transfer,omitempty"` // "auto" (default), "pull" or "copy"
+	CopyVolumes   *bool  `json:"copy_volumes,omitempty"`   // Defaults to true
+	StopSource    *bool  `json:"stop_source,omitempty"`    // Stop the source while volumes are copied, defaults to true
+	ReuseVolumes  bool   `json:"reuse_volumes,omitempty"`  // Use volumes that already exist on the target as-is
+	SourceAction  string `json:"source_action,omitempty"`  // "keep" (default), "stop" or "remove" once the target is healthy
+	HealthTimeout string `json:"health_timeout,omitempty"` // Go duration, defaults to 2m
+}
+
+// Migration step names, in the order they run
+const (
+	MigrationStepInspect  = "inspect"
+	MigrationStepImage    = "image"
+	MigrationStepNetworks = "networks"
+	MigrationStepVolumes  = "volumes"
+	MigrationStepCreate   = "create"
+	MigrationStepStart    = "start"
+	MigrationStepHealth   = "health"
+	MigrationStepSource   = "source"
+	MigrationStepRollback = "rollback" // Undoes the changes of a failed migration
+)
+
+// Migration step and migration statuses
+const (
+	MigrationRunning   = "running"
+	MigrationCompleted = "completed"
+	MigrationSkipped   = "skipped"
+	MigrationFailed    = "failed"
+)
+
+// MigrationStep represents a progress event of a container migration
+type MigrationStep struct {
+	Step      string `json:"step"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Migration represents the state of a container migration between hosts
+type Migration struct {
+	ID             string          `json:"id"`
+	SourceHost     string          `json:"source_host"`
+	TargetHost     string          `json:"target_host"`
+	ContainerID    string          `json:"container_id"`
+	NewContainerID string          `json:"new_container_id,omitempty"`
+	Status         string          `json:"status"`
+	Error          string          `json:"error,omitempty"`
+	Steps          []MigrationStep `json:"steps"`
+	StartedAt      int64           `json:"started_at"`
+	FinishedAt     int64           `json:"finished_at,omitempty"`
+}
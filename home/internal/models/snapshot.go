@@ -0,0 +1,22 @@
+package models
+
+// SnapshotRequest represents a request to commit a container to a snapshot image
+type SnapshotRequest struct {
+	Repo    string `json:"repo"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+	Author  string `json:"author"`
+	Pause   *bool  `json:"pause,omitempty"` // Defaults to true
+}
+
+// Snapshot represents a container snapshot image created by vps-monitor
+type Snapshot struct {
+	ImageID       string `json:"image_id"`
+	Reference     string `json:"reference"`
+	Host          string `json:"host"`
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name"`
+	Message       string `json:"message,omitempty"`
+	Created       int64  `json:"created"`
+	Size          int64  `json:"size"`
+}
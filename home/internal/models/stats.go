@@ -4,6 +4,7 @@ type ContainerStats struct {
 	ContainerID   string  `json:"container_id"`
 	Host          string  `json:"host"`
 	CPUPercent    float64 `json:"cpu_percent"`
+	CPUUsage      uint64  `json:"cpu_usage"` // Total CPU time consumed in nanoseconds
 	MemoryUsage   uint64  `json:"memory_usage"`
 	MemoryLimit   uint64  `json:"memory_limit"`
 	MemoryPercent float64 `json:"memory_percent"`
@@ -22,3 +23,20 @@ type HistoricalAverages struct {
 	Memory12h float64 `json:"memory_12h"`
 	HasData   bool    `json:"has_data"`
 }
+
+// StatsSeriesPoint is a bucket of a stats time series
+type StatsSeriesPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Avg       float64 `json:"avg"`
+	Last      float64 `json:"last"`
+}
+
+// ContainerStatsSeries is the stats history of a container, per metric
+type ContainerStatsSeries struct {
+	ContainerID   string                        `json:"container_id"`
+	ContainerName string                        `json:"container_name,omitempty"`
+	Host          string                        `json:"host,omitempty"`
+	Metrics       map[string][]StatsSeriesPoint `json:"metrics"`
+}
@@ -0,0 +1,113 @@
+package sinks
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/config"
+	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/stats"
+)
+
+// agentMetricsTimeout bounds reading the metrics of a single agent host
+const agentMetricsTimeout = 10 * time.Second
+
+// Forward writes the samples of the samplers to the sinks until Stop. The
+// metrics of agent hosts are read concurrently at the container sampling
+// interval in their own goroutine, so unreachable agents do not hold back
+// samples. Samples of the machine running vps-monitor are written once,
+// under the name of the first socket host. Hosts read through the stats
+// helper are not pushed, as they lack swap, network and disk I/O.
+func (m *Manager) Forward(dockerClient *docker.MultiHostClient, containerSampler *stats.ContainerSampler, hostSampler *stats.HostSampler) {
+	containerSamples, unsubscribeContainers := containerSampler.Subscribe()
+	hostSamples, unsubscribeHost := hostSampler.Subscribe()
+
+	localHost := ""
+	var agents []config.DockerHost
+	for _, host := range dockerClient.GetHosts() {
+		switch {
+		case host.Agent != nil:
+			agents = append(agents, host)
+		case strings.HasPrefix(host.Host, "unix://") && localHost == "":
+			// Every socket host is the machine running vps-monitor
+			localHost = host.Name
+		}
+	}
+
+	m.forwardWg.Add(1)
+	go func() {
+		defer m.forwardWg.Done()
+		defer unsubscribeContainers()
+		defer unsubscribeHost()
+
+		for {
+			select {
+			case samples := <-containerSamples:
+				points := make([]Point, 0, len(samples))
+				for _, sample := range samples {
+					points = append(points, ContainerPoint(sample.Host, sample.Container, sample.Stats))
+				}
+				m.Write(points...)
+			case metrics := <-hostSamples:
+				if localHost != "" {
+					m.Write(HostPoints(localHost, metrics)...)
+				}
+			case <-m.forwardStopCh:
+				return
+			}
+		}
+	}()
+
+	if len(agents) == 0 {
+		return
+	}
+
+	m.forwardWg.Add(1)
+	go func() {
+		defer m.forwardWg.Done()
+
+		ticker := time.NewTicker(containerSampler.Interval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.Write(agentHostPoints(dockerClient, agents)...)
+			case <-m.forwardStopCh:
+				return
+			}
+		}
+	}()
+}
+
+// agentHostPoints reads the metrics of agent hosts concurrently
+func agentHostPoints(dockerClient *docker.MultiHostClient, agents []config.DockerHost) []Point {
+	var (
+		mu     sync.Mutex
+		points []Point
+		wg     sync.WaitGroup
+	)
+	for _, host := range agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), agentMetricsTimeout)
+			metrics, err := dockerClient.GetHostMetrics(ctx, host.Name)
+			cancel()
+			if err != nil {
+				log.Printf("Metric sinks: failed to get host metrics for %s: %v", host.Name, err)
+				return
+			}
+
+			mu.Lock()
+			points = append(points, HostPoints(host.Name, metrics)...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return points
+}
@@ -0,0 +1,73 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hhftechnology/vps-monitor/internal/config"
+)
+
+// graphiteSink writes points in the Graphite plaintext protocol over TCP, one
+// tagged series per field: prefix.measurement.field;tag=value value timestamp
+type graphiteSink struct {
+	address string
+	prefix  string
+}
+
+func newGraphiteSink(cfg config.MetricSink) *graphiteSink {
+	return &graphiteSink{address: cfg.Address, prefix: cfg.Prefix}
+}
+
+func (s *graphiteSink) Send(ctx context.Context, points []Point) error {
+	var body bytes.Buffer
+	for _, point := range points {
+		s.encode(&body, point)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+	_, err = conn.Write(body.Bytes())
+	return err
+}
+
+func (s *graphiteSink) encode(buf *bytes.Buffer, point Point) {
+	var tags strings.Builder
+	for _, key := range sortedKeys(point.Tags) {
+		if value := point.Tags[key]; value != "" {
+			tags.WriteString(";" + graphiteEscaper.Replace(key) + "=" + graphiteEscaper.Replace(value))
+		}
+	}
+	timestamp := strconv.FormatInt(point.Time.Unix(), 10)
+
+	for _, field := range sortedKeys(point.Fields) {
+		value := point.Fields[field]
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		buf.WriteString(metricPath(s.prefix, point.Measurement, field) + tags.String() + " " +
+			strconv.FormatFloat(value, 'f', -1, 64) + " " + timestamp + "\n")
+	}
+}
+
+// graphiteEscaper replaces the separators of tagged series and lines
+var graphiteEscaper = strings.NewReplacer(";", "_", "=", "_", " ", "_", "~", "_", "\n", "_")
+
+// metricPath joins the dotted name of a Graphite or StatsD metric
+func metricPath(prefix, measurement, field string) string {
+	if prefix == "" {
+		return measurement + "." + field
+	}
+	return prefix + "." + measurement + "." + field
+}
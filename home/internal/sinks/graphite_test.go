@@ -0,0 +1,84 @@
+package sinks
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestGraphiteEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		point  Point
+		want   string
+	}{
+		{
+			name:   "one tagged series per field",
+			prefix: "vps_monitor",
+			point: Point{
+				Measurement: "container",
+				Tags:        map[string]string{"name": "web", "host": "local"},
+				Fields:      map[string]float64{"pids": 12, "cpu_percent": 1.5},
+				Time:        testTime,
+			},
+			want: "vps_monitor.container.cpu_percent;host=local;name=web 1.5 1700000000\n" +
+				"vps_monitor.container.pids;host=local;name=web 12 1700000000\n",
+		},
+		{
+			name: "separators in tags are replaced",
+			point: Point{
+				Measurement: "host_filesystem",
+				Tags:        map[string]string{"mountpoint": "/mnt/my disk;x=y~z", "empty": ""},
+				Fields:      map[string]float64{"used": 1},
+				Time:        testTime,
+			},
+			want: "host_filesystem.used;mountpoint=/mnt/my_disk_x_y_z 1 1700000000\n",
+		},
+		{
+			name: "non-finite fields are skipped",
+			point: Point{
+				Measurement: "host",
+				Fields:      map[string]float64{"nan": math.NaN(), "inf": math.Inf(-1)},
+				Time:        testTime,
+			},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			(&graphiteSink{prefix: tt.prefix}).encode(&buf, tt.point)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphiteEscaper(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"a;b=c d~e\nf", "a_b_c_d_e_f"},
+		{"/var/lib/docker", "/var/lib/docker"},
+	}
+
+	for _, tt := range tests {
+		if got := graphiteEscaper.Replace(tt.input); got != tt.want {
+			t.Errorf("graphiteEscaper(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMetricPath(t *testing.T) {
+	if got := metricPath("", "host", "load1"); got != "host.load1" {
+		t.Errorf("metricPath() without prefix = %q", got)
+	}
+	if got := metricPath("vps", "host", "load1"); got != "vps.host.load1" {
+		t.Errorf("metricPath() with prefix = %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hhftechnology/vps-monitor/internal/config"
+)
+
+// influxSink writes points in the InfluxDB line protocol to a write endpoint
+// such as /api/v2/write?org=acme&bucket=vps or /write?db=vps
+type influxSink struct {
+	url    string
+	token  string
+	prefix string
+	client *http.Client
+}
+
+func newInfluxSink(cfg config.MetricSink) *influxSink {
+	return &influxSink{
+		url:    cfg.URL,
+		token:  cfg.Token,
+		prefix: cfg.Prefix,
+		client: &http.Client{},
+	}
+}
+
+func (s *influxSink) Send(ctx context.Context, points []Point) error {
+	var body bytes.Buffer
+	for _, point := range points {
+		s.encode(&body, point)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("influxdb returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+		// Rejected data or credentials will not succeed on retry
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return &permanentError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+// encode writes a point as a line: measurement,tag=value field=value timestamp
+func (s *influxSink) encode(buf *bytes.Buffer, point Point) {
+	measurement := point.Measurement
+	if s.prefix != "" {
+		measurement = s.prefix + "_" + measurement
+	}
+	buf.WriteString(measurementEscaper.Replace(measurement))
+
+	for _, key := range sortedKeys(point.Tags) {
+		if value := point.Tags[key]; value != "" {
+			buf.WriteString("," + tagEscaper.Replace(key) + "=" + tagEscaper.Replace(value))
+		}
+	}
+
+	separator := " "
+	for _, key := range sortedKeys(point.Fields) {
+		value := point.Fields[key]
+		// The line protocol has no representation for these
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		buf.WriteString(separator + tagEscaper.Replace(key) + "=" + strconv.FormatFloat(value, 'f', -1, 64))
+		separator = ","
+	}
+
+	buf.WriteString(" " + strconv.FormatInt(point.Time.UnixNano(), 10) + "\n")
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
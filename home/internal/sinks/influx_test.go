@@ -0,0 +1,93 @@
+package sinks
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+var testTime = time.Unix(1700000000, 500)
+
+func TestInfluxEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		point  Point
+		want   string
+	}{
+		{
+			name:   "tags and fields are sorted",
+			prefix: "vps_monitor",
+			point: Point{
+				Measurement: "container",
+				Tags:        map[string]string{"name": "web", "host": "local"},
+				Fields:      map[string]float64{"pids": 12, "cpu_percent": 1.5},
+				Time:        testTime,
+			},
+			want: "vps_monitor_container,host=local,name=web cpu_percent=1.5,pids=12 1700000000000000500\n",
+		},
+		{
+			name: "escaped measurement, tags and fields",
+			point: Point{
+				Measurement: "my measurement,x",
+				Tags:        map[string]string{"a b": "c,d=e", "line": "x\ny"},
+				Fields:      map[string]float64{"f=1": 2},
+				Time:        testTime,
+			},
+			want: `my\ measurement\,x,a\ b=c\,d\=e,line=x\ny f\=1=2 1700000000000000500` + "\n",
+		},
+		{
+			name: "empty tags and non-finite fields are skipped",
+			point: Point{
+				Measurement: "host",
+				Tags:        map[string]string{"host": "local", "compose_project": ""},
+				Fields:      map[string]float64{"nan": math.NaN(), "inf": math.Inf(1), "load1": 0.25},
+				Time:        testTime,
+			},
+			want: "host,host=local load1=0.25 1700000000000000500\n",
+		},
+		{
+			name: "large values are not written in exponent form",
+			point: Point{
+				Measurement: "host",
+				Fields:      map[string]float64{"memory_total": 17179869184, "tiny": 1e-7},
+				Time:        testTime,
+			},
+			want: "host memory_total=17179869184,tiny=0.0000001 1700000000000000500\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			(&influxSink{prefix: tt.prefix}).encode(&buf, tt.point)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfluxEscapers(t *testing.T) {
+	tests := []struct {
+		input           string
+		wantMeasurement string
+		wantTag         string
+	}{
+		{"plain", "plain", "plain"},
+		{"a,b", `a\,b`, `a\,b`},
+		{"a b", `a\ b`, `a\ b`},
+		{"a=b", "a=b", `a\=b`},
+		{"a\nb", `a\nb`, `a\nb`},
+	}
+
+	for _, tt := range tests {
+		if got := measurementEscaper.Replace(tt.input); got != tt.wantMeasurement {
+			t.Errorf("measurementEscaper(%q) = %q, want %q", tt.input, got, tt.wantMeasurement)
+		}
+		if got := tagEscaper.Replace(tt.input); got != tt.wantTag {
+			t.Errorf("tagEscaper(%q) = %q, want %q", tt.input, got, tt.wantTag)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package sinks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/models"
+	"github.com/hhftechnology/vps-monitor/internal/stats"
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// Point is a sample of a measurement, identified by its tags
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]float64
+	Time        time.Time
+}
+
+// composeProjectLabel is set by Docker Compose on the containers of a project
+const composeProjectLabel = "com.docker.compose.project"
+
+// ContainerPoint converts the stats of a container
+func ContainerPoint(host string, ctr models.ContainerInfo, containerStats models.ContainerStats) Point {
+	name := ctr.ID
+	if len(ctr.Names) > 0 {
+		name = strings.TrimPrefix(ctr.Names[0], "/")
+	}
+
+	tags := map[string]string{"host": host, "name": name, "image": ctr.Image}
+	if project := ctr.Labels[composeProjectLabel]; project != "" {
+		tags["compose_project"] = project
+	}
+
+	timestamp := time.Now()
+	if containerStats.Timestamp > 0 {
+		timestamp = time.Unix(containerStats.Timestamp, 0)
+	}
+
+	return Point{
+		Measurement: "container",
+		Tags:        tags,
+		Fields: map[string]float64{
+			stats.MetricCPUPercent:    containerStats.CPUPercent,
+			stats.MetricMemoryUsage:   float64(containerStats.MemoryUsage),
+			stats.MetricMemoryLimit:   float64(containerStats.MemoryLimit),
+			stats.MetricMemoryPercent: containerStats.MemoryPercent,
+			stats.MetricNetworkRx:     float64(containerStats.NetworkRx),
+			stats.MetricNetworkTx:     float64(containerStats.NetworkTx),
+			stats.MetricBlockRead:     float64(containerStats.BlockRead),
+			stats.MetricBlockWrite:    float64(containerStats.BlockWrite),
+			stats.MetricPIDs:          float64(containerStats.PIDs),
+		},
+		Time: timestamp,
+	}
+}
+
+// HostPoints converts host metrics into a point for the host and one per
+// filesystem, network interface and disk
+func HostPoints(host string, metrics *system.HostMetrics) []Point {
+	timestamp := time.Unix(metrics.Timestamp, 0)
+
+	points := []Point{{
+		Measurement: "host",
+		Tags:        map[string]string{"host": host},
+		Fields: map[string]float64{
+			"cpu_percent":      metrics.CPU.Percent,
+			"load1":            metrics.Load.Load1,
+			"load5":            metrics.Load.Load5,
+			"load15":           metrics.Load.Load15,
+			"memory_total":     float64(metrics.Memory.Total),
+			"memory_used":      float64(metrics.Memory.Used),
+			"memory_available": float64(metrics.Memory.Available),
+			"memory_percent":   metrics.Memory.Percent,
+			"swap_total":       float64(metrics.Swap.Total),
+			"swap_used":        float64(metrics.Swap.Used),
+			"swap_percent":     metrics.Swap.Percent,
+		},
+		Time: timestamp,
+	}}
+
+	for _, fs := range metrics.Filesystems {
+		points = append(points, Point{
+			Measurement: "host_filesystem",
+			Tags:        map[string]string{"host": host, "mountpoint": fs.Mountpoint, "device": fs.Device},
+			Fields: map[string]float64{
+				"total":          float64(fs.Total),
+				"used":           float64(fs.Used),
+				"percent":        fs.Percent,
+				"inodes_used":    float64(fs.InodesUsed),
+				"inodes_percent": fs.InodesPercent,
+			},
+			Time: timestamp,
+		})
+	}
+
+	for _, iface := range metrics.Network {
+		points = append(points, Point{
+			Measurement: "host_network",
+			Tags:        map[string]string{"host": host, "interface": iface.Name},
+			Fields: map[string]float64{
+				"bytes_recv":       float64(iface.BytesRecv),
+				"bytes_sent":       float64(iface.BytesSent),
+				"rx_bytes_per_sec": iface.RxBytesPerSec,
+				"tx_bytes_per_sec": iface.TxBytesPerSec,
+			},
+			Time: timestamp,
+		})
+	}
+
+	for _, disk := range metrics.DiskIO {
+		points = append(points, Point{
+			Measurement: "host_disk",
+			Tags:        map[string]string{"host": host, "device": disk.Device},
+			Fields: map[string]float64{
+				"read_bytes":          float64(disk.ReadBytes),
+				"write_bytes":         float64(disk.WriteBytes),
+				"read_bytes_per_sec":  disk.ReadBytesPerSec,
+				"write_bytes_per_sec": disk.WriteBytesPerSec,
+				"busy_percent":        disk.BusyPercent,
+			},
+			Time: timestamp,
+		})
+	}
+
+	return points
+}
@@ -0,0 +1,299 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/config"
+)
+
+// Sink sends a batch of points to a metrics backend
+type Sink interface {
+	Send(ctx context.Context, points []Point) error
+}
+
+// permanentError marks a failure retrying cannot fix, such as a rejected
+// payload, so the batch is dropped instead of kept
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+const (
+	sendTimeout  = 10 * time.Second
+	sendAttempts = 3
+	retryBackoff = time.Second
+)
+
+// Manager buffers points and sends them to every configured sink in batches
+type Manager struct {
+	workers []*worker
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+
+	forwardStopCh chan struct{} // Stops Forward before the workers flush
+	forwardWg     sync.WaitGroup
+}
+
+// NewManager creates a manager for the configured sinks
+func NewManager(configs []config.MetricSink) (*Manager, error) {
+	m := &Manager{stopCh: make(chan struct{}), forwardStopCh: make(chan struct{})}
+
+	for _, cfg := range configs {
+		var sink Sink
+		switch cfg.Type {
+		case config.SinkInfluxDB:
+			sink = newInfluxSink(cfg)
+		case config.SinkGraphite:
+			sink = newGraphiteSink(cfg)
+		case config.SinkStatsD:
+			sink = newStatsDSink(cfg)
+		default:
+			return nil, fmt.Errorf("unknown type %q of metric sink %s", cfg.Type, cfg.Name)
+		}
+
+		m.workers = append(m.workers, &worker{
+			name:   cfg.Name,
+			sink:   sink,
+			config: cfg,
+			notify: make(chan struct{}, 1),
+			buffer: pointRing{limit: cfg.BufferSize},
+		})
+	}
+
+	return m, nil
+}
+
+// Start begins sending buffered points in the background
+func (m *Manager) Start() {
+	for _, w := range m.workers {
+		m.wg.Add(1)
+		go w.run(m.stopCh, &m.wg)
+	}
+}
+
+// Stop stops forwarding, sends what is still buffered and stops the workers
+func (m *Manager) Stop() {
+	close(m.forwardStopCh)
+	m.forwardWg.Wait()
+
+	close(m.stopCh)
+	m.wg.Wait()
+}
+
+// Write queues points for every sink
+func (m *Manager) Write(points ...Point) {
+	for _, w := range m.workers {
+		w.enqueue(points)
+	}
+}
+
+// worker buffers the points of a single sink. Points that could not be sent
+// stay buffered and are retried with the next batch; when the buffer is full
+// the oldest points are dropped.
+type worker struct {
+	name   string
+	sink   Sink
+	config config.MetricSink
+	notify chan struct{}
+
+	mu      sync.Mutex
+	buffer  pointRing
+	head    int // Points removed from the front of buffer so far
+	dropped int // Points dropped since last logged
+}
+
+func (w *worker) enqueue(points []Point) {
+	w.mu.Lock()
+	overwritten := w.buffer.push(points)
+	w.head += overwritten
+	w.dropped += overwritten
+	full := w.buffer.count >= w.config.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *worker) run(stopCh <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			w.flush(nil)
+			return
+		case <-ticker.C:
+			w.flush(stopCh)
+		case <-w.notify:
+			w.flush(stopCh)
+		}
+	}
+}
+
+// flush sends buffered points batch by batch until the buffer is empty or a
+// batch fails. Retries are cut short once stopCh is closed.
+func (w *worker) flush(stopCh <-chan struct{}) {
+	w.mu.Lock()
+	if w.dropped > 0 {
+		log.Printf("Metric sink %s: buffer full, dropped %d samples", w.name, w.dropped)
+		w.dropped = 0
+	}
+	w.mu.Unlock()
+
+	for {
+		w.mu.Lock()
+		batch := w.buffer.peek(w.config.BatchSize)
+		start := w.head
+		w.mu.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		err := w.send(batch, stopCh)
+		var permanent *permanentError
+		if err != nil && !errors.As(err, &permanent) {
+			log.Printf("Metric sink %s: failed to send %d samples, keeping them buffered: %v", w.name, len(batch), err)
+			return
+		}
+		if err != nil {
+			log.Printf("Metric sink %s: dropping %d samples: %v", w.name, len(batch), err)
+		}
+
+		w.mu.Lock()
+		// Points dropped for space while sending were part of the batch
+		if sent := len(batch) - (w.head - start); sent > 0 {
+			w.buffer.drop(sent)
+			w.head += sent
+		}
+		w.mu.Unlock()
+	}
+}
+
+// pointRing is a bounded FIFO of points. It grows up to limit and then
+// overwrites the oldest points, so neither adding nor removing points moves
+// the others.
+type pointRing struct {
+	points []Point
+	start  int // Index of the oldest point
+	count  int
+	limit  int
+}
+
+// push appends points and returns how many of the oldest were overwritten to
+// make room
+func (r *pointRing) push(points []Point) int {
+	overwritten := 0
+	for _, point := range points {
+		if r.count == len(r.points) && len(r.points) < r.limit {
+			r.grow()
+		}
+		if len(r.points) == 0 {
+			overwritten++
+			continue
+		}
+		if r.count == len(r.points) {
+			r.points[r.start] = point
+			r.start = (r.start + 1) % len(r.points)
+			overwritten++
+			continue
+		}
+		r.points[(r.start+r.count)%len(r.points)] = point
+		r.count++
+	}
+	return overwritten
+}
+
+func (r *pointRing) grow() {
+	points := make([]Point, min(max(2*len(r.points), 64), r.limit))
+	for i := range r.count {
+		points[i] = r.points[(r.start+i)%len(r.points)]
+	}
+	r.points = points
+	r.start = 0
+}
+
+// peek returns a copy of up to n of the oldest points
+func (r *pointRing) peek(n int) []Point {
+	batch := make([]Point, min(n, r.count))
+	for i := range batch {
+		batch[i] = r.points[(r.start+i)%len(r.points)]
+	}
+	return batch
+}
+
+// drop removes up to n of the oldest points
+func (r *pointRing) drop(n int) {
+	n = min(n, r.count)
+	if n <= 0 {
+		return
+	}
+	for i := range n {
+		r.points[(r.start+i)%len(r.points)] = Point{}
+	}
+	r.start = (r.start + n) % len(r.points)
+	r.count -= n
+}
+
+// send tries a batch up to sendAttempts times with a growing delay
+func (w *worker) send(batch []Point, stopCh <-chan struct{}) error {
+	points := w.withTags(batch)
+
+	var err error
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = w.sink.Send(ctx, points)
+		cancel()
+
+		var permanent *permanentError
+		if err == nil || errors.As(err, &permanent) || attempt == sendAttempts || stopCh == nil {
+			return err
+		}
+
+		select {
+		case <-stopCh:
+			return err
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// withTags returns the points with the tags of the sink added, keeping the
+// tags of a point when both set the same key
+func (w *worker) withTags(points []Point) []Point {
+	if len(w.config.Tags) == 0 {
+		return points
+	}
+
+	tagged := make([]Point, len(points))
+	for i, point := range points {
+		tags := maps.Clone(w.config.Tags)
+		maps.Copy(tags, point.Tags)
+		point.Tags = tags
+		tagged[i] = point
+	}
+	return tagged
+}
+
+// sortedKeys returns the keys of m in order, so encoded samples are stable
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
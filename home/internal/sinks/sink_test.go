@@ -0,0 +1,99 @@
+package sinks
+
+import (
+	"slices"
+	"testing"
+)
+
+// ringValues returns the field "v" of up to n of the oldest points in r
+func ringValues(r *pointRing, n int) []float64 {
+	var values []float64
+	for _, point := range r.peek(n) {
+		values = append(values, point.Fields["v"])
+	}
+	return values
+}
+
+func valuePoints(values ...float64) []Point {
+	points := make([]Point, len(values))
+	for i, v := range values {
+		points[i] = Point{Measurement: "test", Fields: map[string]float64{"v": v}}
+	}
+	return points
+}
+
+func TestPointRing(t *testing.T) {
+	r := pointRing{limit: 4}
+
+	if got := r.push(valuePoints(1, 2, 3)); got != 0 {
+		t.Errorf("push() below the limit overwrote %d points", got)
+	}
+	if got := ringValues(&r, 2); !slices.Equal(got, []float64{1, 2}) {
+		t.Errorf("peek(2) = %v, want [1 2]", got)
+	}
+	if r.count != 3 {
+		t.Errorf("peek() removed points, count = %d", r.count)
+	}
+
+	// Overwriting keeps the newest points in order
+	if got := r.push(valuePoints(4, 5, 6)); got != 2 {
+		t.Errorf("push() past the limit overwrote %d points, want 2", got)
+	}
+	if got := ringValues(&r, 10); !slices.Equal(got, []float64{3, 4, 5, 6}) {
+		t.Errorf("peek(10) = %v, want [3 4 5 6]", got)
+	}
+
+	r.drop(3)
+	if got := ringValues(&r, 10); !slices.Equal(got, []float64{6}) {
+		t.Errorf("after drop(3) peek() = %v, want [6]", got)
+	}
+
+	// Pushing after the start has wrapped fills the free slots in order
+	r.push(valuePoints(7, 8))
+	if got := ringValues(&r, 10); !slices.Equal(got, []float64{6, 7, 8}) {
+		t.Errorf("peek() after wrapping = %v, want [6 7 8]", got)
+	}
+
+	r.drop(10)
+	if r.count != 0 || len(r.peek(10)) != 0 {
+		t.Errorf("drop() past the end left %d points", r.count)
+	}
+	r.drop(1)
+}
+
+func TestPointRingGrows(t *testing.T) {
+	r := pointRing{limit: 1000}
+
+	var values []float64
+	for i := range 100 {
+		values = append(values, float64(i))
+	}
+	r.push(valuePoints(values[:50]...))
+	r.drop(10)
+	r.push(valuePoints(values[50:]...))
+
+	if len(r.points) != 128 {
+		t.Errorf("got capacity %d, want 128 after growing twice", len(r.points))
+	}
+	if got := ringValues(&r, 1000); !slices.Equal(got, values[10:]) {
+		t.Errorf("peek() after growing = %v, want %v", got, values[10:])
+	}
+
+	// Growth stops at the limit
+	small := pointRing{limit: 10}
+	if got := small.push(valuePoints(values...)); got != 90 {
+		t.Errorf("push() overwrote %d points, want 90", got)
+	}
+	if len(small.points) != 10 {
+		t.Errorf("got capacity %d, want the limit 10", len(small.points))
+	}
+	if got := ringValues(&small, 100); !slices.Equal(got, values[90:]) {
+		t.Errorf("peek() = %v, want %v", got, values[90:])
+	}
+
+	// A zero limit keeps nothing
+	empty := pointRing{}
+	if got := empty.push(valuePoints(1, 2)); got != 2 || empty.count != 0 {
+		t.Errorf("push() with no room overwrote %d points and kept %d", got, empty.count)
+	}
+}
@@ -0,0 +1,93 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hhftechnology/vps-monitor/internal/config"
+)
+
+// statsdMaxPacket keeps datagrams below common network MTUs
+const statsdMaxPacket = 1432
+
+// statsdSink sends every field as a gauge over UDP, with tags in the
+// DogStatsD format understood by Telegraf, Datadog and statsd_exporter:
+// prefix.measurement.field:value|g|#tag:value
+type statsdSink struct {
+	address string
+	prefix  string
+}
+
+func newStatsDSink(cfg config.MetricSink) *statsdSink {
+	return &statsdSink{address: cfg.Address, prefix: cfg.Prefix}
+}
+
+func (s *statsdSink) Send(ctx context.Context, points []Point) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Lines are packed into datagrams, StatsD has no timestamps
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+
+	for _, point := range points {
+		for _, line := range s.encode(point) {
+			if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		}
+	}
+	return flush()
+}
+
+func (s *statsdSink) encode(point Point) []string {
+	var tags []string
+	for _, key := range sortedKeys(point.Tags) {
+		if value := point.Tags[key]; value != "" {
+			tags = append(tags, statsdEscaper.Replace(key)+":"+statsdEscaper.Replace(value))
+		}
+	}
+	suffix := "|g"
+	if len(tags) > 0 {
+		suffix += "|#" + strings.Join(tags, ",")
+	}
+
+	lines := make([]string, 0, len(point.Fields))
+	for _, field := range sortedKeys(point.Fields) {
+		value := point.Fields[field]
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		// A leading sign would make the gauge relative
+		if value < 0 {
+			value = 0
+		}
+		lines = append(lines, statsdEscaper.Replace(metricPath(s.prefix, point.Measurement, field))+":"+
+			strconv.FormatFloat(value, 'f', -1, 64)+suffix)
+	}
+	return lines
+}
+
+// statsdEscaper replaces the separators of the StatsD line format
+var statsdEscaper = strings.NewReplacer(" ", "_", ":", "_", "|", "_", ",", "_", "#", "_", "@", "_", "\n", "_")
@@ -0,0 +1,120 @@
+package sinks
+
+import (
+	"context"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsDEncode(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		point  Point
+		want   []string
+	}{
+		{
+			name:   "gauges with DogStatsD tags",
+			prefix: "vps_monitor",
+			point: Point{
+				Measurement: "container",
+				Tags:        map[string]string{"name": "web", "host": "local", "image": ""},
+				Fields:      map[string]float64{"pids": 12, "cpu_percent": 1.5},
+				Time:        testTime,
+			},
+			want: []string{
+				"vps_monitor.container.cpu_percent:1.5|g|#host:local,name:web",
+				"vps_monitor.container.pids:12|g|#host:local,name:web",
+			},
+		},
+		{
+			name: "no tags",
+			point: Point{
+				Measurement: "host",
+				Fields:      map[string]float64{"load1": 0.5},
+			},
+			want: []string{"host.load1:0.5|g"},
+		},
+		{
+			name: "separators are replaced",
+			point: Point{
+				Measurement: "host_network",
+				Tags:        map[string]string{"interface": "eth0:1|x,y#z@w", "host": "a b"},
+				Fields:      map[string]float64{"rx:bytes": 1},
+			},
+			want: []string{"host_network.rx_bytes:1|g|#host:a_b,interface:eth0_1_x_y_z_w"},
+		},
+		{
+			name: "negative values are clamped and non-finite ones skipped",
+			point: Point{
+				Measurement: "host",
+				Fields:      map[string]float64{"a": -3, "b": math.NaN(), "c": math.Inf(1)},
+			},
+			want: []string{"host.a:0|g"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := (&statsdSink{prefix: tt.prefix}).encode(tt.point)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStatsDSendPacksDatagrams sends more lines than fit in one datagram and
+// checks every line arrives once, in datagrams within the size limit
+func TestStatsDSendPacksDatagrams(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	defer conn.Close()
+
+	var points []Point
+	var want []string
+	for i := range 100 {
+		field := "field_" + strconv.Itoa(i)
+		points = append(points, Point{Measurement: "host", Fields: map[string]float64{field: float64(i)}})
+		want = append(want, "host."+field+":"+strconv.Itoa(i)+"|g")
+	}
+
+	sink := &statsdSink{address: conn.LocalAddr().String()}
+	if err := sink.Send(context.Background(), points); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var got []string
+	packet := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(got) < len(want) {
+		n, _, err := conn.ReadFrom(packet)
+		if err != nil {
+			t.Fatalf("received %d of %d lines: %v", len(got), len(want), err)
+		}
+		if n > statsdMaxPacket {
+			t.Errorf("datagram of %d bytes exceeds %d", n, statsdMaxPacket)
+		}
+		got = append(got, strings.Split(string(packet[:n]), "\n")...)
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestStatsDEscaper(t *testing.T) {
+	if got := statsdEscaper.Replace("a b:c|d,e#f@g\nh"); got != "a_b_c_d_e_f_g_h" {
+		t.Errorf("statsdEscaper() = %q", got)
+	}
+	if got := statsdEscaper.Replace("web-1.example"); got != "web-1.example" {
+		t.Errorf("statsdEscaper() changed %q", got)
+	}
+}
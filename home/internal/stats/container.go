@@ -0,0 +1,303 @@
+package stats
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/docker"
+	"github.com/hhftechnology/vps-monitor/internal/models"
+)
+
+// containerSampleConcurrency bounds the stats requests in flight per round
+const containerSampleConcurrency = 8
+
+// maxTrackedStarts bounds the recent starts kept per container
+const maxTrackedStarts = 64
+
+// ContainerSample is the stats of a running container at one point in time
+type ContainerSample struct {
+	Host      string
+	Container models.ContainerInfo
+	Stats     models.ContainerStats
+}
+
+// ContainerSampler collects the stats of every running container in the
+// background and records them in the history, independently of alerting. It
+// also reads container starts from the event log of each daemon and keeps
+// the restart count of every container, inspecting a container only when it
+// is first seen or has started since the previous round. The history of
+// containers that were removed is deleted.
+type ContainerSampler struct {
+	docker   *docker.MultiHostClient
+	history  *HistoryManager
+	interval time.Duration
+
+	startsSince map[string]time.Time           // Start of the next container event window by host
+	containers  map[string]map[string]struct{} // Container IDs listed by host in the previous round
+	swept       bool                           // Whether history of containers removed while stopped was deleted
+
+	mu          sync.RWMutex
+	latest      []ContainerSample
+	restarts    map[string]int         // Restart count by host and container ID
+	starts      map[string][]time.Time // Recent starts by host and container ID
+	subscribers map[chan []ContainerSample]struct{}
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewContainerSampler creates a sampler collecting every interval into history
+func NewContainerSampler(dockerClient *docker.MultiHostClient, history *HistoryManager, interval time.Duration) *ContainerSampler {
+	return &ContainerSampler{
+		docker:   dockerClient,
+		history:  history,
+		interval: interval,
+
+		startsSince: make(map[string]time.Time),
+		containers:  make(map[string]map[string]struct{}),
+		restarts:    make(map[string]int),
+		starts:      make(map[string][]time.Time),
+		subscribers: make(map[chan []ContainerSample]struct{}),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start begins background sampling
+func (s *ContainerSampler) Start() {
+	s.wg.Add(1)
+	go s.sampleLoop()
+}
+
+// Stop stops background sampling
+func (s *ContainerSampler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// Interval returns the sampling interval
+func (s *ContainerSampler) Interval() time.Duration {
+	return s.interval
+}
+
+// History returns the recorded stats history
+func (s *ContainerSampler) History() *HistoryManager {
+	return s.history
+}
+
+func (s *ContainerSampler) sampleLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sample()
+
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (s *ContainerSampler) sample() {
+	ctx, cancel := context.WithTimeout(context.Background(), max(s.interval, 30*time.Second))
+	defer cancel()
+
+	containersMap, hostErrors, err := s.docker.ListContainersAllHosts(ctx)
+	if err != nil {
+		log.Printf("Container sampler: failed to list containers: %v", err)
+		return
+	}
+
+	s.cleanupRemoved(containersMap, hostErrors)
+	started := s.containerStarts(ctx, containersMap)
+
+	var (
+		mu       sync.Mutex
+		samples  []ContainerSample
+		restarts = make(map[string]int)
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, containerSampleConcurrency)
+	for hostName, containers := range containersMap {
+		for _, ctr := range containers {
+			key := restartKey(hostName, ctr.ID)
+			count, known := s.restarts[key]
+			inspect := !known || len(started[key]) > 0
+			if known {
+				mu.Lock()
+				restarts[key] = count
+				mu.Unlock()
+			}
+			if !inspect && ctr.State != "running" {
+				continue
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				// The restart count only changes when the container starts
+				if inspect {
+					if info, err := s.docker.GetContainer(ctx, hostName, ctr.ID); err == nil {
+						mu.Lock()
+						restarts[key] = info.RestartCount
+						mu.Unlock()
+					}
+				}
+				if ctr.State != "running" {
+					return
+				}
+
+				containerStats, err := s.docker.GetContainerStatsOnce(ctx, hostName, ctr.ID)
+				if err != nil {
+					return
+				}
+				s.history.RecordStats(ctr.ID, *containerStats)
+
+				mu.Lock()
+				samples = append(samples, ContainerSample{Host: hostName, Container: ctr, Stats: *containerStats})
+				mu.Unlock()
+			}()
+		}
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = samples
+	s.restarts = restarts
+
+	starts := make(map[string][]time.Time, len(s.starts))
+	for hostName, containers := range containersMap {
+		for _, ctr := range containers {
+			key := restartKey(hostName, ctr.ID)
+			recent := append(s.starts[key], started[key]...)
+			if len(recent) > 0 {
+				starts[key] = recent[max(len(recent)-maxTrackedStarts, 0):]
+			}
+		}
+	}
+	s.starts = starts
+
+	for ch := range s.subscribers {
+		// Drop the round for slow subscribers rather than block sampling
+		select {
+		case ch <- samples:
+		default:
+		}
+	}
+}
+
+// cleanupRemoved deletes the history of containers that are no longer
+// listed by their host. Hosts that could not be listed keep theirs. Once
+// every host is listed, history of containers removed while the server was
+// stopped is deleted as well.
+func (s *ContainerSampler) cleanupRemoved(containersMap map[string][]models.ContainerInfo, hostErrors []docker.HostError) {
+	current := make(map[string]struct{})
+	for hostName, containers := range containersMap {
+		ids := make(map[string]struct{}, len(containers))
+		for _, ctr := range containers {
+			ids[ctr.ID] = struct{}{}
+			current[ctr.ID] = struct{}{}
+		}
+
+		for id := range s.containers[hostName] {
+			if _, ok := ids[id]; !ok {
+				s.history.CleanupContainer(id)
+			}
+		}
+		s.containers[hostName] = ids
+	}
+
+	if s.swept || len(hostErrors) > 0 {
+		return
+	}
+	s.swept = true
+	for _, id := range s.history.Containers() {
+		if _, ok := current[id]; !ok {
+			s.history.CleanupContainer(id)
+		}
+	}
+}
+
+// containerStarts returns the start times of containers started since the
+// previous round by host and container ID, read from the event log of each
+// daemon. The first round of a host only records where the next one begins.
+func (s *ContainerSampler) containerStarts(ctx context.Context, containersMap map[string][]models.ContainerInfo) map[string][]time.Time {
+	now := time.Now()
+	started := make(map[string][]time.Time)
+
+	for hostName := range containersMap {
+		since, exists := s.startsSince[hostName]
+		s.startsSince[hostName] = now
+		if !exists {
+			continue
+		}
+
+		starts, err := s.docker.GetContainerStarts(ctx, hostName, since, now)
+		if err != nil {
+			// Keep the window so the starts are picked up by the next round
+			s.startsSince[hostName] = since
+			log.Printf("Container sampler: failed to read container events of %s: %v", hostName, err)
+			continue
+		}
+		for id, times := range starts {
+			started[restartKey(hostName, id)] = times
+		}
+	}
+
+	return started
+}
+
+func restartKey(hostName, id string) string {
+	return hostName + ":" + id
+}
+
+// Latest returns the samples of the most recent round. Containers that were
+// not running or whose stats could not be read are missing.
+func (s *ContainerSampler) Latest() []ContainerSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest
+}
+
+// Subscribe returns a channel receiving the samples of every round and a
+// function that must be called to unsubscribe
+func (s *ContainerSampler) Subscribe() (<-chan []ContainerSample, func()) {
+	ch := make(chan []ContainerSample, 1)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+// RestartCount returns how often Docker restarted a container, as of the most
+// recent round
+func (s *ContainerSampler) RestartCount(hostName, id string) (int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count, ok := s.restarts[restartKey(hostName, id)]
+	return count, ok
+}
+
+// Starts returns the most recent start times of a container seen in the
+// event log since sampling began, oldest first
+func (s *ContainerSampler) Starts(hostName, id string) []time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.starts[restartKey(hostName, id)]
+}
@@ -1,102 +1,143 @@
 package stats
 
 import (
-	"sync"
+	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/hhftechnology/vps-monitor/internal/models"
+	"github.com/hhftechnology/vps-monitor/internal/tsdb"
 )
 
-type DataPoint struct {
-	CPUPercent    float64
-	MemoryPercent float64
-	Timestamp     time.Time
+// Metric names of container stats, matching the JSON fields of
+// models.ContainerStats
+const (
+	MetricCPUPercent    = "cpu_percent"
+	MetricMemoryUsage   = "memory_usage"
+	MetricMemoryLimit   = "memory_limit"
+	MetricMemoryPercent = "memory_percent"
+	MetricNetworkRx     = "network_rx"
+	MetricNetworkTx     = "network_tx"
+	MetricBlockRead     = "block_read"
+	MetricBlockWrite    = "block_write"
+	MetricPIDs          = "pids"
+)
+
+// rateSuffix turns a counter metric into its per-second rate, such as
+// network_rx_rate
+const rateSuffix = "_rate"
+
+// Metrics lists the metrics that can be queried, counters also as rates
+var Metrics = []string{
+	MetricCPUPercent, MetricMemoryUsage, MetricMemoryLimit, MetricMemoryPercent,
+	MetricNetworkRx, MetricNetworkTx, MetricBlockRead, MetricBlockWrite, MetricPIDs,
+	MetricNetworkRx + rateSuffix, MetricNetworkTx + rateSuffix,
+	MetricBlockRead + rateSuffix, MetricBlockWrite + rateSuffix,
 }
 
-type ContainerHistory struct {
-	mu         sync.RWMutex
-	dataPoints []DataPoint
-	maxSize    int
+// IsMetric reports whether name is a queryable metric
+func IsMetric(name string) bool {
+	return slices.Contains(Metrics, name)
 }
 
+// HistoryManager records container stats in a time-series store, keyed by
+// container ID
 type HistoryManager struct {
-	mu         sync.RWMutex
-	containers map[string]*ContainerHistory
-	maxSize    int
+	store *tsdb.DB
 }
 
-func NewHistoryManager() *HistoryManager {
-	return &HistoryManager{
-		containers: make(map[string]*ContainerHistory),
-		maxSize:    2000, // Store ~16+ hours of data (at 30s interval) for 12h averages
-	}
+func NewHistoryManager(store *tsdb.DB) *HistoryManager {
+	return &HistoryManager{store: store}
 }
 
 func (hm *HistoryManager) RecordStats(containerID string, stats models.ContainerStats) {
-	hm.mu.Lock()
-	defer hm.mu.Unlock()
-
-	if _, exists := hm.containers[containerID]; !exists {
-		hm.containers[containerID] = &ContainerHistory{
-			dataPoints: make([]DataPoint, 0, hm.maxSize),
-			maxSize:    hm.maxSize,
-		}
+	timestamp := stats.Timestamp
+	if timestamp == 0 {
+		timestamp = time.Now().Unix()
 	}
 
-	history := hm.containers[containerID]
-	history.mu.Lock()
-	defer history.mu.Unlock()
-
-	dataPoint := DataPoint{
-		CPUPercent:    stats.CPUPercent,
-		MemoryPercent: stats.MemoryPercent,
-		Timestamp:     time.Unix(stats.Timestamp, 0),
-	}
-
-	history.dataPoints = append(history.dataPoints, dataPoint)
-
-	if len(history.dataPoints) > history.maxSize {
-		history.dataPoints = history.dataPoints[1:]
+	err := hm.store.Append(containerID, timestamp, map[string]float64{
+		MetricCPUPercent:    stats.CPUPercent,
+		MetricMemoryUsage:   float64(stats.MemoryUsage),
+		MetricMemoryLimit:   float64(stats.MemoryLimit),
+		MetricMemoryPercent: stats.MemoryPercent,
+		MetricNetworkRx:     float64(stats.NetworkRx),
+		MetricNetworkTx:     float64(stats.NetworkTx),
+		MetricBlockRead:     float64(stats.BlockRead),
+		MetricBlockWrite:    float64(stats.BlockWrite),
+		MetricPIDs:          float64(stats.PIDs),
+	})
+	if err != nil {
+		log.Printf("Failed to record stats of container %s: %v", containerID, err)
 	}
 }
 
 func (hm *HistoryManager) GetAverages(containerID string, duration time.Duration) (cpuAvg, memAvg float64, hasData bool) {
-	hm.mu.RLock()
-	defer hm.mu.RUnlock()
+	now := time.Now()
+	summary := hm.store.Summarize(containerID, now.Add(-duration).Unix(), now.Unix())
 
-	history, exists := hm.containers[containerID]
-	if !exists {
+	cpu, hasCPU := summary[MetricCPUPercent]
+	mem, hasMem := summary[MetricMemoryPercent]
+	if !hasCPU || !hasMem {
 		return 0, 0, false
 	}
 
-	history.mu.RLock()
-	defer history.mu.RUnlock()
+	return cpu.Avg, mem.Avg, true
+}
 
-	if len(history.dataPoints) == 0 {
-		return 0, 0, false
+// GetSeries returns metrics of a container between from and to in buckets of
+// step, read from the resolution matching the range, and the step used.
+// Rates are computed from the last counter value of consecutive buckets.
+func (hm *HistoryManager) GetSeries(containerID string, from, to time.Time, step time.Duration, metrics []string) (map[string][]models.StatsSeriesPoint, time.Duration) {
+	// Reach back one bucket so the first rate has a previous value
+	lookback := step
+	if lookback <= 0 {
+		lookback = to.Sub(from) / 300
 	}
-
-	now := time.Now()
-	cutoff := now.Add(-duration)
-
-	var cpuSum, memSum float64
-	var count int
-
-	for i := len(history.dataPoints) - 1; i >= 0; i-- {
-		dp := history.dataPoints[i]
-		if dp.Timestamp.Before(cutoff) {
-			break
+	buckets, step := hm.store.Query(containerID, from.Add(-lookback).Unix(), to.Unix(), step)
+
+	series := make(map[string][]models.StatsSeriesPoint, len(metrics))
+	for _, metric := range metrics {
+		points := []models.StatsSeriesPoint{}
+
+		counter, isRate := strings.CutSuffix(metric, rateSuffix)
+		var prev *tsdb.Bucket
+		for i := range buckets {
+			bucket := &buckets[i]
+
+			if isRate {
+				agg, ok := bucket.Values[counter]
+				if !ok {
+					continue
+				}
+				previous := prev
+				prev = bucket
+				if previous == nil || bucket.Timestamp < from.Unix() {
+					continue
+				}
+				elapsed := float64(bucket.Timestamp - previous.Timestamp)
+				delta := agg.Last - previous.Values[counter].Last
+				if delta < 0 {
+					// The counter was reset by a restart
+					continue
+				}
+				rate := delta / elapsed
+				points = append(points, models.StatsSeriesPoint{Timestamp: bucket.Timestamp, Min: rate, Max: rate, Avg: rate, Last: rate})
+				continue
+			}
+
+			agg, ok := bucket.Values[metric]
+			if !ok || bucket.Timestamp < from.Unix() {
+				continue
+			}
+			points = append(points, models.StatsSeriesPoint{Timestamp: bucket.Timestamp, Min: agg.Min, Max: agg.Max, Avg: agg.Avg, Last: agg.Last})
 		}
-		cpuSum += dp.CPUPercent
-		memSum += dp.MemoryPercent
-		count++
-	}
 
-	if count == 0 {
-		return 0, 0, false
+		series[metric] = points
 	}
 
-	return cpuSum / float64(count), memSum / float64(count), true
+	return series, step
 }
 
 func (hm *HistoryManager) Get1hAverages(containerID string) (cpuAvg, memAvg float64, hasData bool) {
@@ -107,8 +148,13 @@ func (hm *HistoryManager) Get12hAverages(containerID string) (cpuAvg, memAvg flo
 	return hm.GetAverages(containerID, 12*time.Hour)
 }
 
+// Containers returns the IDs of the containers with recorded stats
+func (hm *HistoryManager) Containers() []string {
+	return hm.store.Series()
+}
+
 func (hm *HistoryManager) CleanupContainer(containerID string) {
-	hm.mu.Lock()
-	defer hm.mu.Unlock()
-	delete(hm.containers, containerID)
+	if err := hm.store.Delete(containerID); err != nil {
+		log.Printf("Failed to delete stats of container %s: %v", containerID, err)
+	}
 }
@@ -0,0 +1,257 @@
+package stats
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hhftechnology/vps-monitor/internal/system"
+)
+
+// HostDataPoint is a host metrics sample kept in the history
+type HostDataPoint struct {
+	Timestamp            int64   `json:"timestamp"`
+	CPUPercent           float64 `json:"cpuPercent"`
+	MemoryPercent        float64 `json:"memoryPercent"`
+	SwapPercent          float64 `json:"swapPercent"`
+	DiskPercent          float64 `json:"diskPercent"` // Root filesystem
+	Load1                float64 `json:"load1"`
+	NetRxBytesPerSec     float64 `json:"netRxBytesPerSec"`
+	NetTxBytesPerSec     float64 `json:"netTxBytesPerSec"`
+	DiskReadBytesPerSec  float64 `json:"diskReadBytesPerSec"`
+	DiskWriteBytesPerSec float64 `json:"diskWriteBytesPerSec"`
+}
+
+// HostSampler collects host metrics in the background, keeps a history of
+// them and notifies subscribers of every new sample
+type HostSampler struct {
+	collector *system.MetricsCollector
+	interval  time.Duration
+	maxSize   int
+
+	mu          sync.RWMutex
+	latest      *system.HostMetrics
+	dataPoints  []HostDataPoint
+	subscribers map[chan *system.HostMetrics]struct{}
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewHostSampler creates a sampler collecting every interval and keeping
+// retention worth of history
+func NewHostSampler(interval, retention time.Duration) *HostSampler {
+	maxSize := int(retention / interval)
+	if maxSize < 1 {
+		maxSize = 1
+	}
+
+	return &HostSampler{
+		collector:   system.NewMetricsCollector(),
+		interval:    interval,
+		maxSize:     maxSize,
+		dataPoints:  make([]HostDataPoint, 0, maxSize),
+		subscribers: make(map[chan *system.HostMetrics]struct{}),
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start begins background sampling
+func (s *HostSampler) Start() {
+	s.wg.Add(1)
+	go s.sampleLoop()
+}
+
+// Stop stops background sampling
+func (s *HostSampler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// Interval returns the sampling interval
+func (s *HostSampler) Interval() time.Duration {
+	return s.interval
+}
+
+func (s *HostSampler) sampleLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sample()
+
+		select {
+		case <-ticker.C:
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (s *HostSampler) sample() {
+	// The first collection samples for a second, so never time out before that
+	ctx, cancel := context.WithTimeout(context.Background(), max(s.interval, 5*time.Second))
+	defer cancel()
+
+	metrics, err := s.collector.Collect(ctx)
+	if err != nil {
+		log.Printf("Host sampler: failed to collect metrics: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = metrics
+	s.dataPoints = append(s.dataPoints, dataPointFromMetrics(metrics))
+	if len(s.dataPoints) > s.maxSize {
+		s.dataPoints = s.dataPoints[1:]
+	}
+
+	for ch := range s.subscribers {
+		// Drop the sample for slow subscribers rather than block sampling
+		select {
+		case ch <- metrics:
+		default:
+		}
+	}
+}
+
+// Latest returns the most recent sample, or nil before the first one
+func (s *HostSampler) Latest() *system.HostMetrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest
+}
+
+// Subscribe returns a channel receiving every new sample and a function that
+// must be called to unsubscribe
+func (s *HostSampler) Subscribe() (<-chan *system.HostMetrics, func()) {
+	ch := make(chan *system.HostMetrics, 1)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+// Range returns the samples between from and to. When step is larger than the
+// sampling interval, samples are averaged into buckets of step.
+func (s *HostSampler) Range(from, to time.Time, step time.Duration) []HostDataPoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	points := []HostDataPoint{}
+	fromUnix, toUnix := from.Unix(), to.Unix()
+	for _, dp := range s.dataPoints {
+		if dp.Timestamp >= fromUnix && dp.Timestamp <= toUnix {
+			points = append(points, dp)
+		}
+	}
+
+	if step <= s.interval || len(points) == 0 {
+		return points
+	}
+	return downsample(points, int64(step.Seconds()))
+}
+
+// downsample averages points into buckets of stepSeconds, each stamped with
+// the bucket start
+func downsample(points []HostDataPoint, stepSeconds int64) []HostDataPoint {
+	result := []HostDataPoint{}
+
+	var sum HostDataPoint
+	count := 0
+	bucket := points[0].Timestamp - points[0].Timestamp%stepSeconds
+
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		n := float64(count)
+		result = append(result, HostDataPoint{
+			Timestamp:            bucket,
+			CPUPercent:           sum.CPUPercent / n,
+			MemoryPercent:        sum.MemoryPercent / n,
+			SwapPercent:          sum.SwapPercent / n,
+			DiskPercent:          sum.DiskPercent / n,
+			Load1:                sum.Load1 / n,
+			NetRxBytesPerSec:     sum.NetRxBytesPerSec / n,
+			NetTxBytesPerSec:     sum.NetTxBytesPerSec / n,
+			DiskReadBytesPerSec:  sum.DiskReadBytesPerSec / n,
+			DiskWriteBytesPerSec: sum.DiskWriteBytesPerSec / n,
+		})
+	}
+
+	for _, dp := range points {
+		if start := dp.Timestamp - dp.Timestamp%stepSeconds; start != bucket {
+			flush()
+			sum, count, bucket = HostDataPoint{}, 0, start
+		}
+		sum.CPUPercent += dp.CPUPercent
+		sum.MemoryPercent += dp.MemoryPercent
+		sum.SwapPercent += dp.SwapPercent
+		sum.DiskPercent += dp.DiskPercent
+		sum.Load1 += dp.Load1
+		sum.NetRxBytesPerSec += dp.NetRxBytesPerSec
+		sum.NetTxBytesPerSec += dp.NetTxBytesPerSec
+		sum.DiskReadBytesPerSec += dp.DiskReadBytesPerSec
+		sum.DiskWriteBytesPerSec += dp.DiskWriteBytesPerSec
+		count++
+	}
+	flush()
+
+	return result
+}
+
+// dataPointFromMetrics summarises a metrics snapshot. Network throughput only
+// counts physical-looking interfaces, as container veth pairs and bridges
+// would count the same traffic twice.
+func dataPointFromMetrics(metrics *system.HostMetrics) HostDataPoint {
+	dp := HostDataPoint{
+		Timestamp:     metrics.Timestamp,
+		CPUPercent:    metrics.CPU.Percent,
+		MemoryPercent: metrics.Memory.Percent,
+		SwapPercent:   metrics.Swap.Percent,
+		Load1:         metrics.Load.Load1,
+	}
+
+	for _, fs := range metrics.Filesystems {
+		if fs.Mountpoint == "/" {
+			dp.DiskPercent = fs.Percent
+		}
+	}
+
+	for _, nic := range metrics.Network {
+		if isVirtualInterface(nic.Name) {
+			continue
+		}
+		dp.NetRxBytesPerSec += nic.RxBytesPerSec
+		dp.NetTxBytesPerSec += nic.TxBytesPerSec
+	}
+
+	for _, device := range metrics.DiskIO {
+		dp.DiskReadBytesPerSec += device.ReadBytesPerSec
+		dp.DiskWriteBytesPerSec += device.WriteBytesPerSec
+	}
+
+	return dp
+}
+
+func isVirtualInterface(name string) bool {
+	for _, prefix := range []string{"veth", "docker", "br-", "virbr", "cni", "flannel", "tun", "tap"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,369 @@
+package system
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// HostMetrics is a detailed snapshot of the host. Rates and CPU percentages
+// cover the time since the previous snapshot.
+type HostMetrics struct {
+	Timestamp   int64               `json:"timestamp"`
+	Interval    float64             `json:"interval"` // Seconds covered by rates and CPU percentages
+	CPU         CPUMetrics          `json:"cpu"`
+	Load        LoadMetrics         `json:"load"`
+	Memory      MemoryMetrics       `json:"memory"`
+	Swap        SwapMetrics         `json:"swap"`
+	Filesystems []FilesystemMetrics `json:"filesystems"`
+	Network     []NetworkMetrics    `json:"network"`
+	DiskIO      []DiskIOMetrics     `json:"diskIO"`
+}
+
+type CPUMetrics struct {
+	Percent float64   `json:"percent"`
+	PerCore []float64 `json:"perCore"`
+	Cores   int       `json:"cores"`
+}
+
+type LoadMetrics struct {
+	Load1        float64 `json:"load1"`
+	Load5        float64 `json:"load5"`
+	Load15       float64 `json:"load15"`
+	Load1PerCore float64 `json:"load1PerCore"`
+}
+
+type MemoryMetrics struct {
+	Total     uint64  `json:"total"`
+	Used      uint64  `json:"used"`
+	Available uint64  `json:"available"`
+	Cached    uint64  `json:"cached"`
+	Buffers   uint64  `json:"buffers"`
+	Percent   float64 `json:"percent"`
+}
+
+type SwapMetrics struct {
+	Total   uint64  `json:"total"`
+	Used    uint64  `json:"used"`
+	Free    uint64  `json:"free"`
+	Percent float64 `json:"percent"`
+}
+
+type FilesystemMetrics struct {
+	Device        string  `json:"device"`
+	Mountpoint    string  `json:"mountpoint"`
+	Fstype        string  `json:"fstype"`
+	Total         uint64  `json:"total"`
+	Used          uint64  `json:"used"`
+	Free          uint64  `json:"free"`
+	Percent       float64 `json:"percent"`
+	InodesTotal   uint64  `json:"inodesTotal"`
+	InodesUsed    uint64  `json:"inodesUsed"`
+	InodesFree    uint64  `json:"inodesFree"`
+	InodesPercent float64 `json:"inodesPercent"`
+}
+
+type NetworkMetrics struct {
+	Name          string  `json:"name"`
+	BytesSent     uint64  `json:"bytesSent"`
+	BytesRecv     uint64  `json:"bytesRecv"`
+	TxBytesPerSec float64 `json:"txBytesPerSec"`
+	RxBytesPerSec float64 `json:"rxBytesPerSec"`
+	PacketsSent   uint64  `json:"packetsSent"`
+	PacketsRecv   uint64  `json:"packetsRecv"`
+	Errors        uint64  `json:"errors"`
+	Drops         uint64  `json:"drops"`
+}
+
+type DiskIOMetrics struct {
+	Device           string  `json:"device"`
+	ReadBytes        uint64  `json:"readBytes"`
+	WriteBytes       uint64  `json:"writeBytes"`
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+	ReadOpsPerSec    float64 `json:"readOpsPerSec"`
+	WriteOpsPerSec   float64 `json:"writeOpsPerSec"`
+	BusyPercent      float64 `json:"busyPercent"`
+}
+
+// MetricsCollector computes host metrics. It keeps the previous counters so
+// rates cover the time between two calls; the first call samples for
+// metricsWarmup to get meaningful values.
+type MetricsCollector struct {
+	mu       sync.Mutex
+	prevAt   time.Time
+	prevCPU  []cpu.TimesStat
+	prevNet  map[string]net.IOCountersStat
+	prevDisk map[string]disk.IOCountersStat
+}
+
+// metricsWarmup is the sampling interval of the first collection
+const metricsWarmup = time.Second
+
+// defaultCollector backs GetMetrics
+var defaultCollector = NewMetricsCollector()
+
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{}
+}
+
+// GetMetrics returns detailed host metrics using the shared collector
+func GetMetrics(ctx context.Context) (*HostMetrics, error) {
+	return defaultCollector.Collect(ctx)
+}
+
+// Collect returns detailed host metrics
+func (c *MetricsCollector) Collect(ctx context.Context) (*HostMetrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.prevAt.IsZero() {
+		if err := c.sampleCounters(ctx); err != nil {
+			return nil, err
+		}
+		select {
+		case <-time.After(metricsWarmup):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	prevAt, prevCPU, prevNet, prevDisk := c.prevAt, c.prevCPU, c.prevNet, c.prevDisk
+	if err := c.sampleCounters(ctx); err != nil {
+		return nil, err
+	}
+	elapsed := c.prevAt.Sub(prevAt).Seconds()
+
+	metrics := &HostMetrics{
+		Timestamp:   c.prevAt.Unix(),
+		Interval:    elapsed,
+		Filesystems: []FilesystemMetrics{},
+		Network:     []NetworkMetrics{},
+		DiskIO:      []DiskIOMetrics{},
+	}
+
+	// CPU
+	metrics.CPU.Cores = len(c.prevCPU)
+	metrics.CPU.PerCore = make([]float64, 0, len(c.prevCPU))
+	var busyTotal, allTotal float64
+	for i, times := range c.prevCPU {
+		if i >= len(prevCPU) {
+			break
+		}
+		busy, all := cpuDelta(prevCPU[i], times)
+		busyTotal += busy
+		allTotal += all
+		metrics.CPU.PerCore = append(metrics.CPU.PerCore, percent(busy, all))
+	}
+	metrics.CPU.Percent = percent(busyTotal, allTotal)
+
+	// Load
+	if avg, err := load.AvgWithContext(ctx); err == nil {
+		metrics.Load = LoadMetrics{Load1: avg.Load1, Load5: avg.Load5, Load15: avg.Load15}
+		if metrics.CPU.Cores > 0 {
+			metrics.Load.Load1PerCore = avg.Load1 / float64(metrics.CPU.Cores)
+		}
+	}
+
+	// Memory and swap
+	if vMem, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		metrics.Memory = MemoryMetrics{
+			Total:     vMem.Total,
+			Used:      vMem.Used,
+			Available: vMem.Available,
+			Cached:    vMem.Cached,
+			Buffers:   vMem.Buffers,
+			Percent:   vMem.UsedPercent,
+		}
+	}
+	if swap, err := mem.SwapMemoryWithContext(ctx); err == nil {
+		metrics.Swap = SwapMetrics{
+			Total:   swap.Total,
+			Used:    swap.Used,
+			Free:    swap.Free,
+			Percent: swap.UsedPercent,
+		}
+	}
+
+	metrics.Filesystems = collectFilesystems(ctx)
+
+	// Network interfaces
+	for name, counters := range c.prevNet {
+		prev, ok := prevNet[name]
+		if !ok {
+			continue
+		}
+		metrics.Network = append(metrics.Network, NetworkMetrics{
+			Name:          name,
+			BytesSent:     counters.BytesSent,
+			BytesRecv:     counters.BytesRecv,
+			TxBytesPerSec: rate(prev.BytesSent, counters.BytesSent, elapsed),
+			RxBytesPerSec: rate(prev.BytesRecv, counters.BytesRecv, elapsed),
+			PacketsSent:   counters.PacketsSent,
+			PacketsRecv:   counters.PacketsRecv,
+			Errors:        counters.Errin + counters.Errout,
+			Drops:         counters.Dropin + counters.Dropout,
+		})
+	}
+	sort.Slice(metrics.Network, func(i, j int) bool {
+		return metrics.Network[i].Name < metrics.Network[j].Name
+	})
+
+	// Disk I/O
+	for name, counters := range c.prevDisk {
+		prev, ok := prevDisk[name]
+		if !ok {
+			continue
+		}
+		metrics.DiskIO = append(metrics.DiskIO, DiskIOMetrics{
+			Device:           name,
+			ReadBytes:        counters.ReadBytes,
+			WriteBytes:       counters.WriteBytes,
+			ReadBytesPerSec:  rate(prev.ReadBytes, counters.ReadBytes, elapsed),
+			WriteBytesPerSec: rate(prev.WriteBytes, counters.WriteBytes, elapsed),
+			ReadOpsPerSec:    rate(prev.ReadCount, counters.ReadCount, elapsed),
+			WriteOpsPerSec:   rate(prev.WriteCount, counters.WriteCount, elapsed),
+			// IoTime is in milliseconds
+			BusyPercent: min(100, rate(prev.IoTime, counters.IoTime, elapsed)/10),
+		})
+	}
+	sort.Slice(metrics.DiskIO, func(i, j int) bool {
+		return metrics.DiskIO[i].Device < metrics.DiskIO[j].Device
+	})
+
+	return metrics, nil
+}
+
+// sampleCounters records the current CPU, network and disk counters
+func (c *MetricsCollector) sampleCounters(ctx context.Context) error {
+	cpuTimes, err := cpu.TimesWithContext(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	netCounters := make(map[string]net.IOCountersStat)
+	if counters, err := networkCounters(ctx); err == nil {
+		for _, counter := range counters {
+			if counter.Name == "lo" {
+				continue
+			}
+			netCounters[counter.Name] = counter
+		}
+	}
+
+	diskCounters := make(map[string]disk.IOCountersStat)
+	if counters, err := disk.IOCountersWithContext(ctx); err == nil {
+		for name, counter := range counters {
+			if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+				continue
+			}
+			diskCounters[name] = counter
+		}
+	}
+
+	c.prevAt = time.Now()
+	c.prevCPU = cpuTimes
+	c.prevNet = netCounters
+	c.prevDisk = diskCounters
+	return nil
+}
+
+// networkCounters reads the interface counters of the host network namespace.
+// /proc/net follows the reading process, so the counters of PID 1 are used
+// when the host /proc is mounted.
+func networkCounters(ctx context.Context) ([]net.IOCountersStat, error) {
+	if hostProc := os.Getenv("HOST_PROC"); hostProc != "" {
+		if counters, err := net.IOCountersByFileWithContext(ctx, true, filepath.Join(hostProc, "1", "net", "dev")); err == nil {
+			return counters, nil
+		}
+	}
+	return net.IOCountersWithContext(ctx, true)
+}
+
+// collectFilesystems returns usage of every mounted physical filesystem. When
+// the host root is mounted at /host, mount points are read through it.
+func collectFilesystems(ctx context.Context) []FilesystemMetrics {
+	filesystems := []FilesystemMetrics{}
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return filesystems
+	}
+
+	hostRoot := ""
+	if _, err := os.Stat("/host"); err == nil {
+		hostRoot = "/host"
+	}
+
+	seen := make(map[string]struct{})
+	for _, partition := range partitions {
+		if _, dup := seen[partition.Device]; dup {
+			continue
+		}
+
+		usage, err := disk.UsageWithContext(ctx, filepath.Join(hostRoot, partition.Mountpoint))
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		seen[partition.Device] = struct{}{}
+
+		filesystems = append(filesystems, FilesystemMetrics{
+			Device:        partition.Device,
+			Mountpoint:    partition.Mountpoint,
+			Fstype:        partition.Fstype,
+			Total:         usage.Total,
+			Used:          usage.Used,
+			Free:          usage.Free,
+			Percent:       usage.UsedPercent,
+			InodesTotal:   usage.InodesTotal,
+			InodesUsed:    usage.InodesUsed,
+			InodesFree:    usage.InodesFree,
+			InodesPercent: usage.InodesUsedPercent,
+		})
+	}
+
+	sort.Slice(filesystems, func(i, j int) bool {
+		return filesystems[i].Mountpoint < filesystems[j].Mountpoint
+	})
+	return filesystems
+}
+
+// cpuDelta returns the busy and total CPU time between two samples
+func cpuDelta(prev, cur cpu.TimesStat) (busy, total float64) {
+	prevIdle := prev.Idle + prev.Iowait
+	curIdle := cur.Idle + cur.Iowait
+	prevTotal := prev.User + prev.Nice + prev.System + prevIdle + prev.Irq + prev.Softirq + prev.Steal
+	curTotal := cur.User + cur.Nice + cur.System + curIdle + cur.Irq + cur.Softirq + cur.Steal
+
+	total = curTotal - prevTotal
+	busy = total - (curIdle - prevIdle)
+	if total <= 0 || busy < 0 {
+		return 0, 0
+	}
+	return busy, total
+}
+
+func percent(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return 100 * part / total
+}
+
+// rate returns the per-second increase of a counter, treating resets as zero
+func rate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev || seconds <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
@@ -0,0 +1,223 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// Process is a host process with its resource usage
+type Process struct {
+	PID           int32   `json:"pid"`
+	User          string  `json:"user"`
+	Name          string  `json:"name"`
+	Cmdline       string  `json:"cmdline"`
+	CPUPercent    float64 `json:"cpuPercent"` // Over the sampling interval, 100 per core
+	MemoryRSS     uint64  `json:"memoryRss"`
+	MemoryPercent float64 `json:"memoryPercent"`
+	StartTime     int64   `json:"startTime"`
+	ContainerID   string  `json:"containerId,omitempty"`
+}
+
+// ProcessSort selects the ordering of GetTopProcesses
+type ProcessSort string
+
+const (
+	ProcessSortCPU    ProcessSort = "cpu"
+	ProcessSortMemory ProcessSort = "memory"
+)
+
+// processSampleInterval is how long CPU time is measured for
+const processSampleInterval = 500 * time.Millisecond
+
+// containerIDPattern matches a container ID in a cgroup path, such as
+// /docker/<id>, /system.slice/docker-<id>.scope or cri-containerd-<id>.scope
+var containerIDPattern = regexp.MustCompile(`(?:^|[/-])([0-9a-f]{64})(?:\.scope)?$`)
+
+// GetTopProcesses returns the limit processes using the most CPU or memory.
+// Processes are read through HOST_PROC, so with the host's /proc mounted they
+// are the host's processes.
+func GetTopProcesses(ctx context.Context, sortBy ProcessSort, limit int) ([]Process, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// CPU usage is the CPU time used between two reads, as the lifetime
+	// average hides what is busy right now
+	before := make(map[int32]float64, len(procs))
+	for _, p := range procs {
+		if times, err := p.TimesWithContext(ctx); err == nil {
+			before[p.Pid] = times.User + times.System
+		}
+	}
+
+	select {
+	case <-time.After(processSampleInterval):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var memTotal uint64
+	if vMem, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		memTotal = vMem.Total
+	}
+
+	processes := make([]Process, 0, len(procs))
+	for _, p := range procs {
+		prev, ok := before[p.Pid]
+		if !ok {
+			continue
+		}
+		times, err := p.TimesWithContext(ctx)
+		if err != nil {
+			// Exited during sampling
+			continue
+		}
+
+		entry := Process{
+			PID:        p.Pid,
+			CPUPercent: (times.User + times.System - prev) / processSampleInterval.Seconds() * 100,
+		}
+		if entry.CPUPercent < 0 {
+			entry.CPUPercent = 0
+		}
+		if memInfo, err := p.MemoryInfoWithContext(ctx); err == nil {
+			entry.MemoryRSS = memInfo.RSS
+			if memTotal > 0 {
+				entry.MemoryPercent = float64(memInfo.RSS) / float64(memTotal) * 100
+			}
+		}
+		processes = append(processes, entry)
+	}
+
+	sort.Slice(processes, func(i, j int) bool {
+		if sortBy == ProcessSortMemory {
+			return processes[i].MemoryRSS > processes[j].MemoryRSS
+		}
+		return processes[i].CPUPercent > processes[j].CPUPercent
+	})
+	if limit > 0 && len(processes) > limit {
+		processes = processes[:limit]
+	}
+
+	// Only look up the details of the processes returned
+	users := readPasswd()
+	for i := range processes {
+		fillProcessDetails(ctx, &processes[i], users)
+	}
+
+	return processes, nil
+}
+
+func fillProcessDetails(ctx context.Context, entry *Process, users map[uint32]string) {
+	p, err := process.NewProcessWithContext(ctx, entry.PID)
+	if err != nil {
+		return
+	}
+
+	entry.Name, _ = p.NameWithContext(ctx)
+	entry.Cmdline, _ = p.CmdlineWithContext(ctx)
+	if entry.Cmdline == "" {
+		// Kernel threads have no command line
+		entry.Cmdline = "[" + entry.Name + "]"
+	}
+
+	if created, err := p.CreateTimeWithContext(ctx); err == nil {
+		entry.StartTime = created / 1000
+	}
+
+	if uids, err := p.UidsWithContext(ctx); err == nil && len(uids) > 0 {
+		if name, ok := users[uids[0]]; ok {
+			entry.User = name
+		} else {
+			entry.User = strconv.FormatUint(uint64(uids[0]), 10)
+		}
+	}
+
+	entry.ContainerID = processContainerID(entry.PID)
+}
+
+// processContainerID returns the ID of the container owning a process, read
+// from its cgroup, or "" for host processes
+func processContainerID(pid int32) string {
+	file, err := os.Open(procPath(strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// hierarchy-ID:controllers:path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if match := containerIDPattern.FindStringSubmatch(parts[2]); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// readPasswd maps user IDs to names from the passwd file under etcPath
+func readPasswd() map[uint32]string {
+	users := make(map[uint32]string)
+
+	file, err := os.Open(etcPath("passwd"))
+	if err != nil {
+		return users
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// name:password:uid:gid:...
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+		uid, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		users[uint32(uid)] = fields[0]
+	}
+	return users
+}
+
+// etcPath joins elements under HOST_ETC, or HOST_ROOT/etc when only the host
+// root is set. Without either, /host/etc is used when the host root is
+// mounted there, else /etc.
+func etcPath(elem ...string) string {
+	root := os.Getenv("HOST_ETC")
+	if root == "" {
+		if hostRoot := os.Getenv("HOST_ROOT"); hostRoot != "" {
+			root = filepath.Join(hostRoot, "etc")
+		} else if _, err := os.Stat("/host/etc"); err == nil {
+			root = "/host/etc"
+		} else {
+			root = "/etc"
+		}
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
+
+// procPath joins elements under HOST_PROC, or /proc when unset
+func procPath(elem ...string) string {
+	root := os.Getenv("HOST_PROC")
+	if root == "" {
+		root = "/proc"
+	}
+	return filepath.Join(append([]string{root}, elem...)...)
+}
@@ -0,0 +1,118 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContainerIDPattern(t *testing.T) {
+	const id = "3f4e8a9b2c1d0e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"cgroupfs driver", "/docker/" + id, id},
+		{"nested cgroupfs", "/kubepods/besteffort/pod1234/" + id, id},
+		{"systemd driver", "/system.slice/docker-" + id + ".scope", id},
+		{"containerd", "/kubepods.slice/kubepods-burstable.slice/cri-containerd-" + id + ".scope", id},
+		{"bare id", id, id},
+		{"host process", "/user.slice/user-1000.slice/session-2.scope", ""},
+		{"init", "/init.scope", ""},
+		{"root", "/", ""},
+		{"short id", "/docker/3f4e8a9b2c1d", ""},
+		{"uppercase id", "/docker/3F4E8A9B2C1D0E7F6A5B4C3D2E1F0A9B8C7D6E5F4A3B2C1D0E9F8A7B6C5D4E3F", ""},
+		{"id followed by child cgroup", "/docker/" + id + "/init", ""},
+		{"id embedded in a name", "/system.slice/docker" + id + ".scope", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if match := containerIDPattern.FindStringSubmatch(tt.path); match != nil {
+				got = match[1]
+			}
+			if got != tt.want {
+				t.Errorf("containerIDPattern on %q = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessContainerID(t *testing.T) {
+	const id = "3f4e8a9b2c1d0e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+
+	tests := []struct {
+		name   string
+		cgroup string
+		want   string
+	}{
+		{
+			name:   "cgroup v1",
+			cgroup: "12:pids:/docker/" + id + "\n11:memory:/docker/" + id + "\n1:name=systemd:/docker/" + id + "\n",
+			want:   id,
+		},
+		{
+			name:   "cgroup v2 systemd driver",
+			cgroup: "0::/system.slice/docker-" + id + ".scope\n",
+			want:   id,
+		},
+		{
+			name:   "cgroup v2 cgroupfs driver",
+			cgroup: "0::/docker/" + id + "\n",
+			want:   id,
+		},
+		{
+			name:   "hybrid with host process",
+			cgroup: "1:name=systemd:/user.slice/user-1000.slice/session-2.scope\n0::/user.slice/user-1000.slice/session-2.scope\n",
+			want:   "",
+		},
+		{
+			name:   "malformed lines",
+			cgroup: "garbage\n\n",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(proc, "42"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(proc, "42", "cgroup"), []byte(tt.cgroup), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("HOST_PROC", proc)
+
+			if got := processContainerID(42); got != tt.want {
+				t.Errorf("processContainerID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEtcPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostEtc  string
+		hostRoot string
+		want     string
+	}{
+		{"host etc", "/mnt/etc", "", "/mnt/etc/passwd"},
+		{"host etc wins over host root", "/mnt/etc", "/mnt/root", "/mnt/etc/passwd"},
+		{"host root", "", "/mnt/root", filepath.Join("/mnt/root", "etc", "passwd")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOST_ETC", tt.hostEtc)
+			t.Setenv("HOST_ROOT", tt.hostRoot)
+			if got := etcPath("passwd"); got != tt.want {
+				t.Errorf("etcPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
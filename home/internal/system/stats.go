@@ -4,10 +4,13 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"sync/atomic"
+	"time"
 
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
 )
 
@@ -33,6 +36,9 @@ type Usage struct {
 	DiskPercent   float64 `json:"diskPercent"`
 	DiskTotal     uint64  `json:"diskTotal"`
 	DiskUsed      uint64  `json:"diskUsed"`
+	Load1         float64 `json:"load1"`
+	Load5         float64 `json:"load5"`
+	Load15        float64 `json:"load15"`
 }
 
 // Init configures gopsutil to use the host's /proc directory if mounted
@@ -44,6 +50,9 @@ func Init() {
 	}
 }
 
+// cpuPrimed records whether GetStats has taken a CPU sample yet
+var cpuPrimed atomic.Bool
+
 func GetStats(ctx context.Context) (*SystemStats, error) {
 	hInfo, err := host.InfoWithContext(ctx)
 	if err != nil {
@@ -56,12 +65,14 @@ func GetStats(ctx context.Context) (*SystemStats, error) {
 		return nil, err
 	}
 
-	// Get CPU Percent (over a small interval)
-	// Note: 0 interval returns immediate value since last call, which might be 0 on first call
-	// For a dashboard, we usually want the average over the last second, but that blocks.
-	// A better approach for an API is to return the value since last call or just immediate.
-	// Let's use a very short interval for responsiveness, or 0.
-	cpuPercents, err := cpu.PercentWithContext(ctx, 0, false)
+	// A 0 interval returns the usage since the previous call. The first call
+	// has nothing to compare against and would report the average since boot,
+	// so it samples for metricsWarmup instead.
+	interval := time.Duration(0)
+	if !cpuPrimed.Swap(true) {
+		interval = metricsWarmup
+	}
+	cpuPercents, err := cpu.PercentWithContext(ctx, interval, false)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +98,11 @@ func GetStats(ctx context.Context) (*SystemStats, error) {
 		diskUsed = diskUsage.Used
 	}
 
+	var loadAvg load.AvgStat
+	if avg, err := load.AvgWithContext(ctx); err == nil {
+		loadAvg = *avg
+	}
+
 	return &SystemStats{
 		HostInfo: HostInfo{
 			Hostname:        hInfo.Hostname,
@@ -104,6 +120,9 @@ func GetStats(ctx context.Context) (*SystemStats, error) {
 			DiskPercent:   diskPercent,
 			DiskTotal:     diskTotal,
 			DiskUsed:      diskUsed,
+			Load1:         loadAvg.Load1,
+			Load5:         loadAvg.Load5,
+			Load15:        loadAvg.Load15,
 		},
 	}, nil
 }
@@ -0,0 +1,568 @@
+package tsdb
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxQueryPoints is the number of buckets a query aims for when no step is
+// given
+const maxQueryPoints = 300
+
+// Rollup is a downsampled resolution kept for Retention
+type Rollup struct {
+	Step      time.Duration
+	Retention time.Duration
+}
+
+// DBOptions configures a DB
+type DBOptions struct {
+	Retention time.Duration // Retention of raw samples
+	Rollups   []Rollup
+}
+
+// Aggregate summarises the values of a field within a bucket
+type Aggregate struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Last  float64 `json:"last"`
+	Count int     `json:"count"`
+}
+
+func (a *Aggregate) add(value float64) {
+	a.merge(Aggregate{Min: value, Max: value, Avg: value, Last: value, Count: 1})
+}
+
+// merge folds in an aggregate of later samples
+func (a *Aggregate) merge(b Aggregate) {
+	if b.Count == 0 {
+		return
+	}
+	if a.Count == 0 {
+		*a = b
+		return
+	}
+	a.Min = min(a.Min, b.Min)
+	a.Max = max(a.Max, b.Max)
+	a.Avg = (a.Avg*float64(a.Count) + b.Avg*float64(b.Count)) / float64(a.Count+b.Count)
+	a.Last = b.Last
+	a.Count += b.Count
+}
+
+// Bucket holds the aggregates of every field from Timestamp until the next
+// bucket
+type Bucket struct {
+	Timestamp int64                `json:"timestamp"`
+	Values    map[string]Aggregate `json:"values"`
+}
+
+func newBucket(timestamp int64) *Bucket {
+	return &Bucket{Timestamp: timestamp, Values: make(map[string]Aggregate)}
+}
+
+func (b *Bucket) add(field string, value float64) {
+	agg := b.Values[field]
+	agg.add(value)
+	b.Values[field] = agg
+}
+
+func (b *Bucket) merge(field string, other Aggregate) {
+	agg := b.Values[field]
+	agg.merge(other)
+	b.Values[field] = agg
+}
+
+// aggregateFields are the statistics stored per field in rollups, as
+// "<field>:<stat>"
+var aggregateFields = []string{"min", "max", "avg", "last", "count"}
+
+// rollupLevel is a rollup resolution stored in its own Store
+type rollupLevel struct {
+	Rollup
+	store   *Store
+	pending map[string]*Bucket // Bucket being filled per series, stored once complete
+}
+
+// DB stores raw samples and rolls them up into coarser resolutions, each
+// with its own retention. Queries read from the resolution best suited to
+// the requested range.
+//
+// Each rollup keeps its most recent maxQueryPoints buckets in memory, with 5
+// columns per field, and reads older ones from its segments. Raw samples are
+// kept in memory for as long as the coarsest rollup step and at least
+// maxQueryPoints finest steps, so a bucket can be rebuilt after a restart
+// without reading segments. With 9 fields and the default rollups that is
+// about 0.8 MB per series.
+type DB struct {
+	raw          *Store
+	rawRetention time.Duration
+	levels       []*rollupLevel // Sorted by step
+
+	mu sync.Mutex // Guards pending buckets
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// OpenDB opens raw samples in dir and each rollup in a subdirectory of it.
+// Rollup buckets missing after a restart are rebuilt from raw samples. An
+// empty dir keeps everything in memory. Pending buckets are written once
+// their step has passed until Close.
+func OpenDB(dir string, opts DBOptions) (*DB, error) {
+	rollups := make([]Rollup, 0, len(opts.Rollups))
+	for _, rollup := range opts.Rollups {
+		if rollup.Step >= time.Second && rollup.Retention > 0 {
+			rollups = append(rollups, rollup)
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Step < rollups[j].Step })
+
+	// Without rollups every raw sample may be queried, so all stay resident
+	var resident time.Duration
+	if len(rollups) > 0 {
+		resident = max(maxQueryPoints*rollups[0].Step, rollups[len(rollups)-1].Step)
+	}
+	raw, err := Open(dir, Options{Retention: opts.Retention, Resident: resident})
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{raw: raw, rawRetention: opts.Retention, stopCh: make(chan struct{})}
+
+	for _, rollup := range rollups {
+
+		levelDir := ""
+		if dir != "" {
+			levelDir = filepath.Join(dir, "rollup-"+FormatStep(rollup.Step))
+		}
+
+		store, err := Open(levelDir, Options{Retention: rollup.Retention, Resident: maxQueryPoints * rollup.Step})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		level := &rollupLevel{Rollup: rollup, store: store, pending: make(map[string]*Bucket)}
+		db.levels = append(db.levels, level)
+		db.backfill(level)
+	}
+
+	db.wg.Add(1)
+	go db.flushLoop()
+
+	return db, nil
+}
+
+// Close writes the pending buckets, complete or not, and closes the raw and
+// rollup stores. A bucket still in progress is picked up again by the next
+// open.
+func (db *DB) Close() error {
+	close(db.stopCh)
+	db.wg.Wait()
+
+	db.mu.Lock()
+	err := db.flush(math.MaxInt64)
+	db.mu.Unlock()
+
+	if rawErr := db.raw.Close(); err == nil {
+		err = rawErr
+	}
+	for _, level := range db.levels {
+		if levelErr := level.store.Close(); err == nil {
+			err = levelErr
+		}
+	}
+	return err
+}
+
+// Rollups returns the configured rollups, sorted by step
+func (db *DB) Rollups() []Rollup {
+	rollups := make([]Rollup, len(db.levels))
+	for i, level := range db.levels {
+		rollups[i] = level.Rollup
+	}
+	return rollups
+}
+
+// Series returns the names of the series in raw storage or any rollup
+func (db *DB) Series() []string {
+	seen := make(map[string]struct{})
+	var names []string
+	add := func(series []string) {
+		for _, name := range series {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+
+	add(db.raw.Series())
+	for _, level := range db.levels {
+		add(level.store.Series())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Append records a raw sample and adds it to the pending bucket of every
+// rollup. Samples older than a rollup's pending bucket or its last written
+// bucket only reach raw storage.
+func (db *DB) Append(name string, timestamp int64, values map[string]float64) error {
+	if err := db.raw.Append(name, timestamp, values); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var firstErr error
+	for _, level := range db.levels {
+		start := bucketStart(timestamp, level.Step)
+
+		pending := level.pending[name]
+		if pending != nil && pending.Timestamp < start {
+			if err := level.write(name, pending); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			pending = nil
+		}
+		if pending != nil && pending.Timestamp > start {
+			continue
+		}
+		if pending == nil {
+			if last, ok := level.store.Last(name); ok && start <= last {
+				continue
+			}
+			pending = newBucket(start)
+			level.pending[name] = pending
+		}
+
+		for field, value := range values {
+			pending.add(field, value)
+		}
+	}
+	return firstErr
+}
+
+// flushLoop writes pending buckets once their step has passed, so the last
+// bucket of a series that stopped receiving samples is not held back
+func (db *DB) flushLoop() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			db.mu.Lock()
+			err := db.flush(time.Now().Unix())
+			db.mu.Unlock()
+			if err != nil {
+				log.Printf("tsdb: failed to write rollup buckets: %v", err)
+			}
+		case <-db.stopCh:
+			return
+		}
+	}
+}
+
+// flush writes the pending buckets that end at or before now. Callers must
+// hold mu.
+func (db *DB) flush(now int64) error {
+	var firstErr error
+	for _, level := range db.levels {
+		step := int64(level.Step.Seconds())
+		for name, pending := range level.pending {
+			if pending.Timestamp > now-step {
+				continue
+			}
+			if err := level.write(name, pending); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			delete(level.pending, name)
+		}
+	}
+	return firstErr
+}
+
+// Delete removes a series from raw storage and every rollup
+func (db *DB) Delete(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	err := db.raw.Delete(name)
+	for _, level := range db.levels {
+		delete(level.pending, name)
+		if levelErr := level.store.Delete(name); err == nil {
+			err = levelErr
+		}
+	}
+	return err
+}
+
+// Query returns the series between from and to (Unix seconds) in buckets of
+// step, along with the step used. Without a step, one is chosen so the
+// result has about maxQueryPoints buckets. Data is read from the coarsest
+// resolution that is at least as fine as step and still covers from.
+func (db *DB) Query(name string, from, to int64, step time.Duration) ([]Bucket, time.Duration) {
+	if step <= 0 {
+		step = time.Duration((to-from)/maxQueryPoints) * time.Second
+	}
+
+	level := db.pickLevel(from, step)
+	if level != nil && step < level.Step {
+		step = level.Step
+	}
+	step = max(step, time.Second)
+
+	buckets := make(map[int64]*Bucket)
+	db.collect(name, level, from, to, func(timestamp int64, field string, agg Aggregate) {
+		start := bucketStart(timestamp, step)
+		bucket, ok := buckets[start]
+		if !ok {
+			bucket = newBucket(start)
+			buckets[start] = bucket
+		}
+		bucket.merge(field, agg)
+	})
+
+	result := make([]Bucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, *bucket)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Timestamp < result[j].Timestamp })
+	return result, step
+}
+
+// Summarize aggregates each field of a series between from and to
+func (db *DB) Summarize(name string, from, to int64) map[string]Aggregate {
+	// A bucket straddling from skews the result by at most 1/60th
+	level := db.pickLevel(from, time.Duration((to-from)/60)*time.Second)
+
+	summary := newBucket(from)
+	db.collect(name, level, from, to, func(_ int64, field string, agg Aggregate) {
+		summary.merge(field, agg)
+	})
+	return summary.Values
+}
+
+// collect calls fn with the aggregates of a series between from and to,
+// in time order, read from level or from raw samples when level is nil
+func (db *DB) collect(name string, level *rollupLevel, from, to int64, fn func(timestamp int64, field string, agg Aggregate)) {
+	if level == nil {
+		fields := db.raw.Fields(name)
+		db.raw.Scan(name, from, to, fields, func(timestamp int64, values []float64) {
+			for i, field := range fields {
+				if !math.IsNaN(values[i]) {
+					fn(timestamp, field, Aggregate{Min: values[i], Max: values[i], Avg: values[i], Last: values[i], Count: 1})
+				}
+			}
+		})
+		return
+	}
+
+	// Include the bucket still being filled. It replaces a stored copy that
+	// Close wrote before it was complete.
+	db.mu.Lock()
+	pending := level.pending[name]
+	var values map[string]Aggregate
+	if pending != nil && pending.Timestamp <= to && pending.Timestamp+int64(level.Step.Seconds()) > from {
+		values = make(map[string]Aggregate, len(pending.Values))
+		for field, agg := range pending.Values {
+			values[field] = agg
+		}
+	}
+	db.mu.Unlock()
+
+	level.scan(name, bucketStart(from, level.Step), to, func(timestamp int64, field string, agg Aggregate) {
+		if values == nil || timestamp != pending.Timestamp {
+			fn(timestamp, field, agg)
+		}
+	})
+
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		fn(pending.Timestamp, field, values[field])
+	}
+}
+
+// pickLevel returns the coarsest rollup with a step of at most step whose
+// retention covers from, or nil for raw samples. When nothing fine enough
+// covers from, the finest rollup that does is used.
+func (db *DB) pickLevel(from int64, step time.Duration) *rollupLevel {
+	covers := func(retention time.Duration) bool {
+		return retention <= 0 || from >= time.Now().Add(-retention).Unix()
+	}
+
+	for i := len(db.levels) - 1; i >= 0; i-- {
+		if db.levels[i].Step <= step && covers(db.levels[i].Retention) {
+			return db.levels[i]
+		}
+	}
+	if covers(db.rawRetention) {
+		return nil
+	}
+	for _, level := range db.levels {
+		if covers(level.Retention) {
+			return level
+		}
+	}
+
+	// Nothing covers the whole range, use the longest history
+	var longest *rollupLevel
+	for _, level := range db.levels {
+		if longest == nil || level.Retention > longest.Retention {
+			longest = level
+		}
+	}
+	if longest != nil && longest.Retention > db.rawRetention {
+		return longest
+	}
+	return nil
+}
+
+// backfill rebuilds the buckets of a rollup from raw samples recorded after
+// its last stored bucket. The newest bucket is left pending, as is a stored
+// bucket that has not ended yet.
+func (db *DB) backfill(level *rollupLevel) {
+	now := time.Now().Unix()
+	for _, name := range db.raw.Series() {
+		from := int64(math.MinInt64)
+		if last, ok := level.store.Last(name); ok {
+			from = last + int64(level.Step.Seconds())
+			if from > now {
+				// Written incomplete by Close, keep filling it
+				level.pending[name] = level.read(name, last)
+				continue
+			}
+		}
+
+		fields := db.raw.Fields(name)
+		var pending *Bucket
+		db.raw.Scan(name, from, math.MaxInt64, fields, func(timestamp int64, values []float64) {
+			start := bucketStart(timestamp, level.Step)
+			if pending != nil && pending.Timestamp != start {
+				if err := level.write(name, pending); err != nil {
+					return
+				}
+				pending = nil
+			}
+			if pending == nil {
+				pending = newBucket(start)
+			}
+			for i, field := range fields {
+				if !math.IsNaN(values[i]) {
+					pending.add(field, values[i])
+				}
+			}
+		})
+
+		if pending != nil {
+			level.pending[name] = pending
+		}
+	}
+}
+
+// write stores a completed bucket
+func (level *rollupLevel) write(name string, bucket *Bucket) error {
+	values := make(map[string]float64, len(bucket.Values)*len(aggregateFields))
+	for field, agg := range bucket.Values {
+		values[field+":min"] = agg.Min
+		values[field+":max"] = agg.Max
+		values[field+":avg"] = agg.Avg
+		values[field+":last"] = agg.Last
+		values[field+":count"] = float64(agg.Count)
+	}
+	return level.store.Append(name, bucket.Timestamp, values)
+}
+
+// scan calls fn with the stored aggregates of a series between from and to
+func (level *rollupLevel) scan(name string, from, to int64, fn func(timestamp int64, field string, agg Aggregate)) {
+	fields := rollupFieldNames(level.store.Fields(name))
+	columns := make([]string, 0, len(fields)*len(aggregateFields))
+	for _, field := range fields {
+		for _, stat := range aggregateFields {
+			columns = append(columns, field+":"+stat)
+		}
+	}
+
+	level.store.Scan(name, from, to, columns, func(timestamp int64, values []float64) {
+		for i, field := range fields {
+			stats := values[i*len(aggregateFields) : (i+1)*len(aggregateFields)]
+			if math.IsNaN(stats[4]) || stats[4] == 0 {
+				continue
+			}
+			fn(timestamp, field, Aggregate{Min: stats[0], Max: stats[1], Avg: stats[2], Last: stats[3], Count: int(stats[4])})
+		}
+	})
+}
+
+// read returns the stored bucket of a series starting at timestamp
+func (level *rollupLevel) read(name string, timestamp int64) *Bucket {
+	bucket := newBucket(timestamp)
+	level.scan(name, timestamp, timestamp, func(_ int64, field string, agg Aggregate) {
+		bucket.merge(field, agg)
+	})
+	return bucket
+}
+
+// rollupFieldNames returns the fields of stored "<field>:<stat>" columns
+func rollupFieldNames(columns []string) []string {
+	seen := make(map[string]struct{})
+	fields := []string{}
+	for _, column := range columns {
+		field, _, ok := strings.Cut(column, ":")
+		if !ok {
+			continue
+		}
+		if _, dup := seen[field]; !dup {
+			seen[field] = struct{}{}
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// bucketStart aligns a timestamp to the start of its bucket
+func bucketStart(timestamp int64, step time.Duration) int64 {
+	seconds := int64(step.Seconds())
+	if seconds <= 1 {
+		return timestamp
+	}
+	start := timestamp - timestamp%seconds
+	if timestamp < 0 && timestamp%seconds != 0 {
+		start -= seconds
+	}
+	return start
+}
+
+// FormatStep formats a step compactly, such as 5m, 1h or 1d
+func FormatStep(step time.Duration) string {
+	switch {
+	case step%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", step/(24*time.Hour))
+	case step%time.Hour == 0:
+		return fmt.Sprintf("%dh", step/time.Hour)
+	case step%time.Minute == 0:
+		return fmt.Sprintf("%dm", step/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", step/time.Second)
+	}
+}
@@ -0,0 +1,177 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketStart(t *testing.T) {
+	tests := []struct {
+		timestamp int64
+		step      time.Duration
+		want      int64
+	}{
+		{125, time.Minute, 120},
+		{120, time.Minute, 120},
+		{119, time.Minute, 60},
+		{0, time.Minute, 0},
+		{-1, time.Minute, -60},
+		{-60, time.Minute, -60},
+		{-61, time.Minute, -120},
+		{1714521599, 24 * time.Hour, 1714435200},
+		{1714521600, 24 * time.Hour, 1714521600},
+		{125, time.Second, 125},
+		{125, 500 * time.Millisecond, 125},
+		{125, 90 * time.Second, 90},
+	}
+
+	for _, tt := range tests {
+		if got := bucketStart(tt.timestamp, tt.step); got != tt.want {
+			t.Errorf("bucketStart(%d, %s) = %d, want %d", tt.timestamp, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestPickLevel(t *testing.T) {
+	const day = 24 * time.Hour
+	db := &DB{
+		rawRetention: 2 * day,
+		levels: []*rollupLevel{
+			{Rollup: Rollup{Step: time.Minute, Retention: 7 * day}},
+			{Rollup: Rollup{Step: 5 * time.Minute, Retention: 30 * day}},
+			{Rollup: Rollup{Step: time.Hour, Retention: 90 * day}},
+		},
+	}
+	ago := func(d time.Duration) int64 { return time.Now().Add(-d).Unix() }
+
+	tests := []struct {
+		name     string
+		from     int64
+		step     time.Duration
+		wantStep time.Duration // 0 for raw samples
+	}{
+		{"step finer than every rollup reads raw", ago(time.Hour), 10 * time.Second, 0},
+		{"exact step", ago(time.Hour), time.Minute, time.Minute},
+		{"coarsest rollup not above step", ago(time.Hour), 2 * time.Hour, time.Hour},
+		{"step between rollups", ago(time.Hour), 10 * time.Minute, 5 * time.Minute},
+		{"raw past its retention falls back to the finest covering rollup", ago(3 * day), 10 * time.Second, time.Minute},
+		{"fine rollup past its retention", ago(10 * day), time.Minute, 5 * time.Minute},
+		{"only the coarsest rollup covers", ago(60 * day), time.Minute, time.Hour},
+		{"nothing covers uses the longest history", ago(200 * day), time.Minute, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := db.pickLevel(tt.from, tt.step)
+			var got time.Duration
+			if level != nil {
+				got = level.Step
+			}
+			if got != tt.wantStep {
+				t.Errorf("pickLevel() picked step %s, want %s", got, tt.wantStep)
+			}
+		})
+	}
+
+	// Without rollups everything is read from raw samples
+	if level := (&DB{rawRetention: day}).pickLevel(ago(10*day), time.Hour); level != nil {
+		t.Errorf("pickLevel() without rollups = %s, want raw", level.Step)
+	}
+}
+
+func TestDBFlush(t *testing.T) {
+	db, err := OpenDB("", DBOptions{Retention: time.Hour, Rollups: []Rollup{{Step: time.Minute, Retention: time.Hour}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := bucketStart(time.Now().Unix(), time.Minute) - 2*60
+	for _, offset := range []int64{0, 20, 40} {
+		if err := db.Append("web", start+offset, map[string]float64{"cpu": float64(offset)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	level := db.levels[0]
+	if _, ok := level.store.Last("web"); ok {
+		t.Fatal("bucket written before it was flushed")
+	}
+
+	db.mu.Lock()
+	err = db.flush(start + 59)
+	db.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := level.store.Last("web"); ok {
+		t.Fatal("bucket written before its step passed")
+	}
+
+	db.mu.Lock()
+	err = db.flush(start + 60)
+	db.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last, ok := level.store.Last("web"); !ok || last != start {
+		t.Fatalf("got last bucket %d, %t after its step passed, want %d", last, ok, start)
+	}
+	if _, ok := level.pending["web"]; ok {
+		t.Error("flushed bucket is still pending")
+	}
+
+	// A late sample of a written bucket only reaches raw samples
+	if err := db.Append("web", start+50, map[string]float64{"cpu": 100}); err != nil {
+		t.Fatal(err)
+	}
+	buckets, _ := db.Query("web", start, start+59, time.Minute)
+	if len(buckets) != 1 || buckets[0].Values["cpu"].Count != 3 || buckets[0].Values["cpu"].Max != 40 {
+		t.Errorf("got buckets %+v, want the flushed bucket unchanged", buckets)
+	}
+}
+
+// TestDBResumesBucketAfterClose closes a DB with a bucket in progress and
+// keeps filling it after reopening, without counting samples twice
+func TestDBResumesBucketAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	opts := DBOptions{Retention: time.Hour, Rollups: []Rollup{{Step: 24 * time.Hour, Retention: 48 * time.Hour}}}
+	// Keep every sample within today's bucket, even just after midnight
+	now := max(time.Now().Unix(), bucketStart(time.Now().Unix(), 24*time.Hour)+10)
+
+	db, err := OpenDB(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []float64{1, 2} {
+		if err := db.Append("web", now-10+int64(value), map[string]float64{"cpu": value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = OpenDB(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	level := db.levels[0]
+	if _, ok := level.store.Last("web"); !ok {
+		t.Fatal("bucket in progress was not written at close")
+	}
+	if err := db.Append("web", now, map[string]float64{"cpu": 6}); err != nil {
+		t.Fatal(err)
+	}
+
+	buckets, step := db.Query("web", bucketStart(now, 24*time.Hour), now, 24*time.Hour)
+	if step != 24*time.Hour || len(buckets) != 1 {
+		t.Fatalf("got %d buckets of %s, want one day", len(buckets), step)
+	}
+	agg := buckets[0].Values["cpu"]
+	if agg.Count != 3 || agg.Min != 1 || agg.Max != 6 || agg.Avg != 3 || agg.Last != 6 {
+		t.Errorf("got %+v, want the samples before and after the restart once each", agg)
+	}
+}
@@ -0,0 +1,396 @@
+package tsdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	segmentExt = ".seg"
+
+	// maxRecordSize guards against reading garbage lengths from damaged files
+	maxRecordSize = 1 << 20
+)
+
+type recordKind byte
+
+const (
+	recordSample recordKind = 1
+	recordDelete recordKind = 2
+
+	// recordCompacted starts a compacted segment. Its timestamp is the start
+	// of the last segment merged into it, so segments left behind by a crash
+	// during compaction are recognised and skipped.
+	recordCompacted recordKind = 3
+)
+
+// record is a single entry of a segment. On disk it is framed as
+// [length uint32][crc32 uint32][payload], with the payload holding the kind,
+// series, timestamp and values.
+type record struct {
+	kind      recordKind
+	series    string
+	timestamp int64
+	values    map[string]float64
+}
+
+// segment is an append-only file of records. Its name is the Unix time it
+// was started at, so segments sort by name.
+type segment struct {
+	path    string
+	start   int64
+	minTime int64
+	maxTime int64
+}
+
+func (seg *segment) observe(timestamp int64) {
+	seg.minTime = min(seg.minTime, timestamp)
+	seg.maxTime = max(seg.maxTime, timestamp)
+}
+
+func segmentName(start int64) string {
+	return fmt.Sprintf("%020d%s", start, segmentExt)
+}
+
+// listSegments returns the segments in dir sorted by start. Leftovers of an
+// interrupted compaction are removed.
+func listSegments(dir string) ([]*segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	segments := []*segment{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, &segment{
+			path:    filepath.Join(dir, name),
+			start:   start,
+			minTime: math.MaxInt64,
+			maxTime: math.MinInt64,
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start < segments[j].start })
+	return segments, nil
+}
+
+// readSegment calls fn for every record of a segment and records its time
+// range. It stops at the first damaged record.
+func readSegment(seg *segment, fn func(record)) error {
+	return readRecords(seg.path, func(rec record) {
+		if rec.kind != recordCompacted {
+			seg.observe(rec.timestamp)
+		}
+		fn(rec)
+	})
+}
+
+// readRecords calls fn for every record of the segment file at path. It
+// stops at the first damaged record.
+func readRecords(path string, fn func(record)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		rec, err := readRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(rec)
+	}
+}
+
+func writeRecord(w io.Writer, rec record) error {
+	payload := make([]byte, 0, 64)
+	payload = append(payload, byte(rec.kind))
+	payload = binary.AppendUvarint(payload, uint64(len(rec.series)))
+	payload = append(payload, rec.series...)
+	payload = binary.AppendVarint(payload, rec.timestamp)
+	payload = binary.AppendUvarint(payload, uint64(len(rec.values)))
+	for field, value := range rec.values {
+		payload = binary.AppendUvarint(payload, uint64(len(field)))
+		payload = append(payload, field...)
+		payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(value))
+	}
+
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+var errCorruptRecord = errors.New("corrupt record")
+
+func readRecord(r *bufio.Reader) (record, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return record{}, errCorruptRecord
+		}
+		return record{}, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size == 0 || size > maxRecordSize {
+		return record{}, errCorruptRecord
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return record{}, errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return record{}, errCorruptRecord
+	}
+
+	return decodeRecord(payload)
+}
+
+func decodeRecord(payload []byte) (record, error) {
+	rec := record{kind: recordKind(payload[0])}
+	buf := payload[1:]
+
+	readString := func() (string, bool) {
+		n, size := binary.Uvarint(buf)
+		if size <= 0 || uint64(len(buf)-size) < n {
+			return "", false
+		}
+		s := string(buf[size : size+int(n)])
+		buf = buf[size+int(n):]
+		return s, true
+	}
+
+	var ok bool
+	if rec.series, ok = readString(); !ok {
+		return record{}, errCorruptRecord
+	}
+
+	timestamp, size := binary.Varint(buf)
+	if size <= 0 {
+		return record{}, errCorruptRecord
+	}
+	rec.timestamp = timestamp
+	buf = buf[size:]
+
+	count, size := binary.Uvarint(buf)
+	if size <= 0 || count > uint64(len(buf)) {
+		return record{}, errCorruptRecord
+	}
+	buf = buf[size:]
+
+	if count > 0 {
+		rec.values = make(map[string]float64, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		field, ok := readString()
+		if !ok || len(buf) < 8 {
+			return record{}, errCorruptRecord
+		}
+		rec.values[field] = math.Float64frombits(binary.LittleEndian.Uint64(buf))
+		buf = buf[8:]
+	}
+
+	return rec, nil
+}
+
+// Compact merges the closed segments of each past day into a single segment,
+// dropping samples past the retention and samples of deleted series. A day is
+// merged once it has ended and its last segment is closed, so each day is
+// rewritten once.
+func (s *Store) Compact() error {
+	today := time.Now().UTC().Format(time.DateOnly)
+
+	s.mu.RLock()
+	groups := make(map[string][]*segment)
+	var days []string
+	activeDay := ""
+	for _, seg := range s.segments {
+		day := time.Unix(seg.start, 0).UTC().Format(time.DateOnly)
+		if s.active != nil && seg == s.active.segment {
+			activeDay = day
+			continue
+		}
+		if _, ok := groups[day]; !ok {
+			days = append(days, day)
+		}
+		groups[day] = append(groups[day], seg)
+	}
+	deleted := make(map[string]int64, len(s.deleted))
+	for name, deletedAt := range s.deleted {
+		deleted[name] = deletedAt
+	}
+	s.mu.RUnlock()
+
+	for _, day := range days {
+		group := groups[day]
+		if len(group) < 2 || day >= today || day == activeDay {
+			continue
+		}
+
+		merged, err := s.mergeSegments(group, deleted)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		kept := s.segments[:0]
+		for _, seg := range s.segments {
+			if seg == group[0] {
+				kept = append(kept, merged)
+				continue
+			}
+			if containsSegment(group, seg) {
+				continue
+			}
+			kept = append(kept, seg)
+		}
+		s.segments = kept
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// mergeSegments rewrites a group of segments into one file that replaces the
+// first segment, then removes the others. Records are streamed in two passes
+// rather than held in memory: the first finds the last deletion of each
+// series, the second writes what remains.
+func (s *Store) mergeSegments(group []*segment, deleted map[string]int64) (*segment, error) {
+	// Samples of a series recorded before its last deletion in the group are
+	// gone. Records are numbered in read order.
+	lastDelete := make(map[string]int)
+	position := 0
+	for _, seg := range group {
+		err := readSegment(seg, func(rec record) {
+			if rec.kind == recordDelete {
+				lastDelete[rec.series] = position
+			}
+			position++
+		})
+		if err != nil {
+			log.Printf("tsdb: segment %s is damaged, compacting what could be read: %v", seg.path, err)
+		}
+	}
+
+	cutoff := s.cutoff()
+	merged := &segment{path: group[0].path, start: group[0].start, minTime: math.MaxInt64, maxTime: math.MinInt64}
+
+	tmpPath := merged.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compacted segment: %w", err)
+	}
+
+	buf := bufio.NewWriter(file)
+	err = writeRecord(buf, record{kind: recordCompacted, timestamp: group[len(group)-1].start})
+	position = 0
+	for _, seg := range group {
+		if err != nil {
+			break
+		}
+		// Damage was already logged by the first pass
+		_ = readSegment(seg, func(rec record) {
+			defer func() { position++ }()
+			if err != nil {
+				return
+			}
+			switch rec.kind {
+			case recordCompacted:
+				// Replaced by the marker of this merge
+				return
+			case recordSample:
+				if rec.timestamp < cutoff {
+					return
+				}
+				if last, ok := lastDelete[rec.series]; ok && position < last {
+					return
+				}
+				if deletedAt, ok := deleted[rec.series]; ok && rec.timestamp <= deletedAt {
+					return
+				}
+			}
+			if err = writeRecord(buf, rec); err == nil {
+				merged.observe(rec.timestamp)
+			}
+		})
+	}
+
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to write compacted segment: %w", err)
+	}
+
+	// From here on the marker makes the merge safe to interrupt: segments it
+	// covers are skipped and removed when the store is opened
+	s.filesMu.Lock()
+	if err := os.Rename(tmpPath, merged.path); err != nil {
+		s.filesMu.Unlock()
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to replace segment: %w", err)
+	}
+	for _, seg := range group[1:] {
+		if err := os.Remove(seg.path); err != nil {
+			log.Printf("tsdb: failed to remove compacted segment %s: %v", seg.path, err)
+		}
+	}
+	s.filesMu.Unlock()
+
+	if merged.minTime > merged.maxTime {
+		// Everything was dropped, keep the empty segment until it expires
+		merged.minTime, merged.maxTime = group[len(group)-1].maxTime, group[len(group)-1].maxTime
+	}
+	return merged, nil
+}
+
+func containsSegment(group []*segment, seg *segment) bool {
+	for _, g := range group {
+		if g == seg {
+			return true
+		}
+	}
+	return false
+}
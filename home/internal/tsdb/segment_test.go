@@ -0,0 +1,424 @@
+package tsdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	records := []record{
+		{kind: recordSample, series: "web", timestamp: 1700000000, values: map[string]float64{"cpu": 12.5, "mem": 1 << 30}},
+		{kind: recordSample, series: "db", timestamp: -1, values: map[string]float64{"cpu": math.Inf(1), "zero": 0}},
+		{kind: recordSample, series: "", timestamp: 0, values: map[string]float64{"": -0.25}},
+		{kind: recordDelete, series: "web", timestamp: 1700000060},
+	}
+
+	var buf bytes.Buffer
+	for _, rec := range records {
+		if err := writeRecord(&buf, rec); err != nil {
+			t.Fatalf("writeRecord() error = %v", err)
+		}
+	}
+
+	reader := bufio.NewReader(&buf)
+	for i, want := range records {
+		got, err := readRecord(reader)
+		if err != nil {
+			t.Fatalf("record %d: readRecord() error = %v", i, err)
+		}
+		if got.kind != want.kind || got.series != want.series || got.timestamp != want.timestamp || !maps.Equal(got.values, want.values) {
+			t.Errorf("record %d: got %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := readRecord(reader); !errors.Is(err, io.EOF) {
+		t.Errorf("readRecord() past the end error = %v, want EOF", err)
+	}
+}
+
+func TestReadRecordCorruption(t *testing.T) {
+	var valid bytes.Buffer
+	rec := record{kind: recordSample, series: "web", timestamp: 1700000000, values: map[string]float64{"cpu": 1}}
+	if err := writeRecord(&valid, rec); err != nil {
+		t.Fatal(err)
+	}
+	encoded := valid.Bytes()
+
+	tests := []struct {
+		name  string
+		input func() []byte
+	}{
+		{"flipped payload byte", func() []byte {
+			b := bytes.Clone(encoded)
+			b[len(b)-1] ^= 0xff
+			return b
+		}},
+		{"flipped checksum", func() []byte {
+			b := bytes.Clone(encoded)
+			b[4] ^= 0xff
+			return b
+		}},
+		{"truncated header", func() []byte { return encoded[:5] }},
+		{"truncated payload", func() []byte { return encoded[:len(encoded)-3] }},
+		{"zero length", func() []byte {
+			b := bytes.Clone(encoded)
+			binary.LittleEndian.PutUint32(b[0:4], 0)
+			return b
+		}},
+		{"length over the limit", func() []byte {
+			b := bytes.Clone(encoded)
+			binary.LittleEndian.PutUint32(b[0:4], maxRecordSize+1)
+			return b
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readRecord(bufio.NewReader(bytes.NewReader(tt.input())))
+			if !errors.Is(err, errCorruptRecord) {
+				t.Errorf("readRecord() error = %v, want %v", err, errCorruptRecord)
+			}
+		})
+	}
+}
+
+func TestDecodeRecordRejectsShortPayloads(t *testing.T) {
+	var buf bytes.Buffer
+	rec := record{kind: recordSample, series: "web", timestamp: 1700000000, values: map[string]float64{"cpu": 1}}
+	if err := writeRecord(&buf, rec); err != nil {
+		t.Fatal(err)
+	}
+	payload := buf.Bytes()[8:]
+
+	// Every strict prefix of a valid payload is missing data
+	for n := 1; n < len(payload); n++ {
+		if _, err := decodeRecord(payload[:n]); !errors.Is(err, errCorruptRecord) {
+			t.Errorf("decodeRecord(%d of %d bytes) error = %v, want %v", n, len(payload), err, errCorruptRecord)
+		}
+	}
+}
+
+// TestStoreRecoversTruncatedTail reopens a store whose last record was cut
+// short by a crash, keeping everything before it
+func TestStoreRecoversTruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Unix()
+
+	store, err := Open(dir, Options{Retention: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range int64(3) {
+		if err := store.Append("web", now-30+i*10, map[string]float64{"cpu": float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("listSegments() = %d segments, %v, want 1", len(segments), err)
+	}
+	info, err := os.Stat(segments[0].path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(segments[0].path, info.Size()-4); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = Open(dir, Options{Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("Open() with a truncated segment error = %v", err)
+	}
+	defer store.Close()
+
+	samples := store.Query("web", now-60, now)
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(samples))
+	}
+	for i, sample := range samples {
+		if sample.Values["cpu"] != float64(i) {
+			t.Errorf("sample %d: got cpu %v, want %d", i, sample.Values["cpu"], i)
+		}
+	}
+
+	// Appending after recovery starts a new segment and keeps working
+	if err := store.Append("web", now, map[string]float64{"cpu": 9}); err != nil {
+		t.Fatalf("Append() after recovery error = %v", err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).Unix()
+	nextDay := day + 24*60*60
+
+	writeSegment := func(start int64, records ...record) {
+		t.Helper()
+		writer, err := createSegment(&segment{path: filepath.Join(dir, segmentName(start)), start: start})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rec := range records {
+			if err := writer.write(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sample := func(series string, timestamp int64, value float64) record {
+		return record{kind: recordSample, series: series, timestamp: timestamp, values: map[string]float64{"cpu": value}}
+	}
+
+	writeSegment(day,
+		sample("web", day+10, 1),
+		sample("db", day+10, 2),
+	)
+	writeSegment(day+3600,
+		sample("web", day+3610, 3),
+		record{kind: recordDelete, series: "db", timestamp: day + 3620},
+		sample("db", day+3630, 4),
+	)
+	writeSegment(day+7200,
+		sample("web", day+7210, 5),
+	)
+	writeSegment(nextDay,
+		sample("web", nextDay+10, 6),
+	)
+
+	// Without retention nothing expires, so only merging is tested
+	store, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := store.Query("web", day, nextDay+60)
+	if err := store.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 || segments[0].start != day || segments[1].start != nextDay {
+		t.Fatalf("got %d segments after compaction, want the first of each day", len(segments))
+	}
+
+	var got []record
+	if err := readSegment(segments[0], func(rec record) { got = append(got, rec) }); err != nil {
+		t.Fatalf("readSegment() of compacted segment error = %v", err)
+	}
+	if len(got) == 0 || got[0].kind != recordCompacted || got[0].timestamp != day+7200 {
+		t.Fatalf("compacted segment does not start with a marker through %d: %+v", day+7200, got)
+	}
+	got = got[1:]
+	want := []record{
+		sample("web", day+10, 1),
+		sample("web", day+3610, 3),
+		{kind: recordDelete, series: "db", timestamp: day + 3620},
+		sample("db", day+3630, 4),
+		sample("web", day+7210, 5),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].kind != want[i].kind || got[i].series != want[i].series || got[i].timestamp != want[i].timestamp || !maps.Equal(got[i].values, want[i].values) {
+			t.Errorf("record %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if segments[0].minTime != day+10 || segments[0].maxTime != day+7210 {
+		t.Errorf("compacted segment covers %d-%d, want %d-%d", segments[0].minTime, segments[0].maxTime, day+10, day+7210)
+	}
+
+	// A second compaction has nothing left to merge
+	if err := store.Compact(); err != nil {
+		t.Fatalf("second Compact() error = %v", err)
+	}
+
+	// Reopening the compacted files yields the same samples
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	after := reopened.Query("web", day, nextDay+60)
+	if len(after) != len(before) {
+		t.Fatalf("got %d samples after compaction, want %d", len(after), len(before))
+	}
+	for i := range before {
+		if after[i].Timestamp != before[i].Timestamp || !maps.Equal(after[i].Values, before[i].Values) {
+			t.Errorf("sample %d: got %+v, want %+v", i, after[i], before[i])
+		}
+	}
+	if db := reopened.Query("db", day, nextDay+60); len(db) != 1 || db[0].Values["cpu"] != 4 {
+		t.Errorf("got db samples %+v, want only the one after the deletion", db)
+	}
+}
+
+// TestStoreSkipsSegmentsOfInterruptedCompaction reopens a store after a crash
+// between replacing the first segment of a day and removing the others
+func TestStoreSkipsSegmentsOfInterruptedCompaction(t *testing.T) {
+	dir := t.TempDir()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	writeSegment := func(start int64, records ...record) {
+		t.Helper()
+		writer, err := createSegment(&segment{path: filepath.Join(dir, segmentName(start)), start: start})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rec := range records {
+			if err := writer.write(rec); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sample := func(timestamp int64, value float64) record {
+		return record{kind: recordSample, series: "web", timestamp: timestamp, values: map[string]float64{"cpu": value}}
+	}
+
+	// The merged segment and the segment it absorbed, which was not removed
+	writeSegment(day,
+		record{kind: recordCompacted, timestamp: day + 3600},
+		sample(day+10, 1),
+		sample(day+3610, 2),
+	)
+	writeSegment(day+3600, sample(day+3610, 2))
+	// A later segment of the same day that was not part of the merge
+	writeSegment(day+7200, sample(day+7210, 3))
+
+	store, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	samples := store.Query("web", day, day+86400)
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3 without duplicates", len(samples))
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentName(day+3600))); !os.IsNotExist(err) {
+		t.Errorf("merged leftover segment was not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentName(day+7200))); err != nil {
+		t.Errorf("segment after the merge was removed: %v", err)
+	}
+}
+
+func TestCompactSkipsCurrentDay(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Unix()
+	start := max(now-7200, time.Unix(now, 0).UTC().Truncate(24*time.Hour).Unix())
+
+	for i, segStart := range []int64{start, start + 1} {
+		writer, err := createSegment(&segment{path: filepath.Join(dir, segmentName(segStart)), start: segStart})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.write(record{kind: recordSample, series: "web", timestamp: segStart, values: map[string]float64{"cpu": float64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Errorf("got %d segments, want today's segments left alone", len(segments))
+	}
+}
+
+func TestStoreReadsEvictedSamplesFromSegments(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Unix()
+	opts := Options{Retention: 3 * time.Hour, Resident: time.Hour}
+
+	store, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, timestamp := range []int64{now - 7200, now - 5400, now - 60} {
+		if err := store.Append("web", timestamp, map[string]float64{"cpu": float64(now - timestamp)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(store *Store) {
+		t.Helper()
+		if got := len(store.series["web"].samples); got != 1 {
+			t.Errorf("got %d resident samples, want only the one within the resident window", got)
+		}
+		if last, ok := store.Last("web"); !ok || last != now-60 {
+			t.Errorf("Last() = %d, %v, want %d, true", last, ok, now-60)
+		}
+
+		samples := store.Query("web", now-6000, now)
+		want := []int64{now - 5400, now - 60}
+		if len(samples) != len(want) {
+			t.Fatalf("got %d samples, want %d", len(samples), len(want))
+		}
+		for i, sample := range samples {
+			if sample.Timestamp != want[i] || sample.Values["cpu"] != float64(now-want[i]) {
+				t.Errorf("sample %d = %+v, want timestamp %d", i, sample, want[i])
+			}
+		}
+	}
+
+	check(store)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	check(store)
+
+	// Samples read from segments honour deletions
+	if err := store.Delete("web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Append("web", now, map[string]float64{"cpu": 0}); err != nil {
+		t.Fatal(err)
+	}
+	if samples := store.Query("web", now-7200, now); len(samples) != 1 {
+		t.Errorf("got %d samples after deleting the series, want 1", len(samples))
+	}
+}
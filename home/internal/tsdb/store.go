@@ -0,0 +1,584 @@
+package tsdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// segmentDuration is how long a segment is appended to before a new one is
+// started. The segments of a day are compacted into one once it has ended.
+const segmentDuration = time.Hour
+
+// Options configures a Store
+type Options struct {
+	Retention time.Duration // Samples older than this are dropped
+
+	// Resident is how far back samples are kept in memory. Older samples are
+	// read from segment files when queried. 0 keeps every sample in memory,
+	// as does a Store without a directory.
+	Resident time.Duration
+}
+
+// Sample is a set of named values recorded at a point in time
+type Sample struct {
+	Timestamp int64              `json:"timestamp"`
+	Values    map[string]float64 `json:"values"`
+}
+
+// Store is an embedded time-series store. Samples are appended to segment
+// files under its directory, so only a directory is needed and history
+// survives restarts. Samples within the resident window are also kept in
+// memory, about 40 bytes plus 8 bytes per field each, so a series of 9 fields
+// sampled every 30 seconds takes about 0.3 MB per day kept. Older samples are
+// read from the segments that cover the queried range. A Store with an empty
+// directory keeps samples in memory only.
+type Store struct {
+	dir       string
+	retention time.Duration
+	resident  time.Duration
+
+	mu       sync.RWMutex
+	series   map[string]*series
+	deleted  map[string]int64 // Deletion time of deleted series, applied by compaction
+	segments []*segment       // Sorted by start, the last one is active
+	active   *segmentWriter
+	evicted  int64 // Samples before this are only kept in segments
+
+	// filesMu is held for reading while segments are read for a query and
+	// for writing while compaction replaces them
+	filesMu sync.RWMutex
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// series holds the resident samples of one series. Fields are stored by
+// index so samples only keep their values. Fields and the newest timestamp
+// also cover samples that are no longer resident.
+type series struct {
+	fields  []string
+	index   map[string]int
+	samples []storedSample // Sorted by timestamp
+	last    int64
+}
+
+func newSeries() *series {
+	return &series{index: make(map[string]int), last: math.MinInt64}
+}
+
+type storedSample struct {
+	timestamp int64
+	values    []float64 // NaN for fields the sample did not record
+}
+
+// Open opens the store in dir, creating it if needed, and loads the samples
+// within the retention. Background maintenance runs until Close.
+func Open(dir string, opts Options) (*Store, error) {
+	s := &Store{
+		dir:       dir,
+		retention: opts.Retention,
+		series:    make(map[string]*series),
+		deleted:   make(map[string]int64),
+		evicted:   math.MinInt64,
+		stopCh:    make(chan struct{}),
+	}
+	if dir != "" {
+		s.resident = opts.Resident
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
+		}
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.wg.Add(1)
+	go s.maintenanceLoop()
+
+	return s, nil
+}
+
+// Close stops background maintenance and flushes the active segment
+func (s *Store) Close() error {
+	close(s.stopCh)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active != nil {
+		err := s.active.close()
+		s.active = nil
+		return err
+	}
+	return nil
+}
+
+// Append records values for a series at timestamp (Unix seconds)
+func (s *Store) Append(name string, timestamp int64, values map[string]float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insert(name, timestamp, values)
+
+	if s.dir == "" {
+		return nil
+	}
+	if err := s.ensureActive(timestamp); err != nil {
+		return err
+	}
+	return s.active.write(record{kind: recordSample, series: name, timestamp: timestamp, values: values})
+}
+
+// Delete removes all samples of a series
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.series, name)
+
+	if s.dir == "" {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	s.deleted[name] = now
+	if err := s.ensureActive(now); err != nil {
+		return err
+	}
+	return s.active.write(record{kind: recordDelete, series: name, timestamp: now})
+}
+
+// Scan calls fn for each sample of a series between from and to (inclusive,
+// Unix seconds), in time order. values holds the requested fields, NaN where
+// a sample did not record one; it is reused between calls. Samples that are
+// no longer resident are read from segments first.
+func (s *Store) Scan(name string, from, to int64, fields []string, fn func(timestamp int64, values []float64)) {
+	// Taken first so compaction cannot replace the segments picked below
+	// before they are read
+	if s.resident > 0 {
+		s.filesMu.RLock()
+		defer s.filesMu.RUnlock()
+	}
+
+	s.mu.RLock()
+	ser, ok := s.series[name]
+	if !ok {
+		s.mu.RUnlock()
+		return
+	}
+
+	var segments []*segment
+	diskFrom, diskTo := max(from, s.cutoff()), min(to, s.evicted-1)
+	if deletedAt, ok := s.deleted[name]; ok {
+		diskFrom = max(diskFrom, deletedAt+1)
+	}
+	if s.evicted > math.MinInt64 && diskFrom <= diskTo {
+		for _, seg := range s.segments {
+			if seg.maxTime >= diskFrom && seg.minTime <= diskTo {
+				segments = append(segments, seg)
+			}
+		}
+	}
+
+	// Copy the resident samples so fn runs without holding mu
+	start := sort.Search(len(ser.samples), func(i int) bool { return ser.samples[i].timestamp >= from })
+	end := sort.Search(len(ser.samples), func(i int) bool { return ser.samples[i].timestamp > to })
+	resident := &series{index: ser.index, samples: append([]storedSample(nil), ser.samples[start:end]...)}
+	indexes := resident.indexes(fields)
+	s.mu.RUnlock()
+
+	values := make([]float64, len(fields))
+	if len(segments) > 0 {
+		stored := s.readSeries(name, segments, diskFrom, diskTo)
+		stored.scan(stored.indexes(fields), values, fn)
+	}
+	resident.scan(indexes, values, fn)
+}
+
+// readSeries reads the samples of a series between from and to from
+// segments, later records replacing earlier ones like in memory. Callers
+// must hold filesMu.
+func (s *Store) readSeries(name string, segments []*segment, from, to int64) *series {
+	ser := newSeries()
+	for _, seg := range segments {
+		err := readRecords(seg.path, func(rec record) {
+			if rec.series != name {
+				return
+			}
+			switch rec.kind {
+			case recordSample:
+				if rec.timestamp >= from && rec.timestamp <= to {
+					ser.insert(rec.timestamp, rec.values)
+				}
+			case recordDelete:
+				ser.samples = nil
+			}
+		})
+		// Segments past the retention may be removed while reading, and
+		// damaged tails were already reported on load
+		if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, errCorruptRecord) {
+			log.Printf("tsdb: failed to read segment %s: %v", seg.path, err)
+		}
+	}
+	return ser
+}
+
+// indexes maps fields to their index in the samples of ser, -1 for fields it
+// does not record
+func (ser *series) indexes(fields []string) []int {
+	indexes := make([]int, len(fields))
+	for i, field := range fields {
+		if idx, ok := ser.index[field]; ok {
+			indexes[i] = idx
+		} else {
+			indexes[i] = -1
+		}
+	}
+	return indexes
+}
+
+// scan calls fn for every sample of ser with the fields at indexes
+func (ser *series) scan(indexes []int, values []float64, fn func(timestamp int64, values []float64)) {
+	for _, sample := range ser.samples {
+		for i, idx := range indexes {
+			if idx >= 0 && idx < len(sample.values) {
+				values[i] = sample.values[idx]
+			} else {
+				values[i] = math.NaN()
+			}
+		}
+		fn(sample.timestamp, values)
+	}
+}
+
+// Query returns the samples of a series between from and to (inclusive, Unix
+// seconds) with every recorded field
+func (s *Store) Query(name string, from, to int64) []Sample {
+	s.mu.RLock()
+	fields := []string{}
+	if ser, ok := s.series[name]; ok {
+		fields = append(fields, ser.fields...)
+	}
+	s.mu.RUnlock()
+
+	samples := []Sample{}
+	s.Scan(name, from, to, fields, func(timestamp int64, values []float64) {
+		sample := Sample{Timestamp: timestamp, Values: make(map[string]float64, len(fields))}
+		for i, field := range fields {
+			if !math.IsNaN(values[i]) {
+				sample.Values[field] = values[i]
+			}
+		}
+		samples = append(samples, sample)
+	})
+	return samples
+}
+
+// Series returns the names of all series
+func (s *Store) Series() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.series))
+	for name := range s.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fields returns the fields recorded for a series
+func (s *Store) Fields(name string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser, ok := s.series[name]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), ser.fields...)
+}
+
+// Last returns the timestamp of the newest sample of a series
+func (s *Store) Last(name string) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ser, ok := s.series[name]
+	if !ok {
+		return 0, false
+	}
+	return ser.last, true
+}
+
+// insert adds a sample to a series, keeping it in memory unless it is older
+// than the resident window. Callers must hold mu.
+func (s *Store) insert(name string, timestamp int64, values map[string]float64) {
+	ser, ok := s.series[name]
+	if !ok {
+		ser = newSeries()
+		s.series[name] = ser
+	}
+
+	if timestamp < s.evicted {
+		ser.addFields(values)
+		ser.last = max(ser.last, timestamp)
+		return
+	}
+	ser.insert(timestamp, values)
+}
+
+// addFields registers the fields of values that ser does not record yet
+func (ser *series) addFields(values map[string]float64) {
+	for field := range values {
+		if _, ok := ser.index[field]; !ok {
+			ser.index[field] = len(ser.fields)
+			ser.fields = append(ser.fields, field)
+		}
+	}
+}
+
+// insert adds a sample, replacing one recorded at the same timestamp
+func (ser *series) insert(timestamp int64, values map[string]float64) {
+	ser.addFields(values)
+	ser.last = max(ser.last, timestamp)
+
+	stored := storedSample{timestamp: timestamp, values: make([]float64, len(ser.fields))}
+	for i := range stored.values {
+		stored.values[i] = math.NaN()
+	}
+	for field, value := range values {
+		stored.values[ser.index[field]] = value
+	}
+
+	// Samples almost always arrive in order
+	n := len(ser.samples)
+	if n == 0 || ser.samples[n-1].timestamp < timestamp {
+		ser.samples = append(ser.samples, stored)
+		return
+	}
+
+	i := sort.Search(n, func(i int) bool { return ser.samples[i].timestamp >= timestamp })
+	if i < n && ser.samples[i].timestamp == timestamp {
+		ser.samples[i] = stored
+		return
+	}
+	ser.samples = append(ser.samples, storedSample{})
+	copy(ser.samples[i+1:], ser.samples[i:])
+	ser.samples[i] = stored
+}
+
+// ensureActive opens a new segment when there is none or the active one is
+// older than segmentDuration. Callers must hold mu.
+func (s *Store) ensureActive(timestamp int64) error {
+	if s.active != nil && time.Since(time.Unix(s.active.segment.start, 0)) < segmentDuration {
+		return nil
+	}
+
+	if s.active != nil {
+		if err := s.active.close(); err != nil {
+			log.Printf("tsdb: failed to close segment %s: %v", s.active.segment.path, err)
+		}
+		s.active = nil
+	}
+
+	start := time.Now().Unix()
+	if len(s.segments) > 0 && s.segments[len(s.segments)-1].start >= start {
+		// Keep segment names unique and ordered
+		start = s.segments[len(s.segments)-1].start + 1
+	}
+
+	seg := &segment{path: filepath.Join(s.dir, segmentName(start)), start: start, minTime: timestamp, maxTime: timestamp}
+	writer, err := createSegment(seg)
+	if err != nil {
+		return err
+	}
+
+	s.segments = append(s.segments, seg)
+	s.active = writer
+	return nil
+}
+
+// load reads every segment in time order. Segments past the retention are
+// removed, as are segments already merged into a compacted one by a
+// compaction that was interrupted. A damaged tail (from a crash mid-write) is
+// ignored.
+func (s *Store) load() error {
+	segments, err := listSegments(s.dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := s.cutoff()
+	s.evicted = s.residentCutoff()
+	compactedThrough := int64(math.MinInt64)
+	for _, seg := range segments {
+		if seg.start <= compactedThrough {
+			if err := os.Remove(seg.path); err != nil {
+				log.Printf("tsdb: failed to remove compacted segment %s: %v", seg.path, err)
+			}
+			continue
+		}
+
+		err := readSegment(seg, func(rec record) {
+			switch rec.kind {
+			case recordCompacted:
+				compactedThrough = max(compactedThrough, rec.timestamp)
+			case recordSample:
+				if rec.timestamp >= cutoff {
+					s.insert(rec.series, rec.timestamp, rec.values)
+				}
+			case recordDelete:
+				delete(s.series, rec.series)
+				s.deleted[rec.series] = rec.timestamp
+			}
+		})
+		if err != nil {
+			log.Printf("tsdb: segment %s is damaged, ignoring the rest of it: %v", seg.path, err)
+		}
+
+		if seg.maxTime < cutoff {
+			if err := os.Remove(seg.path); err != nil {
+				log.Printf("tsdb: failed to remove expired segment %s: %v", seg.path, err)
+			}
+			continue
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	loaded := 0
+	for _, ser := range s.series {
+		loaded += len(ser.samples)
+	}
+	log.Printf("tsdb: loaded %d resident samples of %d series from %d segments in %s", loaded, len(s.series), len(s.segments), s.dir)
+	return nil
+}
+
+// cutoff returns the oldest timestamp within the retention
+func (s *Store) cutoff() int64 {
+	if s.retention <= 0 {
+		return math.MinInt64
+	}
+	return time.Now().Add(-s.retention).Unix()
+}
+
+// residentCutoff returns the oldest timestamp kept in memory
+func (s *Store) residentCutoff() int64 {
+	if s.resident <= 0 {
+		return math.MinInt64
+	}
+	return time.Now().Add(-s.resident).Unix()
+}
+
+func (s *Store) maintenanceLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.expire()
+			if s.dir != "" {
+				if err := s.Compact(); err != nil {
+					log.Printf("tsdb: compaction failed: %v", err)
+				}
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// expire drops series and segments past the retention and evicts samples
+// that left the resident window from memory
+func (s *Store) expire() {
+	cutoff := s.cutoff()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evicted = max(s.evicted, s.residentCutoff())
+	keep := max(cutoff, s.evicted)
+	for name, ser := range s.series {
+		if ser.last < cutoff {
+			delete(s.series, name)
+			continue
+		}
+		i := sort.Search(len(ser.samples), func(i int) bool { return ser.samples[i].timestamp >= keep })
+		if i > 0 {
+			ser.samples = append([]storedSample(nil), ser.samples[i:]...)
+		}
+	}
+
+	kept := s.segments[:0]
+	for _, seg := range s.segments {
+		if seg.maxTime < cutoff && (s.active == nil || seg != s.active.segment) {
+			if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+				log.Printf("tsdb: failed to remove expired segment %s: %v", seg.path, err)
+			}
+			continue
+		}
+		kept = append(kept, seg)
+	}
+	s.segments = kept
+
+	for name, deletedAt := range s.deleted {
+		if deletedAt < cutoff {
+			delete(s.deleted, name)
+		}
+	}
+}
+
+// segmentWriter appends records to the active segment
+type segmentWriter struct {
+	segment *segment
+	file    *os.File
+	buf     *bufio.Writer
+}
+
+func createSegment(seg *segment) (*segmentWriter, error) {
+	file, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment: %w", err)
+	}
+	return &segmentWriter{segment: seg, file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+// write appends a record and flushes it to the file, so a crash loses at most
+// what the OS has not written back yet
+func (w *segmentWriter) write(rec record) error {
+	if err := writeRecord(w.buf, rec); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	w.segment.observe(rec.timestamp)
+	return nil
+}
+
+func (w *segmentWriter) close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}